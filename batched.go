@@ -0,0 +1,156 @@
+package gosmig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// hasBatched tells whether any of the migrations is a batched one, in which
+// case the cursors of the batched migrations in progress are recorded.
+func hasBatched[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) bool {
+
+	return slices.ContainsFunc(migrations, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return m.Batched != nil
+	})
+}
+
+// runBatched applies the batched migration one batch after the other, each in
+// its own transaction along with the cursor of the next batch, resuming from
+// the cursor recorded by a previous run, if any. Each transaction locks the
+// cursor and fails if it moved since it was read, e.g. because of a
+// concurrent run. The version is recorded, and the cursor deleted, in the
+// transaction of the last batch.
+func runBatched[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migration Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+) error {
+
+	version := migration.Version
+
+	cursor, nbBatches, err := getBatchCursor(ctx, db, version, config)
+	if err != nil {
+		return withPhase(PhaseBookkeeping, err)
+	}
+	if nbBatches > 0 {
+		_, _ = fmt.Fprintf(output, "[~] Resuming migration version %d after batch %d (cursor: %s)\n",
+			version, nbBatches, cursor)
+	}
+
+	for {
+		var next string
+		var done bool
+		err := executeInTx(ctx, db, func(ctx context.Context, tx TTX) error {
+			if err := checkPendingVersion(ctx, tx, version, config); err != nil {
+				return err
+			}
+
+			if err := checkBatchCursor(ctx, tx, version, cursor, nbBatches, config); err != nil {
+				return withPhase(PhaseBookkeeping, err)
+			}
+
+			if err := setSearchPath(ctx, tx, config); err != nil {
+				return withPhase(PhaseBookkeeping, err)
+			}
+
+			batchCtx, cancelBatch := context.WithTimeout(ctx, config.Timeout)
+			defer cancelBatch()
+			var err error
+			next, done, err = migration.Batched.Batch(batchCtx, tx, cursor)
+			if err != nil {
+				return withPhase(PhaseFunc, fmt.Errorf(
+					"failed to apply batch %d of migration version %d: %w", nbBatches+1, version, err))
+			}
+
+			if done {
+				if err := deleteBatchCursor(ctx, tx, version, config); err != nil {
+					return withPhase(PhaseBookkeeping, err)
+				}
+				if err := insertDBVersion(ctx, tx, version, config); err != nil {
+					return withPhase(PhaseBookkeeping, err)
+				}
+				return nil
+			}
+
+			if err := upsertBatchCursor(ctx, tx, version, next, nbBatches+1, config); err != nil {
+				return withPhase(PhaseBookkeeping, err)
+			}
+			return nil
+		}, config.Timeout)
+		if err != nil {
+			return err
+		}
+
+		nbBatches++
+		if done {
+			_, _ = fmt.Fprintf(output, "[~] Applied last batch %d of migration version %d\n",
+				nbBatches, version)
+			return nil
+		}
+
+		cursor = next
+		_, _ = fmt.Fprintf(output, "[~] Applied batch %d of migration version %d (next cursor: %s)\n",
+			nbBatches, version, cursor)
+	}
+}
+
+// runCmdResetBatch deletes the cursor of the batched migration with the given
+// version, left behind by a run which was abandoned, so that up applies it
+// again from its first batch.
+func runCmdResetBatch[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	version int,
+	config *Config,
+) error {
+
+	isBatched := func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return m.Version == version && m.Batched != nil
+	}
+	if !slices.ContainsFunc(migrations, isBatched) {
+		return fmt.Errorf("unknown batched migration version %d", version)
+	}
+
+	cursor, nbBatches, err := getBatchCursor(ctx, db, version, config)
+	if err != nil {
+		return err
+	}
+	if nbBatches == 0 {
+		_, _ = fmt.Fprintf(output, "Migration version %d has no batches applied\n", version)
+		return nil
+	}
+
+	if err := deleteBatchCursor(ctx, db, version, config); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(output, "Reset migration version %d after batch %d (cursor: %s)\n",
+		version, nbBatches, cursor)
+	return nil
+}
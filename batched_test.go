@@ -0,0 +1,343 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestBatchedMigration creates a batched migration processing the
+// batches at cursors "", "10" and "20", and failing at the failAt cursor.
+func createTestBatchedMigration(version int, failAt string) migrationMock {
+	nextCursors := map[string]string{"": "10", "10": "20"}
+	return migrationMock{
+		Version: version,
+		Batched: &Batched[*dbRowMock, *dbResultMock, *txMock]{
+			Batch: func(ctx context.Context, tx *txMock, cursor string) (string, bool, error) {
+				if cursor == failAt {
+					return "", false, errors.New("deadlock detected")
+				}
+				next, ok := nextCursors[cursor]
+				return next, !ok, nil
+			},
+			Down: func(ctx context.Context, tx *txMock) error {
+				_, err := tx.ExecContext(ctx, "UPDATE test SET id = NULL")
+				return err
+			},
+		},
+	}
+}
+
+// setupBatchCursorMock mocks the query of the cursor recorded for a batched migration.
+func setupBatchCursorMock(db *dbMock, version int, cursor string, batches int) {
+	db.On("QueryRowContext", mock.Anything,
+		selectBatchCursorSQL(batchTableName(migrationsTableName)), version).
+		Return(batchCursorRow(cursor, batches)).
+		Once()
+}
+
+// batchCursorRow returns a row scanning the cursor of a batched migration.
+func batchCursorRow(cursor string, batches int) *dbRowMock {
+	row := new(dbRowMock)
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			dest := args.Get(0).([]any)
+			*(dest[0].(*string)) = cursor
+			*(dest[1].(*int)) = batches
+		}).
+		Return(nil).
+		Once()
+	return row
+}
+
+// setupBatchMocks mocks the transaction of a batch, which locks the cursor of
+// the batch and records the next one, or the version if it's the last batch.
+func setupBatchMocks(db *dbMock, tx *txMock, version int, next string, batches int, last bool) {
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(version - 1)).
+		Once()
+	cursor, prevBatches := map[string]string{"10": "", "20": "10"}[next], batches-1
+	if last {
+		cursor, prevBatches = "20", 2
+	}
+	tx.On("QueryRowContext", mock.Anything,
+		lockBatchCursorSQL(batchTableName(migrationsTableName)), version).
+		Return(batchCursorRow(cursor, prevBatches)).
+		Once()
+	if last {
+		tx.On("ExecContext", mock.Anything,
+			deleteBatchCursorSQL(batchTableName(migrationsTableName)), version).
+			Return(new(dbResultMock), nil).
+			Once()
+		tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), version).
+			Return(new(dbResultMock), nil).
+			Once()
+	} else {
+		tx.On("ExecContext", mock.Anything,
+			upsertBatchCursorSQL(batchTableName(migrationsTableName)), version, next, batches).
+			Return(new(dbResultMock), nil).
+			Once()
+	}
+	tx.On("Commit").Return(nil).Once()
+}
+
+func TestRunCmdUpBatched(t *testing.T) {
+	testCases := []struct {
+		name      string
+		failAt    string
+		setupMock func(*dbMock, *txMock)
+		wantOut   string
+		wantErr   string
+		wantErrIs error
+		wantPhase Phase
+	}{
+		{
+			name:   "applies all the batches",
+			failAt: "none",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupBatchCursorMock(db, 1, "", 0)
+				setupBatchMocks(db, tx, 1, "10", 1, false)
+				setupBatchMocks(db, tx, 1, "20", 2, false)
+				setupBatchMocks(db, tx, 1, "", 0, true)
+			},
+			wantOut: "[~] Applied batch 1 of migration version 1 (next cursor: 10)\n" +
+				"[~] Applied batch 2 of migration version 1 (next cursor: 20)\n" +
+				"[~] Applied last batch 3 of migration version 1\n" +
+				"[x] Applied migration version 1\n" +
+				"1 migration(s) applied\n",
+		},
+		{
+			name:   "resumes from the recorded cursor",
+			failAt: "none",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupBatchCursorMock(db, 1, "20", 2)
+				setupBatchMocks(db, tx, 1, "", 0, true)
+			},
+			wantOut: "[~] Resuming migration version 1 after batch 2 (cursor: 20)\n" +
+				"[~] Applied last batch 3 of migration version 1\n" +
+				"[x] Applied migration version 1\n" +
+				"1 migration(s) applied\n",
+		},
+		{
+			name:   "failing batch keeps the previous cursor",
+			failAt: "10",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupBatchCursorMock(db, 1, "", 0)
+				setupBatchMocks(db, tx, 1, "10", 1, false)
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(0)).
+					Once()
+				tx.On("QueryRowContext", mock.Anything,
+					lockBatchCursorSQL(batchTableName(migrationsTableName)), 1).
+					Return(batchCursorRow("10", 1)).
+					Once()
+				tx.On("Rollback").Return(nil).Once()
+			},
+			wantOut: "[~] Applied batch 1 of migration version 1 (next cursor: 10)\n",
+			wantErr: "migration version 1 up failed (TX, func phase): failed to execute in " +
+				"transaction: failed to apply batch 2 of migration version 1: deadlock detected",
+			wantPhase: PhaseFunc,
+		},
+		{
+			name:   "cursor moved by a concurrent run",
+			failAt: "none",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupBatchCursorMock(db, 1, "", 0)
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(0)).
+					Once()
+				tx.On("QueryRowContext", mock.Anything,
+					lockBatchCursorSQL(batchTableName(migrationsTableName)), 1).
+					Return(batchCursorRow("10", 1)).
+					Once()
+				tx.On("Rollback").Return(nil).Once()
+			},
+			wantErr: "migration version 1 up failed (TX, bookkeeping phase): failed to execute in " +
+				"transaction: cursor of batched migration changed while applying it: version 1: " +
+				`expected batch 0 (cursor: ""), found batch 1 (cursor: "10")`,
+			wantErrIs: ErrBatchCursorMoved,
+			wantPhase: PhaseBookkeeping,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(0)).
+				Once()
+			tc.setupMock(db, tx)
+
+			var out bytes.Buffer
+			_, err := runCmdUp(context.Background(),
+				[]migrationMock{createTestBatchedMigration(1, tc.failAt)}, db, &out, 0, DefaultConfig())
+
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+			require.Equal(t, tc.wantOut, out.String())
+
+			if tc.wantErr != "" {
+				var migErr *MigrationError
+				require.ErrorAs(t, err, &migErr)
+				require.Equal(t, tc.wantPhase, migErr.Phase)
+				require.EqualError(t, err, tc.wantErr)
+				if tc.wantErrIs != nil {
+					require.ErrorIs(t, err, tc.wantErrIs)
+				}
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRunCmdUpAtomicRefusesBatched(t *testing.T) {
+	db := new(dbMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(0)).
+		Once()
+
+	var out bytes.Buffer
+	_, err := runCmdUpAtomic(context.Background(),
+		[]migrationMock{createTestBatchedMigration(1, "none")}, db, &out, DefaultConfig())
+	require.EqualError(t, err, "can't apply the migrations atomically: migration version 1 "+
+		"is committed batch by batch (Batched)")
+	db.AssertExpectations(t)
+}
+
+func TestRunCmdDownBatched(t *testing.T) {
+	db := new(dbMock)
+	tx := new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(1)).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(1)).
+		Once()
+	tx.On("ExecContext", mock.Anything, "UPDATE test SET id = NULL").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), 1).
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("Commit").Return(nil).Once()
+
+	var out bytes.Buffer
+	err := runCmdDown(context.Background(),
+		[]migrationMock{createTestBatchedMigration(1, "none")}, db, &out, DefaultConfig())
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+	require.Equal(t, "[x]-->[ ] Rolled back migration version 1\n", out.String())
+}
+
+func TestRunCmdStatusBatched(t *testing.T) {
+	testCases := []struct {
+		name         string
+		batchesExist bool
+		wantStatus   string
+	}{
+		{
+			name:         "batches applied",
+			batchesExist: true,
+			wantStatus:   "[ ] PENDING (in progress: 2 batch(es) applied)",
+		},
+		{name: "no batches table yet", wantStatus: "[ ] PENDING "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			expectMigrationsColumns(db, migrationsTableName)
+			db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+				Return(appliedVersionsRow(1)).
+				Once()
+			db.On("QueryRowContext", mock.Anything, tableExistsSQL(), batchTableName(migrationsTableName)).
+				Return(boolRow(tc.batchesExist)).
+				Once()
+			if tc.batchesExist {
+				setupBatchCursorMock(db, 2, "20", 2)
+			}
+
+			var out bytes.Buffer
+			nbPending, err := runCmdStatus(context.Background(),
+				[]migrationMock{createTestMigrations(1)[0], createTestBatchedMigration(2, "none")},
+				nil, db, &out, DefaultConfig())
+			require.NoError(t, err)
+			db.AssertExpectations(t)
+
+			require.Equal(t,
+				"VERSION    STATUS      \n"+
+					"2          "+tc.wantStatus+"\n"+
+					"1          [x] APPLIED \n",
+				out.String())
+			require.Equal(t, 1, nbPending)
+		})
+	}
+}
+
+func TestRunCmdResetBatch(t *testing.T) {
+	migrations := []migrationMock{createTestMigrations(1)[0], createTestBatchedMigration(2, "none")}
+
+	testCases := []struct {
+		name      string
+		version   int
+		setupMock func(*dbMock)
+		wantOut   string
+		wantErr   string
+	}{
+		{
+			name:    "deletes the cursor",
+			version: 2,
+			setupMock: func(db *dbMock) {
+				setupBatchCursorMock(db, 2, "20", 2)
+				db.On("ExecContext", mock.Anything,
+					deleteBatchCursorSQL(batchTableName(migrationsTableName)), 2).
+					Return(new(dbResultMock), nil).
+					Once()
+			},
+			wantOut: "Reset migration version 2 after batch 2 (cursor: 20)\n",
+		},
+		{
+			name:    "no batches applied",
+			version: 2,
+			setupMock: func(db *dbMock) {
+				setupBatchCursorMock(db, 2, "", 0)
+			},
+			wantOut: "Migration version 2 has no batches applied\n",
+		},
+		{
+			name:      "not a batched migration",
+			version:   1,
+			setupMock: func(db *dbMock) {},
+			wantErr:   "unknown batched migration version 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tc.setupMock(db)
+
+			var out bytes.Buffer
+			err := runCmdResetBatch(context.Background(), migrations, db, &out, tc.version, DefaultConfig())
+			db.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, out.String())
+		})
+	}
+}
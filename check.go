@@ -0,0 +1,338 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Check checks, without migrating, that the database matches the migrations:
+// that none is pending (ErrPendingMigrations), that no migration unknown to
+// them is applied (ErrDBAhead), and that the applied ones didn't change since
+// they were applied (ErrChecksumDrift, for the migrations with a Checksum,
+// like the ones loaded from SQL files). The errors are joined, so use
+// errors.Is to check them. It only reads the migrations table, without
+// creating it (a missing one is at version 0), so that services embedding
+// their migrations can run it at every startup, to fail fast when they are
+// deployed against a database which isn't migrated for them.
+func Check[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) error {
+
+	if len(migrations) == 0 {
+		return ErrNoMigrations
+	}
+
+	if config == nil {
+		config = DefaultConfig()
+	} else {
+		config.ensureDefaults()
+	}
+
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	if err := validateMigrations(migrations, config.Versioning); err != nil {
+		return err
+	}
+
+	return checkMigrations(ctx, migrations, db, config)
+}
+
+// hasChecksum tells whether any of the migrations has a checksum, in which
+// case the migrations table records it when they're applied.
+func hasChecksum[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) bool {
+
+	return slices.ContainsFunc(migrations, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return m.Checksum != ""
+	})
+}
+
+// withChecksum records the checksum of the migration, if it has one, once
+// migrate applied it, so that Check can detect if it changes afterwards.
+func withChecksum[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	version int,
+	checksum string,
+	migrate func(ctx context.Context, dbOrTX TDBOrTX) error,
+	config *Config,
+) func(context.Context, TDBOrTX) error {
+
+	if checksum == "" {
+		return migrate
+	}
+
+	return func(ctx context.Context, dbOrTX TDBOrTX) error {
+		if err := migrate(ctx, dbOrTX); err != nil {
+			return err
+		}
+
+		if err := setMigrationChecksum(ctx, dbOrTX, version, checksum, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		return nil
+	}
+}
+
+func runCmdCheck[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+) error {
+
+	if err := checkMigrations(ctx, migrations, db, config); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(output, "Database is up to date")
+
+	return nil
+}
+
+// checkExitCode returns the exit code of the check command failing with err.
+func checkExitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrDBAhead):
+		return ExitDBAhead
+	case errors.Is(err, ErrChecksumDrift):
+		return ExitChecksumDrift
+	case errors.Is(err, ErrPendingMigrations):
+		return ExitPendingMigrations
+	default:
+		return ExitFailure
+	}
+}
+
+func checkMigrations[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) error {
+
+	if usesGraph(migrations) {
+		return checkMigrationsGraph(ctx, migrations, db, config)
+	}
+
+	migrations = slices.Clone(migrations)
+	sortMigrationsAsc(migrations)
+
+	applied, _, err := readAppliedVersions(ctx, db, hasChecksum(migrations), config)
+	if err != nil {
+		return err
+	}
+
+	dbVersion := maxAppliedVersion(applied)
+
+	var pending, drifted []string
+	for _, migration := range migrations {
+		checksum, ok := applied[migration.Version]
+		switch {
+		case !ok && migration.Version > dbVersion:
+			pending = append(pending, migration.ID())
+		case ok && checksum != "" && migration.Checksum != "" && checksum != migration.Checksum:
+			drifted = append(drifted, migration.ID())
+		}
+	}
+
+	var unknown []string
+	for _, version := range unknownVersions(applied, migrations) {
+		unknown = append(unknown, strconv.Itoa(version))
+	}
+
+	return checkErr(pending, unknown, drifted)
+}
+
+// maxAppliedVersion returns the database version, i.e. the highest of the
+// applied versions, or 0 if none is.
+func maxAppliedVersion(applied map[int]string) int {
+	var dbVersion int
+	for version := range applied {
+		dbVersion = max(dbVersion, version)
+	}
+	return dbVersion
+}
+
+// unknownVersions returns, in ascending order, the applied versions which
+// don't match any of the migrations, e.g. the ones applied by a newer
+// release of the code.
+func unknownVersions[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	applied map[int]string,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) []int {
+
+	known := make(map[int]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+
+	// The migrations squashed into a baseline are still recorded as applied.
+	baselineVersion := latestBaselineVersion(migrations)
+	var unknown []int
+	for version := range applied {
+		if !known[version] && version > baselineVersion {
+			unknown = append(unknown, version)
+		}
+	}
+	slices.Sort(unknown)
+
+	return unknown
+}
+
+// checkMigrationsGraph checks migrations with modules or dependencies, which
+// are tracked by ID, without their checksums.
+func checkMigrationsGraph[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) error {
+
+	ordered, err := sortMigrationsGraph(migrations)
+	if err != nil {
+		return err
+	}
+
+	applied, err := readAppliedIDs(ctx, db, config)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	known := make(map[string]bool, len(ordered))
+	for _, migration := range ordered {
+		known[migration.ID()] = true
+		if !applied[migration.ID()] {
+			pending = append(pending, migration.ID())
+		}
+	}
+
+	var unknown []string
+	for id := range applied {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	slices.Sort(unknown)
+
+	return checkErr(pending, unknown, nil)
+}
+
+// readAppliedVersions is getAppliedVersions for the read-only commands (see
+// readOnlyCommands), which don't create the tracking tables: a missing
+// migrations table has no versions applied, and the checksums aren't read if
+// the table doesn't have them yet (e.g. if an older release created it). It
+// also returns the columns of the table, none if it's missing.
+func readAppliedVersions[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	withChecksums bool,
+	config *Config,
+) (map[int]string, map[string]bool, error) {
+
+	columns, err := getMigrationsColumns(ctx, dbOrTX, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(columns) == 0 {
+		return map[int]string{}, columns, nil
+	}
+
+	applied, err := getAppliedVersions(ctx, dbOrTX, withChecksums && columns["checksum"], config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return applied, columns, nil
+}
+
+// readAppliedIDs is getAppliedIDs for the read-only commands: if the graph
+// table doesn't exist yet, the migrations applied are the ones createGraphTable
+// will record, by version.
+func readAppliedIDs[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) (map[string]bool, error) {
+
+	exists, err := tableExists(ctx, dbOrTX, graphTableName(config.migrationsTable()), config)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return getAppliedIDs(ctx, dbOrTX, config)
+	}
+
+	versions, _, err := readAppliedVersions(ctx, dbOrTX, false, config)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]bool, len(versions))
+	for version := range versions {
+		applied[strconv.Itoa(version)] = true
+	}
+	return applied, nil
+}
+
+// checkErr returns the errors of the pending, unknown and drifted migrations,
+// if any, joined.
+func checkErr(pending, unknown, drifted []string) error {
+	var errs []error
+	if len(pending) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrPendingMigrations, strings.Join(pending, ", ")))
+	}
+	if len(unknown) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrDBAhead, strings.Join(unknown, ", ")))
+	}
+	if len(drifted) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrChecksumDrift, strings.Join(drifted, ", ")))
+	}
+	return errors.Join(errs...)
+}
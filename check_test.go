@@ -0,0 +1,289 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestChecksumMigrations returns migrations with the given checksums,
+// by version, like the ones loaded from SQL files.
+func createTestChecksumMigrations(checksums map[int]string, versions ...int) []migrationMock {
+	migrations := createTestMigrations(versions...)
+	for i := range migrations {
+		migrations[i].Checksum = checksums[migrations[i].Version]
+	}
+	return migrations
+}
+
+func TestCheck(t *testing.T) {
+	checksums := map[int]string{1: "aaa", 2: "bbb"}
+
+	testCases := []struct {
+		name       string
+		migrations []migrationMock
+		applied    string
+		wantErrs   []error
+		wantErr    string
+	}{
+		{
+			name:       "up to date",
+			migrations: createTestChecksumMigrations(checksums, 1, 2, 3),
+			applied:    "1:aaa,2:bbb,3:",
+		},
+		{
+			name:       "pending",
+			migrations: createTestChecksumMigrations(checksums, 1, 2, 3),
+			applied:    "1:aaa",
+			wantErrs:   []error{ErrPendingMigrations},
+			wantErr:    "pending migrations: 2, 3",
+		},
+		{
+			name:       "database ahead",
+			migrations: createTestChecksumMigrations(checksums, 1, 2, 3),
+			applied:    "1:aaa,2:bbb,3:,4:,5:",
+			wantErrs:   []error{ErrDBAhead},
+			wantErr:    "database ahead of the code, unknown migrations applied: 4, 5",
+		},
+		{
+			name:       "checksum drift",
+			migrations: createTestChecksumMigrations(checksums, 1, 2, 3),
+			applied:    "1:aaa,2:ccc,3:",
+			wantErrs:   []error{ErrChecksumDrift},
+			wantErr:    "checksum drift, migrations changed since they were applied: 2",
+		},
+		{
+			name:       "checksum not recorded",
+			migrations: createTestChecksumMigrations(checksums, 1, 2, 3),
+			applied:    "1:,2:bbb,3:",
+		},
+		{
+			name:       "drift and ahead",
+			migrations: createTestChecksumMigrations(checksums, 1, 2),
+			applied:    "1:xxx,2:bbb,7:",
+			wantErrs:   []error{ErrDBAhead, ErrChecksumDrift},
+			wantErr: "database ahead of the code, unknown migrations applied: 7\n" +
+				"checksum drift, migrations changed since they were applied: 1",
+		},
+		{
+			name: "squashed into baseline",
+			migrations: func() []migrationMock {
+				migrations := createTestChecksumMigrations(map[int]string{3: "ccc"}, 3, 4)
+				migrations[0].Baseline = true
+				return migrations
+			}(),
+			applied: "1:aaa,2:bbb,3:ccc,4:",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			expectMigrationsColumns(db, migrationsTableName)
+			db.On("QueryRowContext", mock.Anything, selectAppliedChecksumsSQL(migrationsTableName)).
+				Return(appliedIDsRow(tc.applied)).
+				Once()
+
+			err := Check(context.Background(), tc.migrations, db, nil)
+
+			db.AssertExpectations(t)
+
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.wantErr)
+			for _, wantErr := range tc.wantErrs {
+				require.ErrorIs(t, err, wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckWithoutChecksums(t *testing.T) {
+	db := new(dbMock)
+	expectMigrationsColumns(db, migrationsTableName)
+	db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+		Return(appliedIDsRow("1,2")).
+		Once()
+
+	err := Check(context.Background(), createTestMigrations(1, 2, 3), db, nil)
+
+	db.AssertExpectations(t)
+	require.ErrorIs(t, err, ErrPendingMigrations)
+	require.EqualError(t, err, "pending migrations: 3")
+}
+
+func TestCheckDoesNotReorderMigrations(t *testing.T) {
+	unordered := createTestMigrations(2, 1)
+	db := new(dbMock)
+	expectMigrationsColumns(db, migrationsTableName)
+	db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+		Return(appliedIDsRow("")).
+		Once()
+
+	err := Check(context.Background(), unordered, db, nil)
+
+	db.AssertExpectations(t)
+	require.EqualError(t, err, "pending migrations: 1, 2")
+	require.Equal(t, []int{2, 1}, []int{unordered[0].Version, unordered[1].Version})
+}
+
+func TestCheckGraph(t *testing.T) {
+	migrations := []migrationMock{
+		createTestModuleMigration("users", 1),
+		createTestModuleMigration("billing", 1, "users/1"),
+	}
+
+	db := new(dbMock)
+	db.On("QueryRowContext", mock.Anything, tableExistsSQL(), graphTableName(migrationsTableName)).
+		Return(boolRow(true)).
+		Once()
+	db.On("QueryRowContext", mock.Anything, selectAppliedIDsSQL(graphTableName(migrationsTableName))).
+		Return(appliedIDsRow("users/1,orders/1")).
+		Once()
+
+	err := Check(context.Background(), migrations, db, nil)
+
+	db.AssertExpectations(t)
+	require.ErrorIs(t, err, ErrPendingMigrations)
+	require.ErrorIs(t, err, ErrDBAhead)
+	require.EqualError(t, err, "pending migrations: billing/1\n"+
+		"database ahead of the code, unknown migrations applied: orders/1")
+}
+
+func TestCheckWithoutTrackingTables(t *testing.T) {
+	graphMigrations := []migrationMock{
+		createTestMigrations(1)[0],
+		createTestModuleMigration("billing", 1, "1"),
+	}
+
+	testCases := []struct {
+		name       string
+		migrations []migrationMock
+		columns    string
+		applied    string
+		wantErr    string
+	}{
+		{
+			name:       "no migrations table",
+			migrations: createTestChecksumMigrations(map[int]string{1: "aaa"}, 1, 2),
+			wantErr:    "pending migrations: 1, 2",
+		},
+		{
+			name:       "no checksum column",
+			migrations: createTestChecksumMigrations(map[int]string{1: "aaa"}, 1, 2),
+			columns:    "version,applied_at",
+			applied:    "1",
+			wantErr:    "pending migrations: 2",
+		},
+		{
+			name:       "no graph table",
+			migrations: graphMigrations,
+			columns:    migrationsColumns,
+			applied:    "1",
+			wantErr:    "pending migrations: billing/1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			if usesGraph(tc.migrations) {
+				db.On("QueryRowContext", mock.Anything, tableExistsSQL(), graphTableName(migrationsTableName)).
+					Return(boolRow(false)).
+					Once()
+			}
+			db.On("QueryRowContext", mock.Anything, selectColumnsSQL(), "", migrationsTableName).
+				Return(appliedIDsRow(tc.columns)).
+				Once()
+			if tc.columns != "" {
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(appliedIDsRow(tc.applied)).
+					Once()
+			}
+
+			err := Check(context.Background(), tc.migrations, db, nil)
+
+			db.AssertExpectations(t)
+			require.ErrorIs(t, err, ErrPendingMigrations)
+			require.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCheckValidation(t *testing.T) {
+	err := Check(context.Background(), []migrationMock{}, new(dbMock), nil)
+	require.ErrorIs(t, err, ErrNoMigrations)
+
+	err = Check(context.Background(), createTestMigrations(1, 1), new(dbMock), nil)
+	require.Error(t, err)
+}
+
+func TestRunCmdCheck(t *testing.T) {
+	db := new(dbMock)
+	expectMigrationsColumns(db, migrationsTableName)
+	db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+		Return(appliedIDsRow("1,2")).
+		Once()
+
+	var out bytes.Buffer
+	err := runCmdCheck(context.Background(), createTestMigrations(1, 2), db, &out, DefaultConfig())
+
+	db.AssertExpectations(t)
+	require.NoError(t, err)
+	require.Equal(t, "Database is up to date\n", out.String())
+}
+
+func TestCheckExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "pending", err: checkErr([]string{"2"}, nil, nil), want: ExitPendingMigrations},
+		{name: "ahead", err: checkErr(nil, []string{"4"}, nil), want: ExitDBAhead},
+		{name: "drift", err: checkErr(nil, nil, []string{"1"}), want: ExitChecksumDrift},
+		{name: "ahead wins", err: checkErr([]string{"2"}, []string{"4"}, []string{"1"}), want: ExitDBAhead},
+		{name: "other", err: errors.New("connection refused"), want: ExitFailure},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, checkExitCode(tc.err))
+		})
+	}
+}
+
+func TestWithChecksum(t *testing.T) {
+	migrate := func(ctx context.Context, tx *txMock) error {
+		_, err := tx.ExecContext(ctx, "CREATE TABLE test (id INT)")
+		return err
+	}
+
+	tx := new(txMock)
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, updateMigChecksumSQL(migrationsTableName), 1, "aaa").
+		Return(new(dbResultMock), nil).
+		Once()
+
+	run := withChecksum[*dbRowMock, *dbResultMock](1, "aaa", migrate, DefaultConfig())
+	require.NoError(t, run(context.Background(), tx))
+	tx.AssertExpectations(t)
+
+	// Without a checksum, the migration runs as is.
+	tx = new(txMock)
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), nil).
+		Once()
+
+	run = withChecksum[*dbRowMock, *dbResultMock](1, "", migrate, DefaultConfig())
+	require.NoError(t, run(context.Background(), tx))
+	tx.AssertExpectations(t)
+}
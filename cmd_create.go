@@ -0,0 +1,296 @@
+package gosmig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// sequentialVersionWidth is the width sequential versions are zero-padded
+	// to in file names, so that the files are listed in order.
+	sequentialVersionWidth = 3
+
+	defaultGoPackage = "main"
+)
+
+// CreateTemplateData is the data the CreateTemplates are executed with.
+type CreateTemplateData struct {
+	// Version of the new migration.
+	Version int
+	// Name of the new migration, as given to the create command.
+	Name string
+	// Package is the name of the Go package of the existing .go files from
+	// the migrations directory, or "main" if there are none.
+	Package string
+	// NoTX is true if the migration must run without a transaction.
+	NoTX bool
+}
+
+var migrationNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// timeNow is replaced in tests.
+var timeNow = time.Now
+
+const defaultGoTemplate = `package {{.Package}}
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/padurean/gosmig"
+)
+
+// migration{{.Version}} {{.Name}}
+// TODO: add it to the migrations passed to gosmig.New.
+var migration{{.Version}} = gosmig.MigrationSQL{
+	Version: {{.Version}},
+{{- if .NoTX}}
+	UpDownNoTX: &gosmig.UpDownNoTXSQL{
+		Up: func(ctx context.Context, db *sql.DB) error {
+			// TODO: apply the migration.
+			return nil
+		},
+		Down: func(ctx context.Context, db *sql.DB) error {
+			// TODO: roll back the migration.
+			return nil
+		},
+	},
+{{- else}}
+	UpDown: &gosmig.UpDownSQL{
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			// TODO: apply the migration.
+			return nil
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			// TODO: roll back the migration.
+			return nil
+		},
+	},
+{{- end}}
+}
+`
+
+const defaultSQLUpTemplate = `{{if .NoTX}}-- gosmig:no-tx
+{{end}}-- {{.Name}} (up)
+`
+
+const defaultSQLDownTemplate = `-- {{.Name}} (down)
+`
+
+// runCmdCreate scaffolds a new migration named name in config.MigrationsDir
+// (the current directory if not set): a Go file, or a pair of .up.sql and
+// .down.sql files if sqlFiles is true. If the directory holds a gosmig.sum
+// file, it is updated with the new SQL files.
+func runCmdCreate[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	output io.Writer,
+	config *Config,
+	name string,
+	sqlFiles bool,
+	noTX bool,
+) error {
+
+	if !migrationNameRegexp.MatchString(name) {
+		return fmt.Errorf(
+			"invalid migration name %q (only letters, digits, _ and - are allowed)", name)
+	}
+
+	dir := config.MigrationsDir
+	if dir == "" {
+		dir = "."
+	}
+
+	version, err := nextVersion(migrations, dir, config.Versioning)
+	if err != nil {
+		return err
+	}
+
+	data := CreateTemplateData{
+		Version: version,
+		Name:    name,
+		NoTX:    noTX,
+	}
+
+	baseName := migrationFileVersion(version, config.Versioning) + "_" + name
+
+	type fileToCreate struct {
+		name     string
+		tmplText string
+	}
+	var files []fileToCreate
+	if sqlFiles {
+		files = []fileToCreate{
+			{name: baseName + ".up.sql",
+				tmplText: orDefault(config.CreateTemplates.SQLUp, defaultSQLUpTemplate)},
+			{name: baseName + ".down.sql",
+				tmplText: orDefault(config.CreateTemplates.SQLDown, defaultSQLDownTemplate)},
+		}
+	} else {
+		if data.Package, err = goPackageName(dir); err != nil {
+			return err
+		}
+		files = []fileToCreate{
+			{name: baseName + ".go",
+				tmplText: orDefault(config.CreateTemplates.Go, defaultGoTemplate)},
+		}
+	}
+
+	// Render all the templates first, and remove the files already created if
+	// creating one fails, to not leave half of a pair behind.
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		path := filepath.Join(dir, file.name)
+		if contents[i], err = renderTemplate(path, file.tmplText, data); err != nil {
+			return err
+		}
+	}
+
+	var created []string
+	for i, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := createMigrationFile(path, contents[i]); err != nil {
+			for _, createdPath := range created {
+				_ = os.Remove(createdPath)
+			}
+			return err
+		}
+		created = append(created, path)
+	}
+	for _, path := range created {
+		_, _ = fmt.Fprintf(output, "Created %s\n", path)
+	}
+
+	if sqlFiles {
+		if _, err := os.Stat(filepath.Join(dir, sumFileName)); err == nil {
+			if err := WriteSumFile(dir); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(output, "Updated %s\n", filepath.Join(dir, sumFileName))
+		}
+	}
+
+	return nil
+}
+
+// nextVersion returns the version of a new migration: the current UTC time
+// for VersioningTimestamp, otherwise the next version after the highest one
+// among the defined migrations and the SQL files from dir.
+func nextVersion[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	dir string,
+	versioning VersioningScheme,
+) (int, error) {
+
+	if versioning == VersioningTimestamp {
+		return strconv.Atoi(timeNow().UTC().Format(timestampVersionLayout))
+	}
+
+	var maxVersion int
+	for _, migration := range migrations {
+		maxVersion = max(maxVersion, migration.Version)
+	}
+
+	files, _, err := readSQLMigrationFiles(os.DirFS(dir))
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		maxVersion = max(maxVersion, file.version)
+	}
+
+	return maxVersion + 1, nil
+}
+
+// migrationFileVersion returns the version as written in migration file
+// names: zero-padded for sequential versions.
+func migrationFileVersion(version int, versioning VersioningScheme) string {
+	if versioning == VersioningTimestamp {
+		return strconv.Itoa(version)
+	}
+	return fmt.Sprintf("%0*d", sequentialVersionWidth, version)
+}
+
+// goPackageName returns the package name of the first (non-test) .go file
+// from dir, or "main" if there is none.
+func goPackageName(dir string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		return file.Name.Name, nil
+	}
+
+	return defaultGoPackage, nil
+}
+
+func renderTemplate(path, tmplText string, data CreateTemplateData) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(path)).Parse(tmplText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the template of %s: %w", filepath.Base(path), err)
+	}
+
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		return nil, fmt.Errorf("failed to write migration file %s: %w", path, err)
+	}
+	return content.Bytes(), nil
+}
+
+// createMigrationFile creates a new migration file, failing if it already exists.
+func createMigrationFile(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("migration file %s already exists", path)
+		}
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("failed to write migration file %s: %w", path, err)
+	}
+
+	return file.Close()
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,186 @@
+package gosmig
+
+import (
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCmdCreate(t *testing.T) {
+	origTimeNow := timeNow
+	timeNow = func() time.Time {
+		return time.Date(2026, 10, 16, 11, 30, 0, 0, time.FixedZone("EEST", 3*60*60))
+	}
+	t.Cleanup(func() { timeNow = origTimeNow })
+
+	testCases := []struct {
+		name         string
+		migrations   []migrationMock
+		existing     map[string]string
+		config       Config
+		migName      string
+		sqlFiles     bool
+		noTX         bool
+		wantFiles    map[string][]string
+		wantSumFiles []string
+		wantErr      string
+	}{
+		{
+			name:       "Go migration with next version",
+			migrations: createTestMigrations(1, 2),
+			existing: map[string]string{
+				"migrations.go":      "package migrations\n",
+				"migrations_test.go": "package migrations_test\n",
+			},
+			migName: "add_users",
+			wantFiles: map[string][]string{
+				"003_add_users.go": {
+					"package migrations\n",
+					"var migration3 = gosmig.MigrationSQL{\n\tVersion: 3,\n\tUpDown: &gosmig.UpDownSQL{",
+				},
+			},
+		},
+		{
+			name:       "Go migration without transaction in main package",
+			migrations: createTestMigrations(1),
+			migName:    "add_index",
+			noTX:       true,
+			wantFiles: map[string][]string{
+				"002_add_index.go": {
+					"package main\n",
+					"\tUpDownNoTX: &gosmig.UpDownNoTXSQL{",
+				},
+			},
+		},
+		{
+			name:       "SQL migration with version after the SQL files",
+			migrations: createTestMigrations(1),
+			existing: map[string]string{
+				"004_add_d.up.sql":   "ALTER TABLE t ADD COLUMN d INT;",
+				"004_add_d.down.sql": "ALTER TABLE t DROP COLUMN d;",
+			},
+			migName:  "add_e",
+			sqlFiles: true,
+			noTX:     true,
+			wantFiles: map[string][]string{
+				"005_add_e.up.sql":   {"-- gosmig:no-tx\n-- add_e (up)\n"},
+				"005_add_e.down.sql": {"-- add_e (down)\n"},
+			},
+		},
+		{
+			name:       "SQL migration with timestamp version updates the sum file",
+			migrations: createTestMigrations(1),
+			existing:   map[string]string{sumFileName: ""},
+			config:     Config{Versioning: VersioningTimestamp},
+			migName:    "add_users",
+			sqlFiles:   true,
+			wantFiles: map[string][]string{
+				"20261016083000_add_users.up.sql":   {"-- add_users (up)\n"},
+				"20261016083000_add_users.down.sql": {"-- add_users (down)\n"},
+			},
+			wantSumFiles: []string{
+				"20261016083000_add_users.down.sql",
+				"20261016083000_add_users.up.sql",
+			},
+		},
+		{
+			name:       "custom templates",
+			migrations: createTestMigrations(1),
+			config: Config{CreateTemplates: CreateTemplates{
+				SQLUp:   "-- {{.Version}} {{.Name}}: TODO\n",
+				SQLDown: "-- {{.Version}} {{.Name}}: TODO undo\n",
+			}},
+			migName:  "add_users",
+			sqlFiles: true,
+			wantFiles: map[string][]string{
+				"002_add_users.up.sql":   {"-- 2 add_users: TODO\n"},
+				"002_add_users.down.sql": {"-- 2 add_users: TODO undo\n"},
+			},
+		},
+		{
+			name:       "invalid template",
+			migrations: createTestMigrations(1),
+			config:     Config{CreateTemplates: CreateTemplates{SQLDown: "{{.Version"}},
+			migName:    "add_users",
+			sqlFiles:   true,
+			wantErr:    "failed to parse the template of 002_add_users.down.sql",
+		},
+		{
+			name:       "invalid name",
+			migrations: createTestMigrations(1),
+			migName:    "add users",
+			wantErr:    `invalid migration name "add users"`,
+		},
+		{
+			name:       "existing file",
+			migrations: createTestMigrations(1),
+			existing:   map[string]string{"002_add_users.go": "package main\n"},
+			migName:    "add_users",
+			wantErr:    "already exists",
+		},
+		{
+			name:       "existing down SQL file removes the created up SQL file",
+			migrations: createTestMigrations(1),
+			existing: map[string]string{
+				"20261016083000_add_users.down.sql": "-- add_users (down)\n",
+			},
+			config:   Config{Versioning: VersioningTimestamp},
+			migName:  "add_users",
+			sqlFiles: true,
+			wantErr:  "20261016083000_add_users.down.sql already exists",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.existing {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+			config := tc.config
+			config.MigrationsDir = dir
+			config.ensureDefaults()
+
+			var out strings.Builder
+			err := runCmdCreate(tc.migrations, &out, &config, tc.migName, tc.sqlFiles, tc.noTX)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				entries, err := os.ReadDir(dir)
+				require.NoError(t, err)
+				require.Len(t, entries, len(tc.existing), "no file should be created")
+				return
+			}
+
+			require.NoError(t, err)
+			for name, wantContents := range tc.wantFiles {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				for _, want := range wantContents {
+					require.Contains(t, string(content), want)
+				}
+				require.Contains(t, out.String(), "Created "+filepath.Join(dir, name))
+				if filepath.Ext(name) == ".go" {
+					formatted, err := format.Source(content)
+					require.NoError(t, err)
+					require.Equal(t, string(formatted), string(content), "should be gofmt-ed")
+				}
+			}
+
+			if tc.wantSumFiles != nil {
+				sums, err := readSumFile(os.DirFS(dir))
+				require.NoError(t, err)
+				require.Len(t, sums, len(tc.wantSumFiles))
+				for _, name := range tc.wantSumFiles {
+					require.Contains(t, sums, name)
+				}
+				require.Contains(t, out.String(), "Updated "+filepath.Join(dir, sumFileName))
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"time"
 )
 
 func runCmdDown[
@@ -18,12 +17,16 @@ func runCmdDown[
 	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
 	db TDB,
 	output io.Writer,
-	timeout time.Duration,
+	config *Config,
 ) error {
 
+	if usesGraph(migrations) {
+		return runGraphDown(ctx, migrations, db, output, config)
+	}
+
 	sortMigrationsDesc(migrations)
 
-	dbVersion, err := getDBVersion(ctx, db, timeout)
+	dbVersion, err := getDBVersion(ctx, db, config)
 	if err != nil {
 		return err
 	}
@@ -33,17 +36,89 @@ func runCmdDown[
 			continue
 		}
 
-		if migration.UpDown != nil {
-			err = executeInTx(
-				ctx, db, migrateDown(migration.Version, migration.UpDown.Down, timeout), timeout)
+		// The applied versions between it and the database version are unknown.
+		if err := checkDBAhead(dbVersion, migration.Version, config); err != nil {
+			return err
+		}
+
+		if migration.Baseline {
+			return fmt.Errorf("%w: version %d", ErrBaselineRollback, migration.Version)
+		}
+
+		if migration.Irreversible {
+			return fmt.Errorf("%w: version %d", ErrIrreversibleMigration, migration.Version)
+		}
+
+		if len(migration.Tags) > 0 {
+			// Migrations skipped by the tag selector they were applied with are
+			// rolled back without running Down, since their Up never ran.
+			tags, err := getMigrationTags(ctx, db, migration.Version, config)
 			if err != nil {
-				return fmt.Errorf("execute in TX: %w", err)
+				return err
+			}
+			if !selectedByTags(migration.Tags, tags) {
+				err = runMigration(ctx, db, migration, DirectionDown, TxModeTX, "", true, config,
+					func(ctx context.Context) error {
+						return executeInTx(
+							ctx, db, migrateDown(migration.Version, noop[TTX], config), config.Timeout)
+					})
+				if err != nil {
+					return newMigrationError(migration.Version, DirectionDown, TxModeTX, err)
+				}
+
+				_, _ = fmt.Fprintf(
+					output, "[-]-->[ ] Rolled back skipped migration version %d\n", migration.Version)
+				return nil
 			}
-		} else {
-			err = executeNoTx(
-				ctx, db, migrateDown(migration.Version, migration.UpDownNoTX.Down, timeout), timeout)
+		}
+
+		if migration.Contract != nil {
+			contracted, err := isMigrationContracted(ctx, db, migration.Version, config)
 			if err != nil {
-				return fmt.Errorf("execute without TX: %w", err)
+				return err
+			}
+			if contracted {
+				err = runMigration(ctx, db, migration, DirectionDown, TxModeTX, phaseContract, false, config,
+					func(ctx context.Context) error {
+						return executeInTx(ctx, db,
+							migrateUncontract(migration.Version, migration.Contract.Down, config), config.Timeout)
+					})
+				if err != nil {
+					return newMigrationError(migration.Version, DirectionDown, TxModeTX, err)
+				}
+
+				_, _ = fmt.Fprintf(output,
+					"[x]-->[~] Rolled back contract phase of migration version %d\n", migration.Version)
+			}
+		}
+
+		switch {
+		case migration.UpDown != nil:
+			err = runMigration(ctx, db, migration, DirectionDown, TxModeTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeInTx(
+						ctx, db, migrateDown(migration.Version, migration.UpDown.Down, config), config.Timeout)
+				})
+			if err != nil {
+				return newMigrationError(migration.Version, DirectionDown, TxModeTX, err)
+			}
+		case migration.Batched != nil:
+			err = runMigration(ctx, db, migration, DirectionDown, TxModeTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeInTx(
+						ctx, db, migrateDown(migration.Version, migration.Batched.Down, config), config.Timeout)
+				})
+			if err != nil {
+				return newMigrationError(migration.Version, DirectionDown, TxModeTX, err)
+			}
+		default:
+			err = runMigration(ctx, db, migration, DirectionDown, TxModeNoTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeNoTx(
+						ctx, db, migrateDown(migration.Version, migration.UpDownNoTX.Down, config), config.Timeout)
+				})
+			if err != nil {
+				return newMigrationError(migration.Version, DirectionDown, TxModeNoTX, err)
 			}
 		}
 
@@ -60,30 +135,34 @@ func runCmdDown[
 func migrateDown[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
 	version int,
 	down func(ctx context.Context, dbOrTX TDBOrTX) error,
-	timeout time.Duration,
+	config *Config,
 ) func(context.Context, TDBOrTX) error {
 
 	return func(ctx context.Context, dbOrTX TDBOrTX) error {
-		dbVersion, err := getDBVersion(ctx, dbOrTX, timeout)
+		dbVersion, err := getDBVersion(ctx, dbOrTX, config)
 		if err != nil {
-			return err
+			return withPhase(PhaseBookkeeping, err)
 		}
 
 		if version > dbVersion {
-			return fmt.Errorf(
+			return withPhase(PhaseBookkeeping, fmt.Errorf(
 				"%w: migration version %d > current DB version %d",
-				errDBVersionChangedDown, version, dbVersion)
+				ErrDBVersionChangedDown, version, dbVersion))
 		}
 
-		migCtx, cancelMig := context.WithTimeout(ctx, timeout)
+		if err := setSearchPath(ctx, dbOrTX, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		migCtx, cancelMig := context.WithTimeout(ctx, config.Timeout)
 		defer cancelMig()
 		if err := down(migCtx, dbOrTX); err != nil {
-			return fmt.Errorf(
-				"failed to apply migration.down version %d: %w", version, err)
+			return withPhase(PhaseFunc, fmt.Errorf(
+				"failed to apply migration.down version %d: %w", version, err))
 		}
 
-		if err := deleteDBVersion(ctx, dbOrTX, version, timeout); err != nil {
-			return err
+		if err := deleteDBVersion(ctx, dbOrTX, version, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
 		}
 
 		return nil
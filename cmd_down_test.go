@@ -23,7 +23,7 @@ func TestRunCmdDown(t *testing.T) {
 			migrations: createTestMigrations(1, 2),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -44,7 +44,7 @@ func TestRunCmdDown(t *testing.T) {
 			migrations: createTestMigrations(1, 2, 3),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 3
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -68,7 +68,7 @@ func TestRunCmdDown(t *testing.T) {
 			migrations: createTestMigrations(1, 2, 3),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 2
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -92,7 +92,7 @@ func TestRunCmdDown(t *testing.T) {
 			migrations: createTestMigrations(1, 2, 3),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 1
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -115,7 +115,7 @@ func TestRunCmdDown(t *testing.T) {
 			name:       "error getting initial DB version",
 			migrations: createTestMigrations(1),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -131,7 +131,7 @@ func TestRunCmdDown(t *testing.T) {
 			migrations: createTestMigrations(2),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get initial DB version - returns 2
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -151,7 +151,7 @@ func TestRunCmdDown(t *testing.T) {
 					Once()
 
 				// Get DB version in TX - returns 2
-				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -175,7 +175,7 @@ func TestRunCmdDown(t *testing.T) {
 					Return(nil).
 					Once()
 			},
-			wantErr: "execute in TX",
+			wantErr: "migration version 2 down failed (TX, func phase)",
 		},
 		{
 			name: "error during migration execution - no TX",
@@ -196,7 +196,7 @@ func TestRunCmdDown(t *testing.T) {
 			},
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get initial DB version - returns 1
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -211,7 +211,7 @@ func TestRunCmdDown(t *testing.T) {
 					Once()
 
 				// Get DB version for no-TX migration - returns 1
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -230,7 +230,7 @@ func TestRunCmdDown(t *testing.T) {
 					Return(result, errors.New("cannot drop index concurrently in transaction")).
 					Once()
 			},
-			wantErr: "execute without TX",
+			wantErr: "migration version 1 down failed (no TX, func phase)",
 		},
 	}
 
@@ -245,7 +245,7 @@ func TestRunCmdDown(t *testing.T) {
 
 			var output bytes.Buffer
 
-			err := runCmdDown(context.Background(), tc.migrations, db, &output, defaultTimeout)
+			err := runCmdDown(context.Background(), tc.migrations, db, &output, DefaultConfig())
 
 			db.AssertExpectations(t)
 			tx.AssertExpectations(t)
@@ -275,7 +275,7 @@ func TestMigrateDown(t *testing.T) {
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 1
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -295,7 +295,7 @@ func TestMigrateDown(t *testing.T) {
 					Once()
 
 				// Delete version
-				dbOrTX.On("ExecContext", mock.Anything, deleteMigVersionSQL, 1).
+				dbOrTX.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), 1).
 					Return(result, nil).
 					Once()
 			},
@@ -305,7 +305,7 @@ func TestMigrateDown(t *testing.T) {
 			version: 3,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 3
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -325,7 +325,7 @@ func TestMigrateDown(t *testing.T) {
 					Once()
 
 				// Delete version
-				dbOrTX.On("ExecContext", mock.Anything, deleteMigVersionSQL, 3).
+				dbOrTX.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), 3).
 					Return(result, nil).
 					Once()
 			},
@@ -335,7 +335,7 @@ func TestMigrateDown(t *testing.T) {
 			version: 3,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 2
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -356,7 +356,7 @@ func TestMigrateDown(t *testing.T) {
 			version: 5,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 3
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -376,7 +376,7 @@ func TestMigrateDown(t *testing.T) {
 			name:    "error - failed to get DB version",
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -392,7 +392,7 @@ func TestMigrateDown(t *testing.T) {
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 1
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -418,7 +418,7 @@ func TestMigrateDown(t *testing.T) {
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 1
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -438,7 +438,7 @@ func TestMigrateDown(t *testing.T) {
 					Once()
 
 				// Delete version fails
-				dbOrTX.On("ExecContext", mock.Anything, deleteMigVersionSQL, 1).
+				dbOrTX.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), 1).
 					Return(result, errors.New("constraint violation")).
 					Once()
 			},
@@ -462,7 +462,7 @@ func TestMigrateDown(t *testing.T) {
 			}
 
 			// Call migrateDown and execute the returned function
-			migrateFn := migrateDown(tc.version, downFunc, defaultTimeout)
+			migrateFn := migrateDown(tc.version, downFunc, DefaultConfig())
 			err := migrateFn(context.Background(), dbOrTX)
 
 			dbOrTX.AssertExpectations(t)
@@ -494,7 +494,7 @@ func setupMigrationDownMocks(
 		Once()
 
 	// Get DB version in TX
-	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 		Return(row).
 		Once()
 	row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -514,7 +514,7 @@ func setupMigrationDownMocks(
 		Once()
 
 	// Delete version
-	tx.On("ExecContext", mock.Anything, deleteMigVersionSQL, targetVersion).
+	tx.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), targetVersion).
 		Return(result, nil).
 		Once()
 
@@ -523,3 +523,135 @@ func setupMigrationDownMocks(
 		Return(nil).
 		Once()
 }
+
+func TestRunCmdDownTags(t *testing.T) {
+	testCases := []struct {
+		name       string
+		dbTags     string
+		wantDown   bool
+		wantOutput string
+	}{
+		{
+			name:       "migration applied with its tag",
+			dbTags:     "test",
+			wantDown:   true,
+			wantOutput: "[x]-->[ ] Rolled back migration version 2\n",
+		},
+		{
+			name:       "migration applied without tag selector",
+			dbTags:     "",
+			wantDown:   true,
+			wantOutput: "[x]-->[ ] Rolled back migration version 2\n",
+		},
+		{
+			name:       "migration skipped",
+			dbTags:     "prod",
+			wantOutput: "[-]-->[ ] Rolled back skipped migration version 2\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations := createTestMigrations(1, 2)
+			migrations[1].Tags = []string{"test"}
+
+			db := new(dbMock)
+			tx := new(txMock)
+			db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(2)).
+				Once()
+			tagsRow := new(dbRowMock)
+			db.On("QueryRowContext", mock.Anything, selectMigTagsSQL(migrationsTableName), 2).
+				Return(tagsRow).
+				Once()
+			tagsRow.On("Scan", mock.Anything).
+				Run(func(args mock.Arguments) {
+					*(args.Get(0).([]any)[0].(*string)) = tc.dbTags
+				}).
+				Return(nil).
+				Once()
+
+			db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+			tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(2)).
+				Once()
+			if tc.wantDown {
+				tx.On("ExecContext", mock.Anything, "DROP TABLE test").
+					Return(new(dbResultMock), nil).
+					Once()
+			}
+			tx.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), 2).
+				Return(new(dbResultMock), nil).
+				Once()
+			tx.On("Commit").Return(nil).Once()
+
+			var output bytes.Buffer
+			err := runCmdDown(context.Background(), migrations, db, &output, DefaultConfig())
+			require.NoError(t, err)
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+			require.Equal(t, tc.wantOutput, output.String())
+		})
+	}
+}
+
+func TestRunCmdDownIrreversible(t *testing.T) {
+	migrations := createTestMigrations(1, 2)
+	migrations[1].Irreversible = true
+	migrations[1].UpDown.Down = nil
+
+	// Only the DB version is read: nothing is touched before refusing.
+	db := new(dbMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(2)).
+		Once()
+
+	var output bytes.Buffer
+	err := runCmdDown(context.Background(), migrations, db, &output, DefaultConfig())
+	require.ErrorIs(t, err, ErrIrreversibleMigration)
+	require.EqualError(t, err, "an irreversible migration can't be rolled back: version 2")
+	require.Empty(t, output.String())
+	db.AssertExpectations(t)
+}
+
+func TestRunCmdDownDBAhead(t *testing.T) {
+	// Version 3 is applied, but unknown to the code.
+	db := new(dbMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(3)).
+		Once()
+
+	var output bytes.Buffer
+	err := runCmdDown(context.Background(), createTestMigrations(1, 2), db, &output, DefaultConfig())
+	require.ErrorIs(t, err, ErrDBAhead)
+	require.EqualError(t, err, "database ahead of the code, unknown migrations applied: "+
+		"database version 3 > version 2; deploy a release which has them, or force the command (--force)")
+	require.Empty(t, output.String())
+	db.AssertExpectations(t)
+
+	// Forced, the latest known migration is rolled back.
+	db = new(dbMock)
+	tx := new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(3)).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(3)).
+		Once()
+	tx.On("ExecContext", mock.Anything, "DROP TABLE test").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), 2).
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("Commit").Return(nil).Once()
+
+	config := DefaultConfig()
+	config.AllowUnknownVersions = true
+	err = runCmdDown(context.Background(), createTestMigrations(1, 2), db, &output, config)
+	require.NoError(t, err)
+	require.Equal(t, "[x]-->[ ] Rolled back migration version 2\n", output.String())
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
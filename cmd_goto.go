@@ -0,0 +1,104 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// runCmdGoto migrates the database to the given version (0 rolls all the
+// migrations back): it applies the pending migrations up to it, as up does
+// (only their expand phase), or rolls back the applied ones
+// above it, one at a time, as down does. Before changing anything, it refuses
+// to roll back past an irreversible or baseline migration. It returns the
+// number of migrations applied or rolled back.
+func runCmdGoto[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	target int,
+	config *Config,
+) (int, error) {
+
+	if usesGraph(migrations) {
+		return 0, errors.New("can't go to a version: migrations with modules or dependencies " +
+			"have no database version")
+	}
+
+	isTarget := func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool { return m.Version == target }
+	if target != 0 && !slices.ContainsFunc(migrations, isTarget) {
+		return 0, fmt.Errorf("unknown migration version %d", target)
+	}
+
+	dbVersion, err := getDBVersion(ctx, db, config)
+	if err != nil {
+		return 0, err
+	}
+
+	if target > dbVersion {
+		var upTo []Migration[TDBRow, TDBResult, TTX, TTXO, TDB]
+		for _, migration := range migrations {
+			if migration.Version <= target {
+				upTo = append(upTo, migration)
+			}
+		}
+		return runCmdUp(ctx, upTo, db, output, 0, config)
+	}
+
+	if target == dbVersion {
+		_, _ = fmt.Fprintf(output, "Already at version %d\n", target)
+		return 0, nil
+	}
+
+	migrations = slices.Clone(migrations)
+	sortMigrationsDesc(migrations)
+
+	// The known migrations which aren't applied are skipped: down rolls back
+	// the last applied one each time.
+	applied, err := getAppliedVersions(ctx, db, false, config)
+	if err != nil {
+		return 0, err
+	}
+	var toRollBack []Migration[TDBRow, TDBResult, TTX, TTXO, TDB]
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok && migration.Version > target {
+			toRollBack = append(toRollBack, migration)
+		}
+	}
+
+	highestKnown := target
+	if len(toRollBack) > 0 {
+		highestKnown = toRollBack[0].Version
+	}
+	if err := checkDBAhead(dbVersion, highestKnown, config); err != nil {
+		return 0, err
+	}
+
+	for _, migration := range toRollBack {
+		if migration.Baseline {
+			return 0, fmt.Errorf("%w: version %d", ErrBaselineRollback, migration.Version)
+		}
+		if migration.Irreversible {
+			return 0, fmt.Errorf("%w: version %d", ErrIrreversibleMigration, migration.Version)
+		}
+	}
+
+	for range toRollBack {
+		if err := runCmdDown(ctx, migrations, db, output, config); err != nil {
+			return 0, err
+		}
+	}
+
+	_, _ = fmt.Fprintf(output, "%d migration(s) rolled back\n", len(toRollBack))
+
+	return len(toRollBack), nil
+}
@@ -0,0 +1,172 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCmdGoto(t *testing.T) {
+	irreversible := createTestMigrations(1, 2, 3)
+	irreversible[1].Irreversible = true
+	irreversible[1].UpDown.Down = nil
+
+	graph := createTestMigrations(1, 2)
+	graph[0].Module, graph[1].Module = "billing", "billing"
+
+	testCases := []struct {
+		name       string
+		migrations []migrationMock
+		target     int
+		setupMock  func(*dbMock, *txMock)
+		wantOut    string
+		wantCount  int
+		wantErr    string
+		wantErrIs  error
+	}{
+		{
+			name:       "up to a version",
+			migrations: createTestMigrations(1, 2, 3),
+			target:     2,
+			setupMock: func(db *dbMock, tx *txMock) {
+				for range 2 { // read by goto, then by up or down
+					db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+						Return(dbVersionRow(0)).
+						Once()
+				}
+				setupMigrationUpMocks(db, tx, new(dbRowMock), new(dbResultMock), 0, 1)
+				setupMigrationUpMocks(db, tx, new(dbRowMock), new(dbResultMock), 1, 2)
+			},
+			wantOut: "[x] Applied migration version 1\n" +
+				"[x] Applied migration version 2\n" +
+				"2 migration(s) applied\n",
+			wantCount: 2,
+		},
+		{
+			name:       "down to a version",
+			migrations: createTestMigrations(1, 2, 3),
+			target:     1,
+			setupMock: func(db *dbMock, tx *txMock) {
+				for range 2 { // read by goto, then by up or down
+					db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+						Return(dbVersionRow(3)).
+						Once()
+				}
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(appliedVersionsRow(1, 2, 3)).
+					Once()
+				setupMigrationDownMocks(db, tx, new(dbRowMock), new(dbResultMock), 3, 3)
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+				setupMigrationDownMocks(db, tx, new(dbRowMock), new(dbResultMock), 2, 2)
+			},
+			wantOut: "[x]-->[ ] Rolled back migration version 3\n" +
+				"[x]-->[ ] Rolled back migration version 2\n" +
+				"2 migration(s) rolled back\n",
+			wantCount: 2,
+		},
+		{
+			name:       "down to a version, past migrations not applied",
+			migrations: createTestMigrations(1, 2, 3, 4),
+			target:     2,
+			setupMock: func(db *dbMock, tx *txMock) {
+				for range 2 { // read by goto, then by down
+					db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+						Return(dbVersionRow(4)).
+						Once()
+				}
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(appliedVersionsRow(1, 2, 4)).
+					Once()
+				setupMigrationDownMocks(db, tx, new(dbRowMock), new(dbResultMock), 4, 4)
+			},
+			wantOut: "[x]-->[ ] Rolled back migration version 4\n" +
+				"1 migration(s) rolled back\n",
+			wantCount: 1,
+		},
+		{
+			name:       "already at the version",
+			migrations: createTestMigrations(1, 2),
+			target:     2,
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+			},
+			wantOut: "Already at version 2\n",
+		},
+		{
+			name:       "unknown version",
+			migrations: createTestMigrations(1, 2),
+			target:     5,
+			setupMock:  func(db *dbMock, tx *txMock) {},
+			wantErr:    "unknown migration version 5",
+		},
+		{
+			name:       "irreversible migration on the way down",
+			migrations: irreversible,
+			target:     1,
+			setupMock: func(db *dbMock, tx *txMock) {
+				// Nothing is rolled back, not even version 3.
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(3)).
+					Once()
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(appliedVersionsRow(1, 2, 3)).
+					Once()
+			},
+			wantErr:   "an irreversible migration can't be rolled back: version 2",
+			wantErrIs: ErrIrreversibleMigration,
+		},
+		{
+			name:       "unknown versions applied above",
+			migrations: createTestMigrations(1, 2, 3),
+			target:     1,
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(4)).
+					Once()
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(appliedVersionsRow(1, 2, 3, 4)).
+					Once()
+			},
+			wantErr:   "database version 4 > version 3",
+			wantErrIs: ErrDBAhead,
+		},
+		{
+			name:       "migrations with modules",
+			migrations: graph,
+			target:     1,
+			setupMock:  func(db *dbMock, tx *txMock) {},
+			wantErr:    "can't go to a version: migrations with modules or dependencies have no database version",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			tc.setupMock(db, tx)
+
+			var output bytes.Buffer
+			count, err := runCmdGoto(context.Background(), tc.migrations, db, &output, tc.target, DefaultConfig())
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				if tc.wantErrIs != nil {
+					require.ErrorIs(t, err, tc.wantErrIs)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, output.String())
+			require.Equal(t, tc.wantCount, count)
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package gosmig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// runCmdPlan writes what up (with the given --phase, if any: the expand phase
+// by default) would do, in order, without changing the database: the migrations it would apply or skip
+// by tags, the contract phases and the repeatable migrations. It fails if up
+// would refuse to run. It returns the number of steps up would apply.
+func runCmdPlan[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	phase string,
+	config *Config,
+) (int, error) {
+
+	var steps []string
+
+	if usesGraph(migrations) {
+		graphSteps, err := planGraphUp(ctx, migrations, db, config)
+		if err != nil {
+			return 0, err
+		}
+		steps = append(steps, graphSteps...)
+	} else {
+		migrations = slices.Clone(migrations)
+		sortMigrationsAsc(migrations)
+
+		dbVersion, err := getDBVersion(ctx, db, config)
+		if err != nil {
+			return 0, err
+		}
+
+		if phase != phaseContract {
+			expandSteps, err := planUp(ctx, migrations, db, dbVersion, config)
+			if err != nil {
+				return 0, err
+			}
+			steps = append(steps, expandSteps...)
+		} else {
+			contractSteps, err := planContractPhase(ctx, migrations, db, dbVersion, config)
+			if err != nil {
+				return 0, err
+			}
+			steps = append(steps, contractSteps...)
+		}
+	}
+
+	repeatableSteps, err := planRepeatables(ctx, repeatables, db, config)
+	if err != nil {
+		return 0, err
+	}
+	steps = append(steps, repeatableSteps...)
+
+	if len(steps) == 0 {
+		_, _ = fmt.Fprintln(output, "No migrations to apply")
+		return 0, nil
+	}
+
+	for _, step := range steps {
+		_, _ = fmt.Fprintln(output, step)
+	}
+	_, _ = fmt.Fprintf(output, "%d step(s) planned\n", len(steps))
+
+	return len(steps), nil
+}
+
+// planUp returns the steps of runCmdUp: the pending migrations, in ascending
+// order of version, to apply or to skip by tags.
+func planUp[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	dbVersion int,
+	config *Config,
+) ([]string, error) {
+
+	if err := checkSquashedRange(dbVersion, latestBaselineVersion(migrations)); err != nil {
+		return nil, err
+	}
+
+	if err := checkDBAhead(dbVersion, migrations[len(migrations)-1].Version, config); err != nil {
+		return nil, err
+	}
+
+	var steps []string
+	for _, migration := range migrations {
+		if migration.Version <= dbVersion {
+			continue
+		}
+
+		if !selectedByTags(migration.Tags, config.Tags) {
+			steps = append(steps, fmt.Sprintf("[-] Skip migration version %d (tags: %s; active tags: %s)",
+				migration.Version, formatTags(migration.Tags), formatTags(config.Tags)))
+			continue
+		}
+
+		var notes []string
+		switch {
+		case migration.UpDownNoTX != nil:
+			notes = append(notes, "no transaction")
+		case migration.Batched != nil:
+			notes = append(notes, "batched")
+			_, nbBatches, err := getBatchCursor(ctx, db, migration.Version, config)
+			if err != nil {
+				return nil, err
+			}
+			if nbBatches > 0 {
+				notes = append(notes, fmt.Sprintf("in progress: %d batch(es) applied", nbBatches))
+			}
+		}
+		if migration.Contract != nil {
+			notes = append(notes, "expand phase")
+		}
+		if migration.Irreversible {
+			notes = append(notes, "irreversible")
+		}
+
+		steps = append(steps, planStep(fmt.Sprintf("migration version %d", migration.Version), notes))
+	}
+
+	return steps, nil
+}
+
+// planContractPhase returns the steps of runContractPhase: the contract
+// phases of the applied migrations whose contract phase is pending.
+func planContractPhase[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	dbVersion int,
+	config *Config,
+) ([]string, error) {
+
+	var steps []string
+	for _, migration := range migrations {
+		if migration.Contract == nil || migration.Version > dbVersion {
+			continue
+		}
+
+		if len(migration.Tags) > 0 {
+			tags, err := getMigrationTags(ctx, db, migration.Version, config)
+			if err != nil {
+				return nil, err
+			}
+			if !selectedByTags(migration.Tags, tags) {
+				continue
+			}
+		}
+
+		contracted, err := isMigrationContracted(ctx, db, migration.Version, config)
+		if err != nil {
+			return nil, err
+		}
+		if contracted {
+			continue
+		}
+
+		steps = append(steps, planStep(
+			fmt.Sprintf("contract phase of migration version %d", migration.Version), nil))
+	}
+
+	return steps, nil
+}
+
+// planGraphUp returns the steps of runGraphUp: the pending migrations with
+// modules or dependencies, in topological order.
+func planGraphUp[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) ([]string, error) {
+
+	ordered, err := sortMigrationsGraph(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := getAppliedIDs(ctx, db, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []string
+	for _, migration := range ordered {
+		if applied[migration.ID()] {
+			continue
+		}
+
+		var notes []string
+		if migration.UpDownNoTX != nil {
+			notes = append(notes, "no transaction")
+		}
+		if migration.Irreversible {
+			notes = append(notes, "irreversible")
+		}
+
+		steps = append(steps, planStep("migration "+migration.ID(), notes))
+	}
+
+	return steps, nil
+}
+
+// planRepeatables returns the steps of runRepeatables: the repeatable
+// migrations which were never applied or whose checksum changed, by name.
+func planRepeatables[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) ([]string, error) {
+
+	repeatables = slices.Clone(repeatables)
+	sortRepeatables(repeatables)
+
+	var steps []string
+	for _, repeatable := range repeatables {
+		status, err := getRepeatableStatus(ctx, db, repeatable.Name, repeatable.Checksum, config)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case repeatablePending:
+			steps = append(steps, planStep("repeatable migration "+repeatable.Name, nil))
+		case repeatableChanged:
+			steps = append(steps, planStep("repeatable migration "+repeatable.Name, []string{"changed"}))
+		}
+	}
+
+	return steps, nil
+}
+
+// planStep returns the line of a step applying what, with the given notes.
+func planStep(what string, notes []string) string {
+	step := "[ ] Apply " + what
+	if len(notes) > 0 {
+		step += " (" + strings.Join(notes, "; ") + ")"
+	}
+	return step
+}
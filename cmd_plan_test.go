@@ -0,0 +1,175 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCmdPlan(t *testing.T) {
+	migrations := []migrationMock{
+		createTestContractMigration(1, nil),
+		createTestMigrations(2)[0],
+		{
+			Version: 3,
+			UpDownNoTX: &UpDown[*dbRowMock, *dbResultMock, *dbMock]{
+				Up:   func(ctx context.Context, db *dbMock) error { return nil },
+				Down: func(ctx context.Context, db *dbMock) error { return nil },
+			},
+		},
+		createTestContractMigration(4, nil),
+		createTestBatchedMigration(5, ""),
+		createTestMigrations(6)[0],
+	}
+	migrations[1].Tags = []string{"test"}
+	migrations[5].Irreversible = true
+	migrations[5].UpDown.Down = nil
+
+	repeatables := []repeatableMock{
+		createTestRepeatable("c_view", "abc"),
+		createTestRepeatable("b_func", "new"),
+		createTestRepeatable("a_view", "abc"),
+	}
+
+	graph := createTestMigrations(1, 2)
+	graph[0].Module, graph[1].Module = "billing", "billing"
+
+	testCases := []struct {
+		name        string
+		migrations  []migrationMock
+		repeatables []repeatableMock
+		phase       string
+		setupMock   func(*dbMock)
+		wantOut     string
+		wantCount   int
+		wantErr     string
+		wantErrIs   error
+	}{
+		{
+			name:        "expand phase by default",
+			migrations:  migrations,
+			repeatables: repeatables,
+			setupMock: func(db *dbMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(1)).
+					Once()
+				setupBatchCursorMock(db, 5, "20", 2)
+				setupRepeatableChecksumMock(db, "a_view", "")
+				setupRepeatableChecksumMock(db, "b_func", "old")
+				setupRepeatableChecksumMock(db, "c_view", "abc")
+			},
+			wantOut: "[-] Skip migration version 2 (tags: test; active tags: prod)\n" +
+				"[ ] Apply migration version 3 (no transaction)\n" +
+				"[ ] Apply migration version 4 (expand phase)\n" +
+				"[ ] Apply migration version 5 (batched; in progress: 2 batch(es) applied)\n" +
+				"[ ] Apply migration version 6 (irreversible)\n" +
+				"[ ] Apply repeatable migration a_view\n" +
+				"[ ] Apply repeatable migration b_func (changed)\n" +
+				"7 step(s) planned\n",
+			wantCount: 7,
+		},
+		{
+			name:       "contract phase only",
+			migrations: migrations,
+			phase:      phaseContract,
+			setupMock: func(db *dbMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(1)).
+					Once()
+				db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+					Return(contractedRow(false)).
+					Once()
+			},
+			wantOut: "[ ] Apply contract phase of migration version 1\n" +
+				"1 step(s) planned\n",
+			wantCount: 1,
+		},
+		{
+			name:       "expand phase only",
+			migrations: createTestMigrations(1, 2),
+			phase:      phaseExpand,
+			setupMock: func(db *dbMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(1)).
+					Once()
+			},
+			wantOut: "[ ] Apply migration version 2\n" +
+				"1 step(s) planned\n",
+			wantCount: 1,
+		},
+		{
+			name:       "nothing to apply",
+			migrations: createTestMigrations(1, 2),
+			setupMock: func(db *dbMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+			},
+			wantOut: "No migrations to apply\n",
+		},
+		{
+			name:       "unknown versions applied",
+			migrations: createTestMigrations(1, 2),
+			setupMock: func(db *dbMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(3)).
+					Once()
+			},
+			wantErr:   "database version 3 > version 2",
+			wantErrIs: ErrDBAhead,
+		},
+		{
+			name:       "migrations with modules",
+			migrations: graph,
+			setupMock: func(db *dbMock) {
+				db.On("QueryRowContext", mock.Anything, selectAppliedIDsSQL(graphTableName(migrationsTableName))).
+					Return(appliedIDsRow("billing/1")).
+					Once()
+			},
+			wantOut: "[ ] Apply migration billing/2\n" +
+				"1 step(s) planned\n",
+			wantCount: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tc.setupMock(db)
+
+			config := DefaultConfig()
+			config.Tags = []string{"prod"}
+
+			var output bytes.Buffer
+			count, err := runCmdPlan(
+				context.Background(), tc.migrations, tc.repeatables, db, &output, tc.phase, config)
+			db.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				if tc.wantErrIs != nil {
+					require.ErrorIs(t, err, tc.wantErrIs)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, output.String())
+			require.Equal(t, tc.wantCount, count)
+		})
+	}
+
+	t.Run("doesn't reorder the migrations", func(t *testing.T) {
+		unordered := createTestMigrations(2, 1)
+		db := new(dbMock)
+		db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(dbVersionRow(0)).
+			Once()
+
+		_, err := runCmdPlan(context.Background(), unordered, nil, db, new(bytes.Buffer), "", DefaultConfig())
+		require.NoError(t, err)
+		require.Equal(t, []int{2, 1}, []int{unordered[0].Version, unordered[1].Version})
+	})
+}
@@ -0,0 +1,137 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// runCmdSquash replaces the migrations up to version upto with a single
+// baseline migration, written as SQL files in config.MigrationsDir (the
+// current directory if not set). The baseline is the schema of the database,
+// dumped with config.SchemaDumper, so the database must be at version upto.
+// The squashed SQL migration files are removed, and the gosmig.sum file (if
+// any) is updated. Squashed Go migrations have to be removed by hand.
+func runCmdSquash[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	dbURL string,
+	output io.Writer,
+	config *Config,
+	upto int,
+) error {
+
+	if usesGraph(migrations) {
+		return errors.New("squash doesn't support migrations with modules or dependencies")
+	}
+
+	if config.SchemaDumper == nil {
+		return errors.New("squash needs a schema dumper (Config.SchemaDumper)")
+	}
+
+	if upto <= 0 {
+		return errors.New("squash needs the version to squash up to (--upto <version>)")
+	}
+
+	if !slices.ContainsFunc(migrations, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return m.Version == upto
+	}) {
+		return fmt.Errorf("no migration with version %d", upto)
+	}
+
+	if baselineVersion := latestBaselineVersion(migrations); baselineVersion >= upto {
+		return fmt.Errorf(
+			"version %d is already squashed into baseline %d", upto, baselineVersion)
+	}
+
+	dbVersion, err := getDBVersion(ctx, db, config)
+	if err != nil {
+		return err
+	}
+	if dbVersion != upto {
+		return fmt.Errorf(
+			"the database must be at version %d to squash up to it, but it is at version %d "+
+				"(use a scratch database)", upto, dbVersion)
+	}
+
+	schema, err := config.SchemaDumper.DumpSchema(ctx, dbURL, trackingTables(config))
+	if err != nil {
+		return err
+	}
+
+	dir := config.MigrationsDir
+	if dir == "" {
+		dir = "."
+	}
+
+	files, _, err := readSQLMigrationFiles(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+
+	baseName := migrationFileVersion(upto, config.Versioning) + "_baseline"
+	upContent := fmt.Sprintf(
+		"%s\n-- Schema of the migrations up to version %d, squashed.\n\n%s",
+		baselineDirective, upto, schema)
+	downContent := "-- A baseline can't be rolled back.\n"
+
+	for _, file := range []struct{ name, content string }{
+		{baseName + ".up.sql", upContent},
+		{baseName + ".down.sql", downContent},
+	} {
+		path := filepath.Join(dir, file.name)
+		if err := createMigrationFile(path, []byte(file.content)); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(output, "Created %s\n", path)
+	}
+
+	sqlVersions := make(map[int]bool)
+	for _, file := range files {
+		if file.version > upto {
+			continue
+		}
+		sqlVersions[file.version] = true
+		path := filepath.Join(dir, file.name)
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove squashed migration file: %w", err)
+		}
+		_, _ = fmt.Fprintf(output, "Removed %s\n", path)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, sumFileName)); err == nil {
+		if err := WriteSumFile(dir); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(output, "Updated %s\n", filepath.Join(dir, sumFileName))
+	}
+
+	var goVersions []string
+	for _, migration := range migrations {
+		if migration.Version <= upto && !sqlVersions[migration.Version] {
+			goVersions = append(goVersions, strconv.Itoa(migration.Version))
+		}
+	}
+	if len(goVersions) > 0 {
+		_, _ = fmt.Fprintf(output,
+			"Remove the squashed Go migration(s) from the code: %s\n",
+			strings.Join(goVersions, ", "))
+	}
+
+	_, _ = fmt.Fprintf(output, "Squashed migrations up to version %d into a baseline\n", upto)
+
+	return nil
+}
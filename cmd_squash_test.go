@@ -0,0 +1,270 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSchemaDumper struct {
+	schema         string
+	err            error
+	gotURL         string
+	gotExcludeTbls []string
+}
+
+func (d *fakeSchemaDumper) DumpSchema(
+	ctx context.Context, dbURL string, excludeTables []string,
+) (string, error) {
+
+	d.gotURL = dbURL
+	d.gotExcludeTbls = excludeTables
+	return d.schema, d.err
+}
+
+func TestRunCmdSquash(t *testing.T) {
+	sqlMigrationFiles := map[string]string{
+		"001_create_t.up.sql":   "CREATE TABLE t (c INT);",
+		"001_create_t.down.sql": "DROP TABLE t;",
+		"002_add_d.up.sql":      "ALTER TABLE t ADD COLUMN d INT;",
+		"002_add_d.down.sql":    "ALTER TABLE t DROP COLUMN d;",
+		"004_add_e.up.sql":      "ALTER TABLE t ADD COLUMN e INT;",
+		"004_add_e.down.sql":    "ALTER TABLE t DROP COLUMN e;",
+	}
+
+	testCases := []struct {
+		name          string
+		migrations    []migrationMock
+		dbVersion     int
+		dumper        *fakeSchemaDumper
+		upto          int
+		withSumFile   bool
+		wantFiles     []string
+		wantOutput    []string
+		wantErr       string
+		wantNoDBCalls bool
+	}{
+		{
+			name:        "squashes SQL and Go migrations",
+			migrations:  createTestMigrations(1, 2, 3, 4),
+			dbVersion:   3,
+			dumper:      &fakeSchemaDumper{schema: "CREATE TABLE t (c INT, d INT);\n"},
+			upto:        3,
+			withSumFile: true,
+			wantFiles: []string{
+				"003_baseline.down.sql",
+				"003_baseline.up.sql",
+				"004_add_e.down.sql",
+				"004_add_e.up.sql",
+				"gosmig.sum",
+			},
+			wantOutput: []string{
+				"Removed {dir}/001_create_t.down.sql\n",
+				"Removed {dir}/002_add_d.up.sql\n",
+				"Updated {dir}/gosmig.sum\n",
+				"Remove the squashed Go migration(s) from the code: 3\n",
+				"Squashed migrations up to version 3 into a baseline\n",
+			},
+		},
+		{
+			name:          "no schema dumper",
+			migrations:    createTestMigrations(1),
+			upto:          1,
+			wantErr:       "squash needs a schema dumper (Config.SchemaDumper)",
+			wantNoDBCalls: true,
+		},
+		{
+			name:          "missing --upto",
+			migrations:    createTestMigrations(1),
+			dumper:        &fakeSchemaDumper{},
+			wantErr:       "squash needs the version to squash up to (--upto <version>)",
+			wantNoDBCalls: true,
+		},
+		{
+			name:          "unknown version",
+			migrations:    createTestMigrations(1, 2),
+			dumper:        &fakeSchemaDumper{},
+			upto:          5,
+			wantErr:       "no migration with version 5",
+			wantNoDBCalls: true,
+		},
+		{
+			name: "already squashed",
+			migrations: func() []migrationMock {
+				migrations := createTestMigrations(3, 4)
+				migrations[0].Baseline = true
+				return migrations
+			}(),
+			dumper:        &fakeSchemaDumper{},
+			upto:          3,
+			wantErr:       "version 3 is already squashed into baseline 3",
+			wantNoDBCalls: true,
+		},
+		{
+			name:       "database not at the version",
+			migrations: createTestMigrations(1, 2, 3, 4),
+			dbVersion:  4,
+			dumper:     &fakeSchemaDumper{},
+			upto:       3,
+			wantErr: "the database must be at version 3 to squash up to it, " +
+				"but it is at version 4 (use a scratch database)",
+		},
+		{
+			name:       "dump error",
+			migrations: createTestMigrations(1, 2, 3, 4),
+			dbVersion:  3,
+			dumper:     &fakeSchemaDumper{err: errors.New("pg_dump not found")},
+			upto:       3,
+			wantErr:    "pg_dump not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range sqlMigrationFiles {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+			if tc.withSumFile {
+				require.NoError(t, WriteSumFile(dir))
+			}
+
+			db := new(dbMock)
+			row := new(dbRowMock)
+			if !tc.wantNoDBCalls {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(row).
+					Once()
+				row.On("Scan", mock.Anything).
+					Run(func(args mock.Arguments) {
+						*(args.Get(0).([]any)[0].(*int)) = tc.dbVersion
+					}).
+					Return(nil).
+					Once()
+			}
+
+			config := DefaultConfig()
+			config.MigrationsDir = dir
+			if tc.dumper != nil {
+				config.SchemaDumper = tc.dumper
+			}
+
+			var out strings.Builder
+			err := runCmdSquash(
+				context.Background(), tc.migrations, db, "postgres://localhost/db",
+				&out, config, tc.upto)
+
+			db.AssertExpectations(t)
+			row.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			var gotFiles []string
+			for _, entry := range entries {
+				gotFiles = append(gotFiles, entry.Name())
+			}
+			require.Equal(t, tc.wantFiles, gotFiles)
+
+			for _, want := range tc.wantOutput {
+				require.Contains(t, out.String(), strings.ReplaceAll(want, "{dir}", dir))
+			}
+
+			require.Equal(t, "postgres://localhost/db", tc.dumper.gotURL)
+			require.Equal(t,
+				[]string{
+					"gosmig", "gosmig_lock", "gosmig_repeatable", "gosmig_seed", "gosmig_batch",
+					"gosmig_graph", "gosmig_history"},
+				tc.dumper.gotExcludeTbls)
+
+			// The baseline is loaded as such and matches the sum file.
+			migrations, err := LoadSQLMigrationsSQL(os.DirFS(dir))
+			require.NoError(t, err)
+			require.Len(t, migrations, 2)
+			require.Equal(t, 3, migrations[0].Version)
+			require.True(t, migrations[0].Baseline)
+			require.NotNil(t, migrations[0].UpDown)
+			require.False(t, migrations[1].Baseline)
+
+			report := Validate(migrations, os.DirFS(dir), nil)
+			require.Empty(t, report.Errors)
+
+			upContent, err := os.ReadFile(filepath.Join(dir, "003_baseline.up.sql"))
+			require.NoError(t, err)
+			require.Equal(t,
+				"-- gosmig:baseline\n"+
+					"-- Schema of the migrations up to version 3, squashed.\n\n"+
+					tc.dumper.schema,
+				string(upContent))
+		})
+	}
+}
+
+func TestRunCmdUpBaseline(t *testing.T) {
+	migrations := createTestMigrations(3, 4)
+	migrations[0].Baseline = true
+
+	setupGetDBVersion := func(db *dbMock, row *dbRowMock, version int) {
+		db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(row).
+			Once()
+		row.On("Scan", mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(0).([]any)[0].(*int)) = version
+			}).
+			Return(nil).
+			Once()
+	}
+
+	t.Run("database inside the squashed range", func(t *testing.T) {
+		db := new(dbMock)
+		setupGetDBVersion(db, new(dbRowMock), 2)
+
+		_, err := runCmdUp(context.Background(), migrations, db, new(strings.Builder), 0, DefaultConfig())
+		require.ErrorIs(t, err, ErrSquashedRange)
+		require.ErrorContains(t, err, "database version 2 < baseline version 3")
+		db.AssertExpectations(t)
+	})
+
+	t.Run("database past the baseline considers it applied", func(t *testing.T) {
+		db := new(dbMock)
+		setupGetDBVersion(db, new(dbRowMock), 4)
+
+		var out strings.Builder
+		_, err := runCmdUp(context.Background(), migrations, db, &out, 0, DefaultConfig())
+		require.NoError(t, err)
+		require.Equal(t, "No migrations to apply\n", out.String())
+	})
+}
+
+func TestRunCmdDownBaseline(t *testing.T) {
+	migrations := createTestMigrations(3)
+	migrations[0].Baseline = true
+
+	db := new(dbMock)
+	row := new(dbRowMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(row).
+		Once()
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*int)) = 3
+		}).
+		Return(nil).
+		Once()
+
+	err := runCmdDown(context.Background(), migrations, db, new(strings.Builder), DefaultConfig())
+	require.ErrorIs(t, err, ErrBaselineRollback)
+	db.AssertExpectations(t)
+}
@@ -6,8 +6,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
-	"time"
 
 	"golang.org/x/term"
 )
@@ -21,17 +21,11 @@ func runCmdStatus[
 
 	ctx context.Context,
 	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB],
 	db TDB,
 	output io.Writer,
-	timeout time.Duration,
-) error {
-
-	sortMigrationsDesc(migrations)
-
-	dbVersion, err := getDBVersion(ctx, db, timeout)
-	if err != nil {
-		return err
-	}
+	config *Config,
+) (int, error) {
 
 	w := output
 	if output == os.Stdout { // coverage-ignore
@@ -44,16 +38,177 @@ func runCmdStatus[
 		}()
 	}
 
-	_, _ = fmt.Fprintf(w, "%-10s %-12s\n", "VERSION", "STATUS")
+	writeStatus := writeMigrationsStatus[TDBRow, TDBResult, TTX, TTXO, TDB]
+	if usesGraph(migrations) {
+		writeStatus = writeGraphStatus[TDBRow, TDBResult, TTX, TTXO, TDB]
+	}
+	nbPending, err := writeStatus(ctx, migrations, db, w, config)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(repeatables) > 0 {
+		sortRepeatables(repeatables)
+
+		nameWidth := len("REPEATABLE")
+		for _, repeatable := range repeatables {
+			nameWidth = max(nameWidth, len(repeatable.Name))
+		}
+
+		// None was applied if their table doesn't exist yet.
+		exists, err := tableExists(ctx, db, repeatableTableName(config.migrationsTable()), config)
+		if err != nil {
+			return 0, err
+		}
+
+		_, _ = fmt.Fprintf(w, "\n%-*s %-12s\n", nameWidth, "REPEATABLE", "STATUS")
+		for _, repeatable := range repeatables {
+			status := repeatablePending
+			if exists {
+				status, err = getRepeatableStatus(ctx, db, repeatable.Name, repeatable.Checksum, config)
+				if err != nil {
+					return 0, err
+				}
+			}
+			if status != repeatableApplied {
+				nbPending++
+			}
+			_, _ = fmt.Fprintf(w, "%-*s %-12s\n", nameWidth, repeatable.Name, status)
+		}
+	}
+
+	return nbPending, nil
+}
+
+// writeMigrationsStatus writes the status of the migrations, in descending
+// order of version, along with the applied versions unknown to the code, and
+// returns the number of pending ones.
+func writeMigrationsStatus[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	w io.Writer,
+	config *Config,
+) (int, error) {
+
+	sortMigrationsDesc(migrations)
+
+	applied, columns, err := readAppliedVersions(ctx, db, false, config)
+	if err != nil {
+		return 0, err
+	}
+	dbVersion := maxAppliedVersion(applied)
+	unknown := unknownVersions(applied, migrations)
+
+	// No batches were applied if their table doesn't exist yet.
+	var batchesExist bool
+	if hasBatched(migrations) {
+		batchesExist, err = tableExists(ctx, db, batchTableName(config.migrationsTable()), config)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Timestamp versions are wider and get a column with their time.
+	timestamps := config.Versioning == VersioningTimestamp
+	versionWidth := 10
+	if timestamps {
+		versionWidth = len(timestampVersionLayout)
+	}
+
+	var nbPending int
+	if timestamps {
+		_, _ = fmt.Fprintf(w, "%-*s %-12s %s\n", versionWidth, "VERSION", "STATUS", "TIME")
+	} else {
+		_, _ = fmt.Fprintf(w, "%-*s %-12s\n", versionWidth, "VERSION", "STATUS")
+	}
+	// writeUnknown writes the unknown versions above the given one.
+	writeUnknown := func(above int) {
+		for len(unknown) > 0 && unknown[len(unknown)-1] > above {
+			version := unknown[len(unknown)-1]
+			unknown = unknown[:len(unknown)-1]
+
+			line := fmt.Sprintf("%-*d %-12s", versionWidth, version, "[?] UNKNOWN")
+			if timestamps {
+				line += " " + formatVersionTime(version, config.Versioning)
+			}
+			_, _ = fmt.Fprintln(w, strings.TrimRight(line, " ")+" (applied, but unknown to the code)")
+		}
+	}
 	for _, migration := range migrations {
+		writeUnknown(migration.Version)
+
 		status := "[ ] PENDING"
+		var notes []string
 		if migration.Version <= dbVersion {
 			status = "[x] APPLIED"
+			// The columns missing in tables created by older releases (until
+			// a command adds them) hold no tags and no contract phases.
+			if len(migration.Tags) > 0 {
+				var tags []string
+				if columns["tags"] {
+					tags, err = getMigrationTags(ctx, db, migration.Version, config)
+					if err != nil {
+						return 0, err
+					}
+				}
+				if !selectedByTags(migration.Tags, tags) {
+					status = "[-] SKIPPED"
+					notes = append(notes, fmt.Sprintf("tags: %s; active tags were: %s",
+						formatTags(migration.Tags), formatTags(tags)))
+				}
+			}
+			if migration.Contract != nil && status == "[x] APPLIED" {
+				var contracted bool
+				if columns["contracted_at"] {
+					contracted, err = isMigrationContracted(ctx, db, migration.Version, config)
+					if err != nil {
+						return 0, err
+					}
+				}
+				if !contracted {
+					nbPending++
+					notes = append(notes, "waiting on contract")
+				}
+			}
+		} else {
+			nbPending++
+			if migration.Batched != nil && batchesExist {
+				_, nbBatches, err := getBatchCursor(ctx, db, migration.Version, config)
+				if err != nil {
+					return 0, err
+				}
+				if nbBatches > 0 {
+					notes = append(notes, fmt.Sprintf("in progress: %d batch(es) applied", nbBatches))
+				}
+			}
+			if !selectedByTags(migration.Tags, config.Tags) {
+				notes = append(notes, fmt.Sprintf("to be skipped, tags: %s; active tags: %s",
+					formatTags(migration.Tags), formatTags(config.Tags)))
+			}
+		}
+		if migration.Irreversible {
+			notes = append(notes, "irreversible")
+		}
+
+		line := fmt.Sprintf("%-*d %-12s", versionWidth, migration.Version, status)
+		if timestamps {
+			line += " " + formatVersionTime(migration.Version, config.Versioning)
+		}
+		if len(notes) > 0 {
+			line = strings.TrimRight(line, " ") + " (" + strings.Join(notes, "; ") + ")"
 		}
-		_, _ = fmt.Fprintf(w, "%-10d %-12s\n", migration.Version, status)
+		_, _ = fmt.Fprintln(w, line)
 	}
+	writeUnknown(0)
 
-	return nil
+	return nbPending, nil
 }
 
 // usePager attempts to pipe output to $PAGER if available and stdout is a TTY.
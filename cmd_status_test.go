@@ -4,17 +4,41 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+// appliedVersionsRow mocks the row with the comma-separated applied versions.
+func appliedVersionsRow(versions ...int) *dbRowMock {
+	ids := make([]string, len(versions))
+	for i, version := range versions {
+		ids[i] = strconv.Itoa(version)
+	}
+	return appliedIDsRow(strings.Join(ids, ","))
+}
+
+// migrationsColumns are the columns of the migrations table (see
+// createMigsTblSQL).
+const migrationsColumns = "version,applied_at,tags,contracted_at,checksum"
+
+// expectMigrationsColumns mocks the query of the columns of the migrations
+// table, which the read-only commands run instead of creating it.
+func expectMigrationsColumns(db *dbMock, table string) {
+	db.On("QueryRowContext", mock.Anything, selectColumnsSQL(), "", table).
+		Return(appliedIDsRow(migrationsColumns)).
+		Once()
+}
+
 func TestRunCmdStatus(t *testing.T) {
 	testCases := []struct {
 		name       string
 		migrations []migrationMock
 		setupMock  func(*dbMock, *dbRowMock)
+		versioning VersioningScheme
 		wantOut    string
 		wantErr    string
 	}{
@@ -22,8 +46,8 @@ func TestRunCmdStatus(t *testing.T) {
 			name:       "no migrations defined",
 			migrations: []migrationMock{},
 			setupMock: func(db *dbMock, row *dbRowMock) {
-				// Get DB version - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				// Get applied versions - DB at version 0
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -31,8 +55,8 @@ func TestRunCmdStatus(t *testing.T) {
 				})).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).([]any)
-						ptr := dest[0].(*int)
-						*ptr = 0
+						ptr := dest[0].(*string)
+						*ptr = ""
 					}).
 					Return(nil).
 					Once()
@@ -65,8 +89,8 @@ func TestRunCmdStatus(t *testing.T) {
 				},
 			},
 			setupMock: func(db *dbMock, row *dbRowMock) {
-				// Get DB version - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				// Get applied versions - DB at version 0
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -74,8 +98,8 @@ func TestRunCmdStatus(t *testing.T) {
 				})).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).([]any)
-						ptr := dest[0].(*int)
-						*ptr = 0
+						ptr := dest[0].(*string)
+						*ptr = ""
 					}).
 					Return(nil).
 					Once()
@@ -111,8 +135,8 @@ func TestRunCmdStatus(t *testing.T) {
 				},
 			},
 			setupMock: func(db *dbMock, row *dbRowMock) {
-				// Get DB version - returns 2
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				// Get applied versions - DB at version 2
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -120,8 +144,8 @@ func TestRunCmdStatus(t *testing.T) {
 				})).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).([]any)
-						ptr := dest[0].(*int)
-						*ptr = 2
+						ptr := dest[0].(*string)
+						*ptr = "1,2"
 					}).
 					Return(nil).
 					Once()
@@ -157,8 +181,8 @@ func TestRunCmdStatus(t *testing.T) {
 				},
 			},
 			setupMock: func(db *dbMock, row *dbRowMock) {
-				// Get DB version - returns 3
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				// Get applied versions - DB at version 3
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -166,8 +190,8 @@ func TestRunCmdStatus(t *testing.T) {
 				})).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).([]any)
-						ptr := dest[0].(*int)
-						*ptr = 3
+						ptr := dest[0].(*string)
+						*ptr = "1,2,3"
 					}).
 					Return(nil).
 					Once()
@@ -203,8 +227,8 @@ func TestRunCmdStatus(t *testing.T) {
 				},
 			},
 			setupMock: func(db *dbMock, row *dbRowMock) {
-				// Get DB version - returns 2
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				// Get applied versions - DB at version 2
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -212,8 +236,8 @@ func TestRunCmdStatus(t *testing.T) {
 				})).
 					Run(func(args mock.Arguments) {
 						dest := args.Get(0).([]any)
-						ptr := dest[0].(*int)
-						*ptr = 2
+						ptr := dest[0].(*string)
+						*ptr = "1,2"
 					}).
 					Return(nil).
 					Once()
@@ -235,8 +259,8 @@ func TestRunCmdStatus(t *testing.T) {
 				},
 			},
 			setupMock: func(db *dbMock, row *dbRowMock) {
-				// Get DB version - fails
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				// Get applied versions - fails
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -245,7 +269,48 @@ func TestRunCmdStatus(t *testing.T) {
 					Return(errors.New("database connection error")).
 					Once()
 			},
-			wantErr: "failed to get current DB version: database connection error",
+			wantErr: "failed to get the applied migrations: database connection error",
+		},
+		{
+			name:       "unknown versions",
+			migrations: createTestMigrations(1, 2, 4, 6),
+			setupMock: func(db *dbMock, row *dbRowMock) {
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(row).
+					Once()
+				row.On("Scan", mock.Anything).
+					Run(func(args mock.Arguments) {
+						*(args.Get(0).([]any)[0].(*string)) = "1,2,3,4,5"
+					}).
+					Return(nil).
+					Once()
+			},
+			wantOut: "VERSION    STATUS      \n" +
+				"6          [ ] PENDING \n" +
+				"5          [?] UNKNOWN (applied, but unknown to the code)\n" +
+				"4          [x] APPLIED \n" +
+				"3          [?] UNKNOWN (applied, but unknown to the code)\n" +
+				"2          [x] APPLIED \n" +
+				"1          [x] APPLIED \n",
+		},
+		{
+			name:       "timestamp versions",
+			migrations: createTestMigrations(20261016093000, 20261017120005),
+			setupMock: func(db *dbMock, row *dbRowMock) {
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(row).
+					Once()
+				row.On("Scan", mock.Anything).
+					Run(func(args mock.Arguments) {
+						*(args.Get(0).([]any)[0].(*string)) = "20261016093000"
+					}).
+					Return(nil).
+					Once()
+			},
+			versioning: VersioningTimestamp,
+			wantOut: "VERSION        STATUS       TIME\n" +
+				"20261017120005 [ ] PENDING  2026-10-17 12:00:05 UTC\n" +
+				"20261016093000 [x] APPLIED  2026-10-16 09:30:00 UTC\n",
 		},
 	}
 
@@ -253,16 +318,23 @@ func TestRunCmdStatus(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			db := new(dbMock)
 			row := new(dbRowMock)
+			expectMigrationsColumns(db, migrationsTableName)
 			tc.setupMock(db, row)
 
 			var output bytes.Buffer
 
-			err := runCmdStatus(
+			config := DefaultConfig()
+			if tc.versioning != "" {
+				config.Versioning = tc.versioning
+			}
+
+			nbPending, err := runCmdStatus(
 				context.Background(),
 				tc.migrations,
+				nil,
 				db,
 				&output,
-				defaultTimeout,
+				config,
 			)
 
 			db.AssertExpectations(t)
@@ -275,6 +347,50 @@ func TestRunCmdStatus(t *testing.T) {
 			}
 			require.NoError(t, err)
 			require.Equal(t, tc.wantOut, output.String())
+			require.Equal(t, strings.Count(tc.wantOut, "PENDING"), nbPending)
 		})
 	}
 }
+
+func TestRunCmdStatusNotes(t *testing.T) {
+	migrations := createTestMigrations(1, 2, 3, 4)
+	migrations[1].Tags = []string{"test"}
+	migrations[2].Tags = []string{"prod"}
+	migrations[3].Tags = []string{"test"}
+	migrations[0].Irreversible = true
+
+	db := new(dbMock)
+	expectMigrationsColumns(db, migrationsTableName)
+	db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+		Return(appliedVersionsRow(1, 2, 3)).
+		Once()
+	for version, dbTags := range map[int]string{2: "prod,eu", 3: "prod,eu"} {
+		row := new(dbRowMock)
+		db.On("QueryRowContext", mock.Anything, selectMigTagsSQL(migrationsTableName), version).
+			Return(row).
+			Once()
+		row.On("Scan", mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(0).([]any)[0].(*string)) = dbTags
+			}).
+			Return(nil).
+			Once()
+	}
+
+	config := DefaultConfig()
+	config.Tags = []string{"prod"}
+
+	var output bytes.Buffer
+	nbPending, err := runCmdStatus(context.Background(), migrations, nil, db, &output, config)
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+
+	require.Equal(t,
+		"VERSION    STATUS      \n"+
+			"4          [ ] PENDING (to be skipped, tags: test; active tags: prod)\n"+
+			"3          [x] APPLIED \n"+
+			"2          [-] SKIPPED (tags: test; active tags were: prod,eu)\n"+
+			"1          [x] APPLIED (irreversible)\n",
+		output.String())
+	require.Equal(t, 1, nbPending)
+}
@@ -2,11 +2,15 @@ package gosmig
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
 )
 
+// runCmdUp applies the pending migrations, or only the first limit ones if
+// limit > 0. It returns the number of migrations applied, including the ones
+// skipped by tags, which are recorded as applied.
 func runCmdUp[
 	TDBRow DBRow,
 	TDBResult DBResult,
@@ -19,34 +23,78 @@ func runCmdUp[
 	db TDB,
 	output io.Writer,
 	limit int,
-	timeout time.Duration,
-) error {
+	config *Config,
+) (int, error) {
+
+	if usesGraph(migrations) {
+		return runGraphUp(ctx, migrations, db, output, limit, config)
+	}
 
 	sortMigrationsAsc(migrations)
 
-	dbVersion, err := getDBVersion(ctx, db, timeout)
+	dbVersion, err := getDBVersion(ctx, db, config)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if err := checkSquashedRange(dbVersion, latestBaselineVersion(migrations)); err != nil {
+		return 0, err
+	}
+
+	if err := checkDBAhead(dbVersion, migrations[len(migrations)-1].Version, config); err != nil {
+		return 0, err
 	}
 
-	var nbAppliedMigrations int
+	var nbAppliedMigrations, nbSkippedMigrations int
 
 	for _, migration := range migrations {
 		if migration.Version <= dbVersion {
 			continue
 		}
 
-		if migration.UpDown != nil {
-			err = executeInTx(
-				ctx, db, migrateUp(migration.Version, migration.UpDown.Up, timeout), timeout)
+		if !selectedByTags(migration.Tags, config.Tags) {
+			// Only the version is recorded, so that the next ones can be applied.
+			err = runMigration(ctx, db, migration, DirectionUp, TxModeTX, "", true, config,
+				func(ctx context.Context) error {
+					return executeInTx(ctx, db, migrateUp(migration.Version, noop[TTX], config), config.Timeout)
+				})
+			if err != nil {
+				return 0, newMigrationError(migration.Version, DirectionUp, TxModeTX, err)
+			}
+
+			_, _ = fmt.Fprintf(output, "[-] Skipped migration version %d (tags: %s)\n",
+				migration.Version, formatTags(migration.Tags))
+
+			nbSkippedMigrations++
+			continue
+		}
+
+		switch {
+		case migration.UpDown != nil:
+			err = runMigration(ctx, db, migration, DirectionUp, TxModeTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeInTx(ctx, db, withChecksum(migration.Version, migration.Checksum,
+						migrateUp(migration.Version, migration.UpDown.Up, config), config), config.Timeout)
+				})
+			if err != nil {
+				return 0, newMigrationError(migration.Version, DirectionUp, TxModeTX, err)
+			}
+		case migration.Batched != nil:
+			err = runMigration(ctx, db, migration, DirectionUp, TxModeTX, "", false, config,
+				func(ctx context.Context) error {
+					return runBatched(ctx, migration, db, output, config)
+				})
 			if err != nil {
-				return fmt.Errorf("execute in TX: %w", err)
+				return 0, newMigrationError(migration.Version, DirectionUp, TxModeTX, err)
 			}
-		} else {
-			err = executeNoTx(
-				ctx, db, migrateUp(migration.Version, migration.UpDownNoTX.Up, timeout), timeout)
+		default:
+			err = runMigration(ctx, db, migration, DirectionUp, TxModeNoTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeNoTx(ctx, db, withChecksum(migration.Version, migration.Checksum,
+						migrateUp(migration.Version, migration.UpDownNoTX.Up, config), config), config.Timeout)
+				})
 			if err != nil {
-				return fmt.Errorf("execute without TX: %w", err)
+				return 0, newMigrationError(migration.Version, DirectionUp, TxModeNoTX, err)
 			}
 		}
 
@@ -59,45 +107,231 @@ func runCmdUp[
 		}
 	}
 
-	if nbAppliedMigrations == 0 {
+	if nbAppliedMigrations == 0 && nbSkippedMigrations == 0 {
 		_, _ = fmt.Fprintln(output, "No migrations to apply")
-		return nil
+		return 0, nil
 	}
 
 	_, _ = fmt.Fprintf(output, "%d migration(s) applied\n", nbAppliedMigrations)
+	if nbSkippedMigrations > 0 {
+		_, _ = fmt.Fprintf(output, "%d migration(s) skipped\n", nbSkippedMigrations)
+	}
+
+	return nbAppliedMigrations + nbSkippedMigrations, nil
+}
+
+// runCmdUpAtomic applies all the pending migrations in a single transaction,
+// committed once, so that either all or none of them are applied. It refuses
+// to run if any pending migration runs without a transaction (UpDownNoTX).
+// It returns the number of migrations applied, as runCmdUp does.
+func runCmdUpAtomic[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+) (int, error) {
+
+	if usesGraph(migrations) {
+		return 0, errors.New("can't apply the migrations atomically: migrations with modules or " +
+			"dependencies are applied one by one")
+	}
+
+	sortMigrationsAsc(migrations)
+
+	dbVersion, err := getDBVersion(ctx, db, config)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkSquashedRange(dbVersion, latestBaselineVersion(migrations)); err != nil {
+		return 0, err
+	}
+
+	if err := checkDBAhead(dbVersion, migrations[len(migrations)-1].Version, config); err != nil {
+		return 0, err
+	}
+
+	var pending []Migration[TDBRow, TDBResult, TTX, TTXO, TDB]
+	for _, migration := range migrations {
+		if migration.Version <= dbVersion {
+			continue
+		}
+		if selectedByTags(migration.Tags, config.Tags) {
+			if migration.UpDownNoTX != nil {
+				return 0, fmt.Errorf(
+					"can't apply the migrations atomically: migration version %d runs without "+
+						"a transaction (UpDownNoTX)", migration.Version)
+			}
+			if migration.Batched != nil {
+				return 0, fmt.Errorf(
+					"can't apply the migrations atomically: migration version %d is committed "+
+						"batch by batch (Batched)", migration.Version)
+			}
+		}
+		pending = append(pending, migration)
+	}
+
+	if len(pending) == 0 {
+		_, _ = fmt.Fprintln(output, "No migrations to apply")
+		return 0, nil
+	}
+
+	// The version of the migration being applied, to report errors.
+	version := pending[0].Version
+	var lines []string
+	var nbAppliedMigrations, nbSkippedMigrations int
+	// The history is recorded once the transaction is over (see below).
+	var history []historyEntry
+
+	err = executeInTx(ctx, db, func(ctx context.Context, tx TTX) error {
+		for _, migration := range pending {
+			version = migration.Version
+			start := time.Now()
+
+			if !selectedByTags(migration.Tags, config.Tags) {
+				err := traceMigration(ctx, migration, DirectionUp, TxModeTX, "", true, config,
+					func(ctx context.Context) error {
+						return migrateUp(migration.Version, noop[TTX], config)(ctx, tx)
+					})
+				history = append(history,
+					newHistoryEntry(migration, DirectionUp, "", true, start, err))
+				if err != nil {
+					return err
+				}
+				lines = append(lines, fmt.Sprintf("[-] Skipped migration version %d (tags: %s)",
+					migration.Version, formatTags(migration.Tags)))
+				nbSkippedMigrations++
+				continue
+			}
+
+			err := traceMigration(ctx, migration, DirectionUp, TxModeTX, "", false, config,
+				func(ctx context.Context) error {
+					return withChecksum(migration.Version, migration.Checksum,
+						migrateUp(migration.Version, migration.UpDown.Up, config), config)(ctx, tx)
+				})
+			history = append(history, newHistoryEntry(migration, DirectionUp, "", false, start, err))
+			if err != nil {
+				return err
+			}
+			lines = append(lines, fmt.Sprintf("[x] Applied migration version %d", migration.Version))
+			nbAppliedMigrations++
+		}
+		return nil
+	}, config.Timeout*time.Duration(len(pending))) // Timeout is per migration.
+	if config.History && len(history) > 0 {
+		if err != nil {
+			// The migrations applied before the failing one (or the commit) were
+			// rolled back with it, so only its failure is recorded.
+			failed := history[len(history)-1]
+			if failed.Outcome != historyOutcomeFailure { // the commit failed
+				failed.Outcome, failed.Error = historyOutcomeFailure, err.Error()
+			}
+			history = []historyEntry{failed}
+		}
+		recordHistory(ctx, db, config, history...)
+	}
+	if err != nil {
+		return 0, newMigrationError(version, DirectionUp, TxModeTX, err)
+	}
+
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(output, line)
+	}
+	_, _ = fmt.Fprintf(output, "%d migration(s) applied atomically\n", nbAppliedMigrations)
+	if nbSkippedMigrations > 0 {
+		_, _ = fmt.Fprintf(output, "%d migration(s) skipped\n", nbSkippedMigrations)
+	}
 
+	return nbAppliedMigrations + nbSkippedMigrations, nil
+}
+
+// checkSquashedRange checks that the database version isn't inside the range
+// of migrations squashed into the baseline with the given version.
+func checkSquashedRange(dbVersion, baselineVersion int) error {
+	if dbVersion > 0 && dbVersion < baselineVersion {
+		return fmt.Errorf(
+			"%w: database version %d < baseline version %d; migrate it up to the baseline "+
+				"version with a release that still has the squashed migrations first",
+			ErrSquashedRange, dbVersion, baselineVersion)
+	}
+	return nil
+}
+
+// checkDBAhead checks that no migration unknown to the code is applied above
+// the target version of the command, i.e. that the database version isn't
+// above it, unless Config.AllowUnknownVersions is set.
+func checkDBAhead(dbVersion, targetVersion int, config *Config) error {
+	if dbVersion > targetVersion && !config.AllowUnknownVersions {
+		return fmt.Errorf(
+			"%w: database version %d > version %d; deploy a release which has them, "+
+				"or force the command (--force)",
+			ErrDBAhead, dbVersion, targetVersion)
+	}
+	return nil
+}
+
+// noop is the Up (or Down) of skipped migrations.
+func noop[TDBOrTX any](context.Context, TDBOrTX) error {
 	return nil
 }
 
 func migrateUp[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
 	version int,
 	up func(ctx context.Context, dbOrTX TDBOrTX) error,
-	timeout time.Duration,
+	config *Config,
 ) func(context.Context, TDBOrTX) error {
 
 	return func(ctx context.Context, dbOrTX TDBOrTX) error {
-		dbVersion, err := getDBVersion(ctx, dbOrTX, timeout)
-		if err != nil {
+		if err := checkPendingVersion(ctx, dbOrTX, version, config); err != nil {
 			return err
 		}
 
-		if version <= dbVersion {
-			return fmt.Errorf(
-				"%w: migration version %d <= current DB version %d",
-				errDBVersionChangedUp, version, dbVersion)
+		if err := setSearchPath(ctx, dbOrTX, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
 		}
 
-		migCtx, cancelMig := context.WithTimeout(ctx, timeout)
+		migCtx, cancelMig := context.WithTimeout(ctx, config.Timeout)
 		defer cancelMig()
 		if err := up(migCtx, dbOrTX); err != nil {
-			return fmt.Errorf(
-				"failed to apply migration.up version %d: %w", version, err)
+			return withPhase(PhaseFunc, fmt.Errorf(
+				"failed to apply migration.up version %d: %w", version, err))
 		}
 
-		if err := insertDBVersion(ctx, dbOrTX, version, timeout); err != nil {
-			return err
+		if err := insertDBVersion(ctx, dbOrTX, version, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
 		}
 
 		return nil
 	}
 }
+
+// checkPendingVersion checks that the migration version is still pending, in
+// case the database version changed since it was read (e.g. because of a
+// concurrent run).
+func checkPendingVersion[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	config *Config,
+) error {
+
+	dbVersion, err := getDBVersion(ctx, dbOrTX, config)
+	if err != nil {
+		return withPhase(PhaseBookkeeping, err)
+	}
+
+	if version <= dbVersion {
+		return withPhase(PhaseBookkeeping, fmt.Errorf(
+			"%w: migration version %d <= current DB version %d",
+			ErrDBVersionChangedUp, version, dbVersion))
+	}
+
+	return nil
+}
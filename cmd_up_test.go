@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -24,7 +25,7 @@ func TestRunCmdUp(t *testing.T) {
 			migrations: createTestMigrations(1, 2),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 2 (latest)
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -45,7 +46,7 @@ func TestRunCmdUp(t *testing.T) {
 			migrations: createTestMigrations(1, 2, 3),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -78,7 +79,7 @@ func TestRunCmdUp(t *testing.T) {
 			migrations: createTestMigrations(1, 2, 3),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 1
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -107,7 +108,7 @@ func TestRunCmdUp(t *testing.T) {
 			migrations: createTestMigrations(1, 2, 3),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -132,7 +133,7 @@ func TestRunCmdUp(t *testing.T) {
 			name:       "error getting initial DB version",
 			migrations: createTestMigrations(1),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -148,7 +149,7 @@ func TestRunCmdUp(t *testing.T) {
 			migrations: createTestMigrations(1),
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get initial DB version - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -168,7 +169,7 @@ func TestRunCmdUp(t *testing.T) {
 					Once()
 
 				// Get DB version in TX - returns 0
-				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -192,7 +193,7 @@ func TestRunCmdUp(t *testing.T) {
 					Return(nil).
 					Once()
 			},
-			wantErr: "execute in TX",
+			wantErr: "migration version 1 up failed (TX, func phase)",
 		},
 		{
 			name: "error during migration execution - no TX",
@@ -213,7 +214,7 @@ func TestRunCmdUp(t *testing.T) {
 			},
 			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
 				// Get initial DB version - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -228,7 +229,7 @@ func TestRunCmdUp(t *testing.T) {
 					Once()
 
 				// Get DB version for no-TX migration - returns 0
-				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -247,7 +248,7 @@ func TestRunCmdUp(t *testing.T) {
 					Return(result, errors.New("cannot create index concurrently in transaction")).
 					Once()
 			},
-			wantErr: "execute without TX",
+			wantErr: "migration version 1 up failed (no TX, func phase)",
 		},
 	}
 
@@ -262,7 +263,7 @@ func TestRunCmdUp(t *testing.T) {
 
 			var output bytes.Buffer
 
-			err := runCmdUp(context.Background(), tc.migrations, db, &output, tc.limit, defaultTimeout)
+			nbApplied, err := runCmdUp(context.Background(), tc.migrations, db, &output, tc.limit, DefaultConfig())
 
 			db.AssertExpectations(t)
 			tx.AssertExpectations(t)
@@ -276,10 +277,36 @@ func TestRunCmdUp(t *testing.T) {
 			}
 			require.NoError(t, err)
 			require.Equal(t, tc.wantOut, output.String())
+			require.Equal(t,
+				strings.Count(tc.wantOut, "[x] Applied")+strings.Count(tc.wantOut, "[-] Skipped"), nbApplied)
 		})
 	}
 }
 
+func TestRunCmdUpDBAhead(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		db := new(dbMock)
+		db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(dbVersionRow(3)).
+			Once()
+
+		config := DefaultConfig()
+		config.AllowUnknownVersions = force
+
+		var output bytes.Buffer
+		_, err := runCmdUp(context.Background(), createTestMigrations(1, 2), db, &output, 0, config)
+		db.AssertExpectations(t)
+
+		if force {
+			require.NoError(t, err)
+			require.Equal(t, "No migrations to apply\n", output.String())
+			continue
+		}
+		require.ErrorIs(t, err, ErrDBAhead)
+		require.Empty(t, output.String())
+	}
+}
+
 func TestMigrateUp(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -292,7 +319,7 @@ func TestMigrateUp(t *testing.T) {
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 0
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -312,7 +339,7 @@ func TestMigrateUp(t *testing.T) {
 					Once()
 
 				// Insert version
-				dbOrTX.On("ExecContext", mock.Anything, insertMigVersionSQL, 1).
+				dbOrTX.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 1).
 					Return(result, nil).
 					Once()
 			},
@@ -322,7 +349,7 @@ func TestMigrateUp(t *testing.T) {
 			version: 3,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 2
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -342,7 +369,7 @@ func TestMigrateUp(t *testing.T) {
 					Once()
 
 				// Insert version
-				dbOrTX.On("ExecContext", mock.Anything, insertMigVersionSQL, 3).
+				dbOrTX.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 3).
 					Return(result, nil).
 					Once()
 			},
@@ -352,7 +379,7 @@ func TestMigrateUp(t *testing.T) {
 			version: 3,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 3
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -373,7 +400,7 @@ func TestMigrateUp(t *testing.T) {
 			version: 2,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 5
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -393,7 +420,7 @@ func TestMigrateUp(t *testing.T) {
 			name:    "error - failed to get DB version",
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -409,7 +436,7 @@ func TestMigrateUp(t *testing.T) {
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 0
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -435,7 +462,7 @@ func TestMigrateUp(t *testing.T) {
 			version: 1,
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock, result *dbResultMock) {
 				// Get DB version - returns 0
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -455,7 +482,7 @@ func TestMigrateUp(t *testing.T) {
 					Once()
 
 				// Insert version fails
-				dbOrTX.On("ExecContext", mock.Anything, insertMigVersionSQL, 1).
+				dbOrTX.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 1).
 					Return(result, errors.New("unique constraint violation")).
 					Once()
 			},
@@ -479,7 +506,7 @@ func TestMigrateUp(t *testing.T) {
 			}
 
 			// Call migrateUp and execute the returned function
-			migrateFn := migrateUp(tc.version, upFunc, defaultTimeout)
+			migrateFn := migrateUp(tc.version, upFunc, DefaultConfig())
 			err := migrateFn(context.Background(), dbOrTX)
 
 			dbOrTX.AssertExpectations(t)
@@ -532,7 +559,7 @@ func setupMigrationUpMocks(
 		Once()
 
 	// Get DB version in TX
-	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 		Return(row).
 		Once()
 	row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -552,7 +579,7 @@ func setupMigrationUpMocks(
 		Once()
 
 	// Insert version
-	tx.On("ExecContext", mock.Anything, insertMigVersionSQL, targetVersion).
+	tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), targetVersion).
 		Return(result, nil).
 		Once()
 
@@ -561,3 +588,219 @@ func setupMigrationUpMocks(
 		Return(nil).
 		Once()
 }
+
+func TestRunCmdUpTags(t *testing.T) {
+	migrations := createTestMigrations(1, 2, 3)
+	migrations[1].Tags = []string{"test"}
+	migrations[2].Tags = []string{"prod", "test"}
+
+	db := new(dbMock)
+	tx := new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(0)).
+		Once()
+	for version := 1; version <= 3; version++ {
+		db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+		tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(dbVersionRow(version - 1)).
+			Once()
+		tx.On("ExecContext", mock.Anything, insertMigVersionTagsSQL(migrationsTableName), version, "prod,eu").
+			Return(new(dbResultMock), nil).
+			Once()
+		tx.On("Commit").Return(nil).Once()
+	}
+	// Only the selected migrations (1 and 3) are run.
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), nil).
+		Twice()
+
+	config := DefaultConfig()
+	config.Tags = []string{"prod", "eu"}
+
+	var output bytes.Buffer
+	_, err := runCmdUp(context.Background(), migrations, db, &output, 0, config)
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+
+	require.Equal(t,
+		"[x] Applied migration version 1\n"+
+			"[-] Skipped migration version 2 (tags: test)\n"+
+			"[x] Applied migration version 3\n"+
+			"2 migration(s) applied\n"+
+			"1 migration(s) skipped\n",
+		output.String())
+}
+
+func TestRunCmdUpAtomic(t *testing.T) {
+	upErr := errors.New("syntax error")
+
+	testCases := []struct {
+		name       string
+		migrations []migrationMock
+		setupMock  func(*dbMock, *txMock)
+		wantOut    string
+		wantErr    string
+	}{
+		{
+			name:       "applies all in one transaction",
+			migrations: createTestMigrations(1, 2, 3),
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(1)).
+					Once()
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				for version := 2; version <= 3; version++ {
+					tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+						Return(dbVersionRow(version - 1)).
+						Once()
+					tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), version).
+						Return(new(dbResultMock), nil).
+						Once()
+				}
+				tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+					Return(new(dbResultMock), nil).
+					Twice()
+				tx.On("Commit").Return(nil).Once()
+			},
+			wantOut: "[x] Applied migration version 2\n" +
+				"[x] Applied migration version 3\n" +
+				"2 migration(s) applied atomically\n",
+		},
+		{
+			name:       "no migrations to apply",
+			migrations: createTestMigrations(1, 2),
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+			},
+			wantOut: "No migrations to apply\n",
+		},
+		{
+			name: "refuses pending migrations without transaction",
+			migrations: append(createTestMigrations(1, 2), migrationMock{
+				Version: 3,
+				UpDownNoTX: &UpDown[*dbRowMock, *dbResultMock, *dbMock]{
+					Up:   func(ctx context.Context, db *dbMock) error { return nil },
+					Down: func(ctx context.Context, db *dbMock) error { return nil },
+				},
+			}),
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(1)).
+					Once()
+			},
+			wantErr: "can't apply the migrations atomically: migration version 3 runs " +
+				"without a transaction (UpDownNoTX)",
+		},
+		{
+			name: "failure rolls back all",
+			migrations: append(createTestMigrations(1), migrationMock{
+				Version: 2,
+				UpDown: &UpDown[*dbRowMock, *dbResultMock, *txMock]{
+					Up:   func(ctx context.Context, tx *txMock) error { return upErr },
+					Down: func(ctx context.Context, tx *txMock) error { return nil },
+				},
+			}),
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(0)).
+					Once()
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				for version := 1; version <= 2; version++ {
+					tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+						Return(dbVersionRow(version - 1)).
+						Once()
+				}
+				tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 1).
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Rollback").Return(nil).Once()
+			},
+			wantErr: "migration version 2 up failed (TX, func phase): " +
+				"failed to execute in transaction: failed to apply migration.up version 2: syntax error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			tc.setupMock(db, tx)
+
+			var output bytes.Buffer
+			_, err := runCmdUpAtomic(context.Background(), tc.migrations, db, &output, DefaultConfig())
+
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				require.Empty(t, output.String())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, output.String())
+		})
+	}
+}
+
+func TestRunCmdUpSchema(t *testing.T) {
+	migrations := append(createTestMigrations(1), migrationMock{
+		Version: 2,
+		UpDownNoTX: &UpDown[*dbRowMock, *dbResultMock, *dbMock]{
+			Up: func(ctx context.Context, db *dbMock) error {
+				_, err := db.ExecContext(ctx, "CREATE INDEX CONCURRENTLY test_idx ON test (id)")
+				return err
+			},
+			Down: func(ctx context.Context, db *dbMock) error { return nil },
+		},
+	})
+
+	db := new(dbMock)
+	tx := new(txMock)
+	table := "tenant_a.gosmig"
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(table)).
+		Return(dbVersionRow(0)).
+		Once()
+
+	// Migration 1 runs in a transaction, with the search_path set for it.
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(table)).
+		Return(dbVersionRow(0)).
+		Once()
+	tx.On("ExecContext", mock.Anything, "SET LOCAL search_path TO tenant_a").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, insertMigVersionSQL(table), 1).
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("Commit").Return(nil).Once()
+
+	// Migration 2 runs without a transaction, so its search_path can't be set.
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(table)).
+		Return(dbVersionRow(1)).
+		Once()
+
+	config := DefaultConfig()
+	config.Schema = "tenant_a"
+
+	var output bytes.Buffer
+	_, err := runCmdUp(context.Background(), migrations, db, &output, 0, config)
+	require.ErrorIs(t, err, ErrSchemaNoTX)
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+
+	require.Equal(t, "[x] Applied migration version 1\n", output.String())
+
+	require.ErrorIs(t, checkSchemaNoTX(migrations, config), ErrSchemaNoTX)
+	require.NoError(t, checkSchemaNoTX(migrations, DefaultConfig()))
+	require.NoError(t, checkSchemaNoTX(migrations[:1], config))
+}
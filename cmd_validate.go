@@ -0,0 +1,234 @@
+package gosmig
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ValidationReport is the result of Validate.
+type ValidationReport struct {
+	// Errors are problems that make the migrations unusable or unsafe.
+	Errors []string
+	// Warnings are suspicious things that don't prevent running the migrations.
+	Warnings []string
+}
+
+// Err returns an error wrapping ErrInvalidMigrations and listing all the
+// errors from the report, or nil if there are none.
+func (r *ValidationReport) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidMigrations, strings.Join(r.Errors, "; "))
+}
+
+func (r *ValidationReport) String() string {
+	var sb strings.Builder
+	for _, err := range r.Errors {
+		_, _ = fmt.Fprintf(&sb, "ERROR   %s\n", err)
+	}
+	for _, warning := range r.Warnings {
+		_, _ = fmt.Fprintf(&sb, "WARNING %s\n", warning)
+	}
+	_, _ = fmt.Fprintf(&sb, "%d error(s), %d warning(s)\n", len(r.Errors), len(r.Warnings))
+	return sb.String()
+}
+
+func (r *ValidationReport) addError(format string, args ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *ValidationReport) addWarning(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the migrations without connecting to the database, so it
+// can be used in unit tests and pre-commit hooks. On top of the checks done
+// by New (valid versions for config.Versioning, no duplicates, Up and Down
+// defined), it reports gaps between sequential versions and, if sqlFS is not
+// nil, problems with the SQL
+// migration files from it: invalid or non-monotonic file names, missing up or
+// down files, SQL files not among the migrations, and checksums which don't
+// match the gosmig.sum file or the loaded migrations.
+func Validate[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	sqlFS fs.FS,
+	config *Config,
+) *ValidationReport {
+
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	report := &ValidationReport{}
+
+	if len(migrations) == 0 {
+		report.addError("%v", ErrNoMigrations)
+	}
+
+	report.Errors = append(report.Errors, migrationsValidationErrs(migrations)...)
+	report.Errors = append(report.Errors, versionsValidationErrs(migrations, config.Versioning)...)
+
+	// Timestamp versions have gaps by design. Each module has its own versions.
+	if config.Versioning != VersioningTimestamp {
+		versionsByModule := make(map[string][]int)
+		for _, migration := range migrations {
+			if migration.Version > 0 {
+				versionsByModule[migration.Module] = append(
+					versionsByModule[migration.Module], migration.Version)
+			}
+		}
+		for _, module := range slices.Sorted(maps.Keys(versionsByModule)) {
+			versions := versionsByModule[module]
+			slices.Sort(versions)
+			versions = slices.Compact(versions)
+			for i := 1; i < len(versions); i++ {
+				if prev, curr := versions[i-1], versions[i]; curr-prev > 1 {
+					gap := fmt.Sprintf("no migration between versions %d and %d", prev, curr)
+					if module != "" {
+						gap += " of module " + module
+					}
+					report.addWarning("version gap: %s", gap)
+				}
+			}
+		}
+	}
+
+	if sqlFS != nil {
+		validateSQLFiles(report, migrations, sqlFS)
+	}
+
+	return report
+}
+
+func validateSQLFiles[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	report *ValidationReport,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	sqlFS fs.FS,
+) {
+
+	files, invalidNames, err := readSQLMigrationFiles(sqlFS)
+	if err != nil {
+		report.addError("%v", err)
+		return
+	}
+
+	for _, name := range invalidNames {
+		report.addError(
+			"invalid SQL migration file name %s (want <version>_<description>.up|down.sql)", name)
+	}
+
+	// Files are sorted by name, so their versions must not decrease,
+	// otherwise tools listing them (and people reading them) see them out of order.
+	for i := 1; i < len(files); i++ {
+		if prev, curr := files[i-1], files[i]; curr.version < prev.version {
+			report.addWarning(
+				"non-monotonic SQL file names: %s sorts before %s (zero-pad the versions)",
+				prev.name, curr.name)
+		}
+	}
+
+	for _, err := range duplicateSQLFilesErrs(files) {
+		report.addError("%s", err)
+	}
+
+	checksumsByVersion := make(map[int]string)
+	for _, migration := range migrations {
+		checksumsByVersion[migration.Version] = migration.Checksum
+	}
+
+	for _, pair := range pairSQLMigrationFiles(files) {
+		if pair.up == nil {
+			report.addError("missing up SQL file for migration %d", pair.version)
+		}
+		if pair.up != nil {
+			if err := pair.downFileErr(pair.up.hasDirective(irreversibleDirective)); err != nil {
+				report.addError("%s", err)
+			}
+		}
+
+		migChecksum, ok := checksumsByVersion[pair.version]
+		switch {
+		case !ok:
+			report.addError(
+				"SQL migration %d is not among the defined migrations", pair.version)
+		case migChecksum != "" && migChecksum != pair.checksum():
+			report.addError(
+				"checksum of migration %d differs from the one of its SQL files", pair.version)
+		}
+	}
+
+	validateSumFile(report, files, sqlFS)
+}
+
+func validateSumFile(report *ValidationReport, files []sqlMigrationFile, sqlFS fs.FS) {
+	sums, err := readSumFile(sqlFS)
+	if err != nil {
+		report.addError("%v", err)
+		return
+	}
+
+	if sums == nil {
+		if len(files) > 0 {
+			report.addWarning("no %s file, checksums of the SQL files not verified", sumFileName)
+		}
+		return
+	}
+
+	for _, file := range files {
+		sum, ok := sums[file.name]
+		switch {
+		case !ok:
+			report.addError("SQL file %s is missing from %s", file.name, sumFileName)
+		case sum != checksum(file.content):
+			report.addError(
+				"checksum mismatch for SQL file %s: it changed since %s was written",
+				file.name, sumFileName)
+		}
+		delete(sums, file.name)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(sums)) {
+		report.addError("%s lists %s, which doesn't exist", sumFileName, name)
+	}
+}
+
+func runCmdValidate[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	output io.Writer,
+	config *Config,
+) error {
+
+	var sqlFS fs.FS
+	if config.MigrationsDir != "" {
+		sqlFS = os.DirFS(config.MigrationsDir)
+	}
+
+	report := Validate(migrations, sqlFS, config)
+	_, _ = fmt.Fprint(output, report.String())
+
+	return report.Err()
+}
@@ -0,0 +1,220 @@
+package gosmig
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sumFileFor(fsys fstest.MapFS) *fstest.MapFile {
+	var sum strings.Builder
+	for name, file := range fsys {
+		_, _ = fmt.Fprintf(&sum, "%s  %s\n", checksum(file.Data), name)
+	}
+	return &fstest.MapFile{Data: []byte(sum.String())}
+}
+
+func TestValidate(t *testing.T) {
+	sqlFiles := fstest.MapFS{
+		"001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (c INT);")},
+		"001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+		"002_add_d.up.sql":      {Data: []byte("ALTER TABLE t ADD COLUMN d INT;")},
+		"002_add_d.down.sql":    {Data: []byte("ALTER TABLE t DROP COLUMN d;")},
+	}
+
+	withSum := func(fsys fstest.MapFS) fstest.MapFS {
+		fsysWithSum := fstest.MapFS{}
+		for name, file := range fsys {
+			fsysWithSum[name] = file
+		}
+		fsysWithSum[sumFileName] = sumFileFor(fsys)
+		return fsysWithSum
+	}
+
+	testCases := []struct {
+		name         string
+		migrations   []migrationMock
+		sqlFS        func() fstest.MapFS
+		config       *Config
+		wantErrors   []string
+		wantWarnings []string
+	}{
+		{
+			name:       "valid Go migrations",
+			migrations: createTestMigrations(1, 2, 3),
+		},
+		{
+			name:       "no migrations",
+			migrations: nil,
+			wantErrors: []string{"no migrations provided"},
+		},
+		{
+			name:       "invalid Go migrations",
+			migrations: append(createTestMigrations(0, 2, 2), migrationMock{Version: 3}),
+			wantErrors: []string{
+				"migration version must be > 0",
+				"migration 3 must have one of UpDown, UpDownNoTX or Batched fields defined",
+				"migration version 2 is defined 2 times",
+			},
+		},
+		{
+			name:         "version gaps",
+			migrations:   createTestMigrations(1, 2, 5),
+			wantWarnings: []string{"version gap: no migration between versions 2 and 5"},
+		},
+		{
+			name: "version gaps per module",
+			migrations: []migrationMock{
+				createTestModuleMigration("billing", 1),
+				createTestModuleMigration("billing", 3),
+				createTestModuleMigration("users", 2),
+			},
+			wantWarnings: []string{"version gap: no migration between versions 1 and 3 of module billing"},
+		},
+		{
+			name:       "timestamp versions",
+			migrations: createTestMigrations(20261016093000, 20261016093015, 2026101609301, 20261316093000),
+			config:     &Config{Versioning: VersioningTimestamp},
+			wantErrors: []string{
+				"migration version 2026101609301 is not a YYYYMMDDhhmmss timestamp",
+				"migration version 20261316093000 is not a YYYYMMDDhhmmss timestamp",
+			},
+		},
+		{
+			name:       "valid SQL files with sum file",
+			migrations: createTestMigrations(1, 2),
+			sqlFS:      func() fstest.MapFS { return withSum(sqlFiles) },
+		},
+		{
+			name:         "missing sum file",
+			migrations:   createTestMigrations(1, 2),
+			sqlFS:        func() fstest.MapFS { return sqlFiles },
+			wantWarnings: []string{"no gosmig.sum file, checksums of the SQL files not verified"},
+		},
+		{
+			name:       "SQL files not matching the sum file",
+			migrations: createTestMigrations(1, 2),
+			sqlFS: func() fstest.MapFS {
+				fsys := withSum(sqlFiles)
+				fsys["002_add_d.up.sql"] = &fstest.MapFile{
+					Data: []byte("ALTER TABLE t ADD COLUMN d BIGINT;")}
+				fsys["003_add_e.up.sql"] = &fstest.MapFile{
+					Data: []byte("ALTER TABLE t ADD COLUMN e INT;")}
+				fsys["003_add_e.down.sql"] = &fstest.MapFile{
+					Data: []byte("ALTER TABLE t DROP COLUMN e;")}
+				delete(fsys, "001_create_t.down.sql")
+				return fsys
+			},
+			wantErrors: []string{
+				"missing down SQL file for migration 1",
+				"SQL migration 3 is not among the defined migrations",
+				"checksum mismatch for SQL file 002_add_d.up.sql: it changed since gosmig.sum was written",
+				"SQL file 003_add_e.down.sql is missing from gosmig.sum",
+				"SQL file 003_add_e.up.sql is missing from gosmig.sum",
+				"gosmig.sum lists 001_create_t.down.sql, which doesn't exist",
+			},
+		},
+		{
+			name:       "irreversible SQL migrations",
+			migrations: createTestMigrations(1, 2),
+			sqlFS: func() fstest.MapFS {
+				return withSum(fstest.MapFS{
+					"001_drop_t.up.sql":   {Data: []byte("-- gosmig:irreversible\nDROP TABLE t;")},
+					"002_drop_u.up.sql":   {Data: []byte("-- gosmig:irreversible\nDROP TABLE u;")},
+					"002_drop_u.down.sql": {Data: []byte("CREATE TABLE u (c INT);")},
+				})
+			},
+			wantErrors: []string{"irreversible migration 2 must not have a down SQL file"},
+		},
+		{
+			name:       "invalid, duplicate and non-monotonic SQL file names",
+			migrations: createTestMigrations(2, 10),
+			sqlFS: func() fstest.MapFS {
+				fsys := withSum(fstest.MapFS{
+					"10_add_e.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN e INT;")},
+					"10_add_e.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN e;")},
+					"2_add_d.up.sql":    {Data: []byte("ALTER TABLE t ADD COLUMN d INT;")},
+					"2_add_d.down.sql":  {Data: []byte("ALTER TABLE t DROP COLUMN d;")},
+					"2_add_f.down.sql":  {Data: []byte("ALTER TABLE t DROP COLUMN f;")},
+				})
+				fsys["add_g.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE t ADD COLUMN g INT;")}
+				return fsys
+			},
+			wantErrors: []string{
+				"invalid SQL migration file name add_g.up.sql (want <version>_<description>.up|down.sql)",
+				"duplicate SQL migration files: 2_add_d.down.sql, 2_add_f.down.sql",
+			},
+			wantWarnings: []string{
+				"version gap: no migration between versions 2 and 10",
+				"non-monotonic SQL file names: 10_add_e.up.sql sorts before 2_add_d.down.sql (zero-pad the versions)",
+			},
+		},
+		{
+			name: "migration checksum not matching the SQL files",
+			migrations: func() []migrationMock {
+				migrations := createTestMigrations(1, 2)
+				migrations[0].Checksum = "outdated"
+				return migrations
+			}(),
+			sqlFS: func() fstest.MapFS { return withSum(sqlFiles) },
+			wantErrors: []string{
+				"checksum of migration 1 differs from the one of its SQL files",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var report *ValidationReport
+			if tc.sqlFS != nil {
+				report = Validate(tc.migrations, tc.sqlFS(), tc.config)
+			} else {
+				report = Validate(tc.migrations, nil, tc.config)
+			}
+
+			require.Equal(t, tc.wantErrors, report.Errors)
+			require.Equal(t, tc.wantWarnings, report.Warnings)
+			if len(tc.wantErrors) > 0 {
+				require.ErrorIs(t, report.Err(), ErrInvalidMigrations)
+			} else {
+				require.NoError(t, report.Err())
+			}
+		})
+	}
+}
+
+func TestValidationReportString(t *testing.T) {
+	report := &ValidationReport{
+		Errors:   []string{"bad thing"},
+		Warnings: []string{"odd thing", "other odd thing"},
+	}
+
+	require.Equal(t,
+		"ERROR   bad thing\n"+
+			"WARNING odd thing\n"+
+			"WARNING other odd thing\n"+
+			"1 error(s), 2 warning(s)\n",
+		report.String())
+}
+
+func TestRunCmdValidate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "001_create_t.up.sql"), []byte("CREATE TABLE t (c INT);"), 0o644))
+
+	var out strings.Builder
+	err := runCmdValidate(
+		createTestMigrations(1), &out, &Config{MigrationsDir: dir})
+	require.ErrorIs(t, err, ErrInvalidMigrations)
+	require.Contains(t, out.String(), "ERROR   missing down SQL file for migration 1")
+	require.Contains(t, out.String(), "1 error(s), 1 warning(s)")
+
+	err = runCmdValidate(createTestMigrations(1, 2), io.Discard, DefaultConfig())
+	require.NoError(t, err)
+}
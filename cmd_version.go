@@ -4,20 +4,23 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"time"
 )
 
 func runCmdVersion[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
 	ctx context.Context,
 	dbOrTX TDBOrTX,
 	output io.Writer,
-	timeout time.Duration,
+	config *Config,
 ) error {
 
-	dbVersion, err := getDBVersion(ctx, dbOrTX, timeout)
+	dbVersion, err := getDBVersion(ctx, dbOrTX, config)
 	if err != nil {
 		return err
 	}
+	if versionTime := formatVersionTime(dbVersion, config.Versioning); versionTime != "" {
+		_, _ = fmt.Fprintf(output, "Current database version:\n%d (%s)\n", dbVersion, versionTime)
+		return nil
+	}
 	_, _ = fmt.Fprintf(output, "Current database version:\n%d\n", dbVersion)
 	return nil
 }
@@ -12,15 +12,16 @@ import (
 
 func TestRunCmdVersion(t *testing.T) {
 	testCases := []struct {
-		name      string
-		setupMock func(*dbOrTxMock, *dbRowMock)
-		wantOut   string
-		wantErr   string
+		name       string
+		setupMock  func(*dbOrTxMock, *dbRowMock)
+		versioning VersioningScheme
+		wantOut    string
+		wantErr    string
 	}{
 		{
 			name: "success - version 0 (no migrations applied)",
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock) {
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -39,7 +40,7 @@ func TestRunCmdVersion(t *testing.T) {
 		{
 			name: "success - version 5",
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock) {
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -55,10 +56,26 @@ func TestRunCmdVersion(t *testing.T) {
 			},
 			wantOut: "Current database version:\n5\n",
 		},
+		{
+			name: "success - timestamp version",
+			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock) {
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(row).
+					Once()
+				row.On("Scan", mock.Anything).
+					Run(func(args mock.Arguments) {
+						*(args.Get(0).([]any)[0].(*int)) = 20261016093000
+					}).
+					Return(nil).
+					Once()
+			},
+			versioning: VersioningTimestamp,
+			wantOut:    "Current database version:\n20261016093000 (2026-10-16 09:30:00 UTC)\n",
+		},
 		{
 			name: "error - failed to get DB version",
 			setupMock: func(dbOrTX *dbOrTxMock, row *dbRowMock) {
-				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL).
+				dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
 					Return(row).
 					Once()
 				row.On("Scan", mock.MatchedBy(func(dest []any) bool {
@@ -79,7 +96,12 @@ func TestRunCmdVersion(t *testing.T) {
 
 			var output bytes.Buffer
 
-			err := runCmdVersion(context.Background(), dbOrTX, &output, defaultTimeout)
+			config := DefaultConfig()
+			if tc.versioning != "" {
+				config.Versioning = tc.versioning
+			}
+
+			err := runCmdVersion(context.Background(), dbOrTX, &output, config)
 
 			dbOrTX.AssertExpectations(t)
 			row.AssertExpectations(t)
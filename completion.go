@@ -0,0 +1,260 @@
+package gosmig
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+var commandDescriptions = map[string]string{
+	cmdUp:         "Apply all pending migrations",
+	cmdUpOne:      "Apply only the next pending migration",
+	cmdDown:       "Roll back the most recent migration",
+	cmdGoto:       "Migrate up or down to a version (<version>)",
+	cmdStatus:     "Show the status of all migrations",
+	cmdPlan:       "Show what up would apply, without applying it",
+	cmdVersion:    "Show the current database version",
+	cmdCheck:      "Check that the database is up to date, without migrating it",
+	cmdHistory:    "Show the history of the migrations applied and rolled back",
+	cmdUnlock:     "Release the migrations lock left behind by a run which died",
+	cmdResetBatch: "Restart a batched migration from its first batch (<version>)",
+	cmdCompletion: "Generate a shell completion script (bash|zsh|fish)",
+	cmdValidate:   "Check the migrations without connecting to the database",
+	cmdCreate:     "Scaffold a new migration (<name>)",
+	cmdSquash:     "Squash the migrations up to a version into a baseline",
+	cmdSeed:       "Apply a seed set (<name>)",
+	cmdSeedStatus: "Show the status of all seed sets",
+}
+
+type (
+	completionFlagValue int
+
+	completionFlag struct {
+		name        string
+		description string
+		value       completionFlagValue
+	}
+
+	completionData struct {
+		Program  string
+		FuncName string
+		Commands []completionCommand
+		Flags    []completionFlag
+		Shells   []string
+	}
+
+	completionCommand struct {
+		Name        string
+		Description string
+	}
+)
+
+const (
+	completionFlagValueNone completionFlagValue = iota
+	completionFlagValueFile
+	completionFlagValueAny
+	completionFlagValueVersion
+)
+
+var completionFlags = []completionFlag{
+	{name: "config", description: "Path of the config file", value: completionFlagValueFile},
+	{name: "env", description: "Environment to use from the config file", value: completionFlagValueAny},
+	{name: "exit-code", description: "Exit with a distinct code if there are pending migrations, or none to apply"},
+	{name: "sql", description: "Create SQL migration files instead of a Go file"},
+	{name: "no-tx", description: "Create a migration which runs without a transaction"},
+	{name: "atomic", description: "Apply all the pending migrations in a single transaction"},
+	{name: "phase", description: "Apply only the expand or the contract phase of the migrations", value: completionFlagValueAny},
+	{name: "upto", description: "Version to squash the migrations up to", value: completionFlagValueVersion},
+	{name: "tags", description: "Comma-separated tags selecting the migrations to apply", value: completionFlagValueAny},
+	{name: "set", description: "Name of the migration set to run the command on", value: completionFlagValueAny},
+	{name: "force", description: "Run even if unknown migrations are applied above the target version"},
+	{name: "version", description: "Show only the history of this migration version", value: completionFlagValueVersion},
+	{name: "since", description: "Show only the history since this time", value: completionFlagValueAny},
+	{name: "until", description: "Show only the history before this time", value: completionFlagValueAny},
+	{name: "direction", description: "Show only the history of this direction (up or down)", value: completionFlagValueAny},
+	{name: "json", description: "Write the history as JSON"},
+}
+
+func (f completionFlag) Name() string        { return f.name }
+func (f completionFlag) Description() string { return f.description }
+func (f completionFlag) IsFile() bool        { return f.value == completionFlagValueFile }
+func (f completionFlag) IsVersion() bool     { return f.value == completionFlagValueVersion }
+func (f completionFlag) TakesValue() bool    { return f.value != completionFlagValueNone }
+
+var nonIdentCharsRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// completeVersions is the argument of the hidden __complete command which
+// lists the versions of the defined migrations.
+const completeVersions = "versions"
+
+// runCmdCompletion writes a completion script for the given shell. To
+// complete the values of the flags that take a migration version, the script
+// runs the tool (the __complete versions command), so that it completes the
+// versions of the migrations defined in the tool at the time of completion,
+// without needing a database connection.
+func runCmdCompletion(
+	output io.Writer,
+	shell string,
+	program string,
+) error {
+
+	tmpl, ok := completionTemplates[shell]
+	if !ok {
+		return fmt.Errorf(
+			"unsupported shell %q (supported: %s)",
+			shell, strings.Join(completionShells, ", "))
+	}
+
+	data := completionData{
+		Program:  program,
+		FuncName: "_" + nonIdentCharsRegexp.ReplaceAllString(program, "_"),
+		Flags:    completionFlags,
+		Shells:   completionShells,
+	}
+	for _, cmd := range allCommands {
+		data.Commands = append(data.Commands, completionCommand{
+			Name:        cmd,
+			Description: commandDescriptions[cmd],
+		})
+	}
+
+	if err := tmpl.Execute(output, data); err != nil {
+		return fmt.Errorf("failed to generate %s completion script: %w", shell, err)
+	}
+
+	return nil
+}
+
+// runCmdComplete writes the candidates of a completion, one per line, for
+// the completion scripts: the versions of the migrations, in ascending order.
+func runCmdComplete(output io.Writer, what string, versions []int) error {
+	if what != completeVersions {
+		return fmt.Errorf("unknown completion: %q (expected %s)", what, completeVersions)
+	}
+
+	for _, v := range slices.Sorted(slices.Values(versions)) {
+		_, _ = fmt.Fprintln(output, strconv.Itoa(v))
+	}
+
+	return nil
+}
+
+var completionTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"commandNames": func(cmds []completionCommand) string {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name
+		}
+		return strings.Join(names, " ")
+	},
+	"flagNames": func(flags []completionFlag) string {
+		names := make([]string, len(flags))
+		for i, flag := range flags {
+			names[i] = "--" + flag.name
+		}
+		return strings.Join(names, " ")
+	},
+}
+
+var completionTemplates = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Funcs(completionTemplateFuncs).Parse(
+		`# bash completion for {{.Program}}
+# Load it with: source <({{.Program}} completion bash)
+
+{{.FuncName}}() {
+    local cur prev
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+{{- range .Flags}}{{if .IsFile}}
+        --{{.Name}}) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+{{- else if .IsVersion}}
+        --{{.Name}}) COMPREPLY=($(compgen -W "$("${COMP_WORDS[0]}" __complete versions 2>/dev/null)" -- "$cur")); return ;;
+{{- else if .TakesValue}}
+        --{{.Name}}) return ;;
+{{- end}}{{end}}
+        completion) COMPREPLY=($(compgen -W "{{join .Shells " "}}" -- "$cur")); return ;;
+        goto|reset-batch) COMPREPLY=($(compgen -W "$("${COMP_WORDS[0]}" __complete versions 2>/dev/null)" -- "$cur")); return ;;
+    esac
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "{{flagNames .Flags}}" -- "$cur"))
+        return
+    fi
+
+    COMPREPLY=($(compgen -W "{{commandNames .Commands}}" -- "$cur"))
+}
+
+complete -F {{.FuncName}} {{.Program}}
+`)),
+
+	"zsh": template.Must(template.New("zsh").Funcs(completionTemplateFuncs).Parse(
+		`#compdef {{.Program}}
+# zsh completion for {{.Program}}
+# Load it with: source <({{.Program}} completion zsh)
+
+{{.FuncName}}() {
+    local -a commands flags
+    commands=(
+{{- range .Commands}}
+        '{{.Name}}:{{.Description}}'
+{{- end}}
+    )
+    flags=(
+{{- range .Flags}}
+        '--{{.Name}}:{{.Description}}'
+{{- end}}
+    )
+
+    case "${words[CURRENT-1]}" in
+{{- range .Flags}}{{if .IsFile}}
+        --{{.Name}}) _files; return ;;
+{{- else if .IsVersion}}
+        --{{.Name}}) compadd -- $("${words[1]}" __complete versions 2>/dev/null); return ;;
+{{- else if .TakesValue}}
+        --{{.Name}}) return ;;
+{{- end}}{{end}}
+        completion) compadd -- {{join .Shells " "}}; return ;;
+        goto|reset-batch) compadd -- $("${words[1]}" __complete versions 2>/dev/null); return ;;
+    esac
+
+    if [[ "$PREFIX" == -* ]]; then
+        _describe 'flag' flags
+        return
+    fi
+
+    _describe 'command' commands
+}
+
+compdef {{.FuncName}} {{.Program}}
+`)),
+
+	"fish": template.Must(template.New("fish").Funcs(completionTemplateFuncs).Parse(
+		`# fish completion for {{.Program}}
+# Load it with: {{.Program}} completion fish | source
+
+complete -c {{.Program}} -f
+{{- range .Commands}}
+complete -c {{$.Program}} -n 'not __fish_seen_subcommand_from {{commandNames $.Commands}}' -a {{.Name}} -d '{{.Description}}'
+{{- end}}
+complete -c {{.Program}} -n '__fish_seen_subcommand_from completion' -a '{{join .Shells " "}}'
+complete -c {{.Program}} -n '__fish_seen_subcommand_from goto reset-batch' -a '(set -l cmd (commandline -opc)[1]; $cmd __complete versions 2>/dev/null)'
+{{- range .Flags}}{{if .IsFile}}
+complete -c {{$.Program}} -l {{.Name}} -r -F -d '{{.Description}}'
+{{- else if .IsVersion}}
+complete -c {{$.Program}} -l {{.Name}} -x -a '(set -l cmd (commandline -opc)[1]; $cmd __complete versions 2>/dev/null)' -d '{{.Description}}'
+{{- else if .TakesValue}}
+complete -c {{$.Program}} -l {{.Name}} -x -d '{{.Description}}'
+{{- else}}
+complete -c {{$.Program}} -l {{.Name}} -d '{{.Description}}'
+{{- end}}{{end}}
+`)),
+}
@@ -0,0 +1,104 @@
+package gosmig
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCmdCompletion(t *testing.T) {
+	testCases := []struct {
+		name         string
+		shell        string
+		wantContains []string
+		wantErr      string
+	}{
+		{
+			name:  "bash",
+			shell: "bash",
+			wantContains: []string{
+				"_my_migrator() {",
+				`--config) COMPREPLY=($(compgen -f -- "$cur")); return ;;`,
+				"--env) return ;;",
+				`--upto) COMPREPLY=($(compgen -W "$("${COMP_WORDS[0]}" __complete versions 2>/dev/null)" -- "$cur")); return ;;`,
+				`completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")); return ;;`,
+				`goto|reset-batch) COMPREPLY=($(compgen -W "$("${COMP_WORDS[0]}" __complete versions 2>/dev/null)" -- "$cur")); return ;;`,
+				`COMPREPLY=($(compgen -W "--config --env --exit-code --sql --no-tx --atomic --phase --upto --tags --set --force --version --since --until --direction --json" -- "$cur"))`,
+				`COMPREPLY=($(compgen -W "up up-one down goto status plan version check history unlock reset-batch completion validate create squash seed seed-status" -- "$cur"))`,
+				"complete -F _my_migrator my-migrator",
+			},
+		},
+		{
+			name:  "zsh",
+			shell: "zsh",
+			wantContains: []string{
+				"#compdef my-migrator",
+				"'up:Apply all pending migrations'",
+				"'--config:Path of the config file'",
+				"--config) _files; return ;;",
+				`--upto) compadd -- $("${words[1]}" __complete versions 2>/dev/null); return ;;`,
+				"compdef _my_migrator my-migrator",
+			},
+		},
+		{
+			name:  "fish",
+			shell: "fish",
+			wantContains: []string{
+				"complete -c my-migrator -f",
+				"complete -c my-migrator -n 'not __fish_seen_subcommand_from " +
+					"up up-one down goto status plan version check history unlock reset-batch completion validate create squash seed seed-status' -a down -d 'Roll back the most recent migration'",
+				"complete -c my-migrator -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'",
+				"complete -c my-migrator -l config -r -F -d 'Path of the config file'",
+				"complete -c my-migrator -l env -x -d 'Environment to use from the config file'",
+				"complete -c my-migrator -l upto -x " +
+					"-a '(set -l cmd (commandline -opc)[1]; $cmd __complete versions 2>/dev/null)' " +
+					"-d 'Version to squash the migrations up to'",
+				"complete -c my-migrator -l force -d 'Run even if unknown migrations are applied above the target version'",
+			},
+		},
+		{
+			name:    "unsupported shell",
+			shell:   "powershell",
+			wantErr: `unsupported shell "powershell" (supported: bash, zsh, fish)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := runCmdCompletion(&output, tc.shell, "my-migrator")
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			for _, want := range tc.wantContains {
+				require.Contains(t, output.String(), want)
+			}
+		})
+	}
+
+	t.Run("write error", func(t *testing.T) {
+		err := runCmdCompletion(failingWriter{}, "bash", "my-migrator")
+		require.ErrorContains(t, err, "failed to generate bash completion script")
+	})
+}
+
+func TestRunCmdComplete(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, runCmdComplete(&output, "versions", []int{10, 1, 2}))
+	require.Equal(t, "1\n2\n10\n", output.String())
+
+	err := runCmdComplete(&output, "tags", nil)
+	require.EqualError(t, err, `unknown completion: "tags" (expected versions)`)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
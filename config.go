@@ -1,16 +1,132 @@
 package gosmig
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
 
 const defaultTimeout = 10 * time.Second
 
+// ConfigFileDecoder decodes the raw content of a config file into v.
+// Its signature matches json.Unmarshal, yaml.Unmarshal (gopkg.in/yaml.v3) and
+// toml.Unmarshal (github.com/BurntSushi/toml), so those can be used as is.
+type ConfigFileDecoder func(data []byte, v any) error
+
+// VersioningScheme is how the create command numbers new migrations.
+type VersioningScheme string
+
+const (
+	// VersioningSequential numbers new migrations with the next version
+	// after the highest existing one (e.g. 1, 2, 3).
+	VersioningSequential VersioningScheme = "sequential"
+
+	// VersioningTimestamp numbers new migrations with the current UTC time
+	// in the YYYYMMDDhhmmss format (e.g. 20261016093000), which avoids
+	// version conflicts between branches.
+	VersioningTimestamp VersioningScheme = "timestamp"
+)
+
+// CreateTemplates are the text/template sources used by the create command
+// to scaffold new migration files. Empty fields use the built-in templates.
+// The templates are executed with a CreateTemplateData.
+type CreateTemplates struct {
+	// Go is the template of Go migration files.
+	Go string
+	// SQLUp is the template of .up.sql migration files.
+	SQLUp string
+	// SQLDown is the template of .down.sql migration files.
+	SQLDown string
+}
+
 type Config struct {
+	// Timeout for database operations. Defaults to 10 seconds.
 	Timeout time.Duration
+
+	// TableName is the name of the table used to track the applied migrations.
+	// It may be schema-qualified (e.g. "myschema.gosmig"). Defaults to "gosmig".
+	TableName string
+
+	// Schema, if set, is the schema of a tenant in a schema-per-tenant setup
+	// (PostgreSQL). The tracking tables are then created in it, and the
+	// search_path is set to it (SET LOCAL) in the transaction of the migration
+	// functions. The migrating commands then refuse the UpDownNoTX migrations
+	// (see ErrSchemaNoTX). TableName must not be schema-qualified then.
+	Schema string
+
+	// LockTimeout is how long to wait for the migrations lock before giving up.
+	// Zero (the default) disables locking.
+	LockTimeout time.Duration
+
+	// MigrationsDir is the directory holding the SQL migration files (and
+	// their gosmig.sum file), if any. The validate command checks them.
+	MigrationsDir string
+
+	// Versioning is the versioning scheme used by the create command.
+	// Defaults to VersioningSequential.
+	Versioning VersioningScheme
+
+	// Tags is the tag selector: only the untagged migrations and the ones
+	// with one of these tags are applied, the others are skipped (see
+	// Migration.Tags). Empty selects all the migrations. It can also be set
+	// from the command line with the --tags flag (e.g. --tags prod,eu).
+	// Skipped migrations are recorded as applied, so changing the selector
+	// later doesn't apply them.
+	Tags []string
+
+	// AllowUnknownVersions makes up, up-one and down run even if migrations
+	// unknown to the code are applied above the version they target (e.g.
+	// when an older release is deployed against a database migrated by a
+	// newer one), which they otherwise refuse with ErrDBAhead. It can also be
+	// set from the command line with the --force flag.
+	AllowUnknownVersions bool
+
+	// CreateTemplates overrides the templates used by the create command.
+	CreateTemplates CreateTemplates
+
+	// SchemaDumper dumps the database schema for the squash command
+	// (e.g. PGDumpSchemaDumper or SQLiteSchemaDumper).
+	SchemaDumper SchemaDumper
+
+	// ConfigFile is the path of a config file with named environments.
+	// It can also be set from the command line with the --config flag.
+	ConfigFile string
+
+	// Env is the name of the environment to use from the config file.
+	// It can also be set from the command line with the --env flag.
+	Env string
+
+	// ConfigFileDecoders maps config file extensions (e.g. ".ini") to their
+	// decoders. JSON (".json"), YAML (".yaml", ".yml") and TOML (".toml") are
+	// supported out of the box; decoders set here take precedence.
+	ConfigFileDecoders map[string]ConfigFileDecoder
+
+	// TracerProvider, if set, traces the commands with OpenTelemetry: one span
+	// per command, with a child span per migration applied or rolled back.
+	// The context passed to the migration functions carries the span of the
+	// migration. Nil (the default) disables tracing.
+	TracerProvider trace.TracerProvider
+
+	// Metrics, if set, receives the metrics of the commands run against the
+	// database (e.g. promadapter.Collector for Prometheus).
+	Metrics Metrics
+
+	// History, if set, records every migration applied or rolled back, and
+	// every failed attempt, in an append-only table named after the
+	// migrations table (e.g. gosmig_history), which the history command
+	// shows. Unlike the migrations table, it keeps the migrations which were
+	// rolled back.
+	History bool
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		Timeout: defaultTimeout,
+		Timeout:    defaultTimeout,
+		TableName:  migrationsTableName,
+		Versioning: VersioningSequential,
 	}
 }
 
@@ -18,4 +134,55 @@ func (c *Config) ensureDefaults() {
 	if c.Timeout <= 0 {
 		c.Timeout = defaultTimeout
 	}
+	if c.TableName == "" {
+		c.TableName = migrationsTableName
+	}
+	if c.Versioning == "" {
+		c.Versioning = VersioningSequential
+	}
+}
+
+var (
+	tableNameRegexp = regexp.MustCompile(
+		`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	schemaRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// migrationsTable is the name of the migrations table, qualified with the
+// schema if there's one.
+func (c *Config) migrationsTable() string {
+	if c.Schema != "" {
+		return c.Schema + "." + c.TableName
+	}
+	return c.TableName
+}
+
+func (c *Config) validate() error {
+	if !tableNameRegexp.MatchString(c.TableName) {
+		return fmt.Errorf("%w: invalid migrations table name: %q", ErrInvalidConfig, c.TableName)
+	}
+
+	if c.Schema != "" {
+		if !schemaRegexp.MatchString(c.Schema) {
+			return fmt.Errorf("%w: invalid schema: %q", ErrInvalidConfig, c.Schema)
+		}
+		if strings.Contains(c.TableName, ".") {
+			return fmt.Errorf("%w: the migrations table name %q must not be schema-qualified "+
+				"when a schema is set", ErrInvalidConfig, c.TableName)
+		}
+	}
+
+	if c.LockTimeout < 0 {
+		return fmt.Errorf("%w: lock timeout must be >= 0", ErrInvalidConfig)
+	}
+
+	if c.Versioning != VersioningSequential && c.Versioning != VersioningTimestamp {
+		return fmt.Errorf("%w: unknown versioning scheme: %q", ErrInvalidConfig, c.Versioning)
+	}
+
+	if err := validateTags(c.Tags); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	return nil
 }
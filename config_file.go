@@ -0,0 +1,160 @@
+package gosmig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	configFile struct {
+		Environments map[string]configFileEnv `json:"environments" yaml:"environments" toml:"environments"`
+	}
+
+	configFileEnv struct {
+		URL         string   `json:"url" yaml:"url" toml:"url"`
+		TableName   string   `json:"table" yaml:"table" toml:"table"`
+		Schema      string   `json:"schema" yaml:"schema" toml:"schema"`
+		Timeout     string   `json:"timeout" yaml:"timeout" toml:"timeout"`
+		LockTimeout string   `json:"lock_timeout" yaml:"lock_timeout" toml:"lock_timeout"`
+		Tags        []string `json:"tags" yaml:"tags" toml:"tags"`
+	}
+)
+
+// defaultConfigFileDecoders are the decoders of the config file formats
+// supported out of the box, keyed by file extension.
+var defaultConfigFileDecoders = map[string]ConfigFileDecoder{
+	".json": json.Unmarshal,
+	".yaml": yaml.Unmarshal,
+	".yml":  yaml.Unmarshal,
+	".toml": toml.Unmarshal,
+}
+
+var envVarRefRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// applyConfigFile loads the environment selected by config.Env from
+// config.ConfigFile, overrides the config fields it defines and returns the
+// database URL it defines (if any).
+func applyConfigFile(config *Config) (string, error) {
+	data, err := os.ReadFile(config.ConfigFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(config.ConfigFile))
+	decode, ok := config.ConfigFileDecoders[ext]
+	if !ok {
+		if decode, ok = defaultConfigFileDecoders[ext]; !ok {
+			return "", fmt.Errorf("no decoder configured for config file extension %q", ext)
+		}
+	}
+
+	var cf configFile
+	if err := decode(data, &cf); err != nil {
+		return "", fmt.Errorf("failed to decode config file %s: %w", config.ConfigFile, err)
+	}
+
+	env, err := cf.selectEnv(config.Env)
+	if err != nil {
+		return "", err
+	}
+
+	if err := env.expandEnvVars(); err != nil {
+		return "", err
+	}
+
+	if env.TableName != "" {
+		config.TableName = env.TableName
+	}
+
+	if env.Schema != "" {
+		config.Schema = env.Schema
+	}
+
+	if env.Timeout != "" {
+		if config.Timeout, err = time.ParseDuration(env.Timeout); err != nil {
+			return "", fmt.Errorf("invalid timeout in config file: %w", err)
+		}
+		config.ensureDefaults()
+	}
+
+	if env.LockTimeout != "" {
+		if config.LockTimeout, err = time.ParseDuration(env.LockTimeout); err != nil {
+			return "", fmt.Errorf("invalid lock_timeout in config file: %w", err)
+		}
+	}
+
+	if len(env.Tags) > 0 {
+		config.Tags = env.Tags
+	}
+
+	return env.URL, config.validate()
+}
+
+func (cf configFile) selectEnv(name string) (configFileEnv, error) {
+	if name == "" {
+		if len(cf.Environments) != 1 {
+			return configFileEnv{}, fmt.Errorf(
+				"config file defines %d environments, select one with --env",
+				len(cf.Environments))
+		}
+		for _, env := range cf.Environments {
+			return env, nil
+		}
+	}
+
+	env, ok := cf.Environments[name]
+	if !ok {
+		names := make([]string, 0, len(cf.Environments))
+		for n := range cf.Environments {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return configFileEnv{}, fmt.Errorf(
+			"environment %q not found in config file (available: %s)",
+			name, strings.Join(names, ", "))
+	}
+
+	return env, nil
+}
+
+// expandEnvVars replaces ${VAR} references with the values of the
+// corresponding environment variables. Unset variables are reported as errors.
+func (env *configFileEnv) expandEnvVars() error {
+	var missing []string
+	expand := func(s string) string {
+		return envVarRefRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+			name := envVarRefRegexp.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return value
+		})
+	}
+
+	env.URL = expand(env.URL)
+	env.TableName = expand(env.TableName)
+	env.Schema = expand(env.Schema)
+	env.Timeout = expand(env.Timeout)
+	env.LockTimeout = expand(env.LockTimeout)
+	for i, tag := range env.Tags {
+		env.Tags[i] = expand(tag)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"config file references unset environment variable(s): %s",
+			strings.Join(missing, ", "))
+	}
+
+	return nil
+}
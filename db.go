@@ -2,7 +2,10 @@ package gosmig
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -39,30 +42,316 @@ type (
 	}
 )
 
-const (
-	migrationsTableName = "gosmig"
+const migrationsTableName = "gosmig"
 
-	// SQL statements
-	createMigsTblSQL = `CREATE TABLE IF NOT EXISTS ` + migrationsTableName +
-		` (
-		version INTEGER PRIMARY KEY,
+// SQL statements
+
+// trackingTables are the tables gosmig creates for its own bookkeeping, which
+// are not part of the application schema.
+func trackingTables(config *Config) []string {
+	table := config.migrationsTable()
+	return []string{
+		table,
+		lockTableName(table),
+		repeatableTableName(table),
+		seedTableName(table),
+		batchTableName(table),
+		graphTableName(table),
+		historyTableName(table),
+	}
+}
+
+func createSchemaSQL(schema string) string {
+	return "CREATE SCHEMA IF NOT EXISTS " + schema
+}
+
+// setSearchPathSQL sets the search_path to the schema, for the current
+// transaction.
+func setSearchPathSQL(schema string) string {
+	return "SET LOCAL search_path TO " + schema
+}
+
+// repeatableTableName is the name of the table tracking the repeatable
+// migrations by name and checksum, next to the migrations table.
+func repeatableTableName(table string) string {
+	return table + "_repeatable"
+}
+
+func createMigsTblSQL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		version BIGINT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		tags TEXT NOT NULL DEFAULT '',
+		contracted_at TIMESTAMPTZ,
+		checksum VARCHAR(64) NOT NULL DEFAULT ''
+	)`
+}
+
+// addTagsColumnSQL adds the tags column to migrations tables created before it existed.
+func addTagsColumnSQL(table string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT ''"
+}
+
+// addContractedAtColumnSQL adds the contracted_at column, recording when the
+// contract phase of a migration was applied, to migrations tables created
+// before it existed.
+func addContractedAtColumnSQL(table string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS contracted_at TIMESTAMPTZ"
+}
+
+// addChecksumColumnSQL adds the checksum column, recording the checksum of
+// the migrations which have one when they're applied, to migrations tables
+// created before it existed.
+func addChecksumColumnSQL(table string) string {
+	return "ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS checksum VARCHAR(64) NOT NULL DEFAULT ''"
+}
+
+// selectColumnsSQL returns the comma-separated columns of the table (in the
+// given schema, or else the current one), none if it doesn't exist.
+func selectColumnsSQL() string {
+	return `SELECT COALESCE(STRING_AGG(column_name::text, ','), '') FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2`
+}
+
+// selectVersionIntegerSQL returns 1 if the version column of the migrations
+// table (in the given schema, or else the current one) is still an INTEGER,
+// as in the tables created before VersioningTimestamp, or else 0.
+func selectVersionIntegerSQL() string {
+	return `SELECT COUNT(*) FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema())
+			AND table_name = $2 AND column_name = 'version' AND data_type = 'integer'`
+}
+
+// alterVersionBigintSQL widens the version column of migrations tables
+// created when it was an INTEGER, which can't hold VersioningTimestamp
+// versions.
+func alterVersionBigintSQL(table string) string {
+	return "ALTER TABLE " + table + " ALTER COLUMN version TYPE BIGINT"
+}
+
+func updateMigChecksumSQL(table string) string {
+	return "UPDATE " + table + " SET checksum = $2 WHERE version = $1"
+}
+
+// selectAppliedVersionsSQL returns the comma-separated versions of the
+// applied migrations, in a single row.
+func selectAppliedVersionsSQL(table string) string {
+	return "SELECT COALESCE(STRING_AGG(CAST(version AS TEXT), ',' ORDER BY version), '') FROM " + table
+}
+
+// selectAppliedChecksumsSQL returns the comma-separated versions of the
+// applied migrations, each followed by a colon and its checksum (empty if it
+// has none), in a single row.
+func selectAppliedChecksumsSQL(table string) string {
+	return "SELECT COALESCE(STRING_AGG(CAST(version AS TEXT) || ':' || checksum, ',' " +
+		"ORDER BY version), '') FROM " + table
+}
+
+// selectMigContractedSQL counts 1 if the contract phase of the migration was
+// applied, and 0 otherwise.
+func selectMigContractedSQL(table string) string {
+	return "SELECT COUNT(contracted_at) FROM " + table + " WHERE version = $1"
+}
+
+func updateMigContractedSQL(table string) string {
+	return "UPDATE " + table + " SET contracted_at = NOW() WHERE version = $1"
+}
+
+func updateMigUncontractedSQL(table string) string {
+	return "UPDATE " + table + " SET contracted_at = NULL WHERE version = $1"
+}
+
+func selectMigTagsSQL(table string) string {
+	return "SELECT COALESCE(MAX(tags), '') FROM " + table + " WHERE version = $1"
+}
+
+// tableExistsSQL counts 1 if the table exists (PostgreSQL).
+func tableExistsSQL() string {
+	return "SELECT CASE WHEN to_regclass($1) IS NULL THEN 0 ELSE 1 END"
+}
+
+func selectDBVersionSQL(table string) string {
+	return "SELECT COALESCE(MAX(version), 0) FROM " + table
+}
+
+func insertMigVersionSQL(table string) string {
+	return "INSERT INTO " + table + " (version) VALUES ($1)"
+}
+
+func insertMigVersionTagsSQL(table string) string {
+	return "INSERT INTO " + table + " (version, tags) VALUES ($1, $2)"
+}
+
+func deleteMigVersionSQL(table string) string {
+	return "DELETE FROM " + table + " WHERE version = $1"
+}
+
+func createRepeatableTblSQL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		name VARCHAR(255) PRIMARY KEY,
+		checksum VARCHAR(64) NOT NULL,
 		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 	)`
-	selectDBVersionSQL  = "SELECT COALESCE(MAX(version), 0) FROM " + migrationsTableName
-	insertMigVersionSQL = "INSERT INTO " + migrationsTableName + " (version) VALUES ($1)"
-	deleteMigVersionSQL = "DELETE FROM " + migrationsTableName + " WHERE version = $1"
-)
+}
+
+// selectRepeatableChecksumSQL returns an empty checksum for repeatable
+// migrations which were never applied, so a row is always returned.
+func selectRepeatableChecksumSQL(table string) string {
+	return "SELECT COALESCE(MAX(checksum), '') FROM " + table + " WHERE name = $1"
+}
+
+func upsertRepeatableSQL(table string) string {
+	return "INSERT INTO " + table + " (name, checksum) VALUES ($1, $2) " +
+		"ON CONFLICT (name) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = NOW()"
+}
+
+// seedTableName is the name of the table tracking the applied seed sets, next
+// to the migrations table.
+func seedTableName(table string) string {
+	return table + "_seed"
+}
+
+// batchTableName is the name of the table recording the cursor of the batched
+// migrations in progress, next to the migrations table.
+func batchTableName(table string) string {
+	return table + "_batch"
+}
+
+func createBatchTblSQL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		version BIGINT PRIMARY KEY,
+		next_cursor TEXT NOT NULL,
+		batches BIGINT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`
+}
+
+// selectBatchCursorSQL returns an empty cursor and no batches for batched
+// migrations which were not started, so a row is always returned.
+func selectBatchCursorSQL(table string) string {
+	return "SELECT COALESCE(MAX(next_cursor), ''), COALESCE(MAX(batches), 0) FROM " + table +
+		" WHERE version = $1"
+}
+
+// lockBatchCursorSQL is like selectBatchCursorSQL, but locks the row of the
+// cursor, if any, until the end of the transaction.
+func lockBatchCursorSQL(table string) string {
+	return "SELECT COALESCE(MAX(next_cursor), ''), COALESCE(MAX(batches), 0) FROM " +
+		"(SELECT next_cursor, batches FROM " + table + " WHERE version = $1 FOR UPDATE) AS c"
+}
+
+func upsertBatchCursorSQL(table string) string {
+	return "INSERT INTO " + table + " (version, next_cursor, batches) VALUES ($1, $2, $3) " +
+		"ON CONFLICT (version) DO UPDATE SET next_cursor = EXCLUDED.next_cursor, " +
+		"batches = EXCLUDED.batches, updated_at = NOW()"
+}
+
+func deleteBatchCursorSQL(table string) string {
+	return "DELETE FROM " + table + " WHERE version = $1"
+}
+
+// graphTableName is the name of the table tracking the applied migrations by
+// ID, instead of by version, when they have modules or dependencies.
+func graphTableName(table string) string {
+	return table + "_graph"
+}
+
+func createGraphTblSQL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		id VARCHAR(255) PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`
+}
+
+// selectAppliedIDsSQL returns the comma-separated IDs of the applied migrations.
+func selectAppliedIDsSQL(table string) string {
+	return "SELECT COALESCE(STRING_AGG(id, ','), '') FROM " + table
+}
+
+func selectAppliedIDCountSQL(table string) string {
+	return "SELECT COUNT(*) FROM " + table + " WHERE id = $1"
+}
+
+func insertAppliedIDSQL(table string) string {
+	return "INSERT INTO " + table + " (id) VALUES ($1)"
+}
+
+func deleteAppliedIDSQL(table string) string {
+	return "DELETE FROM " + table + " WHERE id = $1"
+}
+
+// historyTableName is the name of the append-only table recording every
+// migration applied or rolled back, and every failed attempt, next to the
+// migrations table.
+func historyTableName(table string) string {
+	return table + "_history"
+}
+
+func createHistoryTblSQL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		id BIGSERIAL PRIMARY KEY,
+		version BIGINT NOT NULL,
+		module VARCHAR(255) NOT NULL DEFAULT '',
+		direction VARCHAR(4) NOT NULL,
+		phase VARCHAR(8) NOT NULL DEFAULT '',
+		started_at TIMESTAMPTZ NOT NULL,
+		duration_ms BIGINT NOT NULL,
+		db_user VARCHAR(255) NOT NULL DEFAULT CURRENT_USER,
+		os_user VARCHAR(255) NOT NULL DEFAULT '',
+		outcome VARCHAR(8) NOT NULL,
+		error TEXT NOT NULL DEFAULT ''
+	)`
+}
+
+func insertHistorySQL(table string) string {
+	return "INSERT INTO " + table +
+		" (version, module, direction, phase, started_at, duration_ms, os_user, outcome, error) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+}
+
+// selectHistorySQL returns the history entries matching the conditions (with
+// positional arguments), oldest first, as a JSON array in a single row.
+func selectHistorySQL(table string, conditions []string) string {
+	var where string
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return "SELECT COALESCE(JSON_AGG(h ORDER BY h.id), '[]')::TEXT FROM (" +
+		"SELECT id, version, module, direction, phase, started_at, duration_ms, db_user, " +
+		"os_user, outcome, error FROM " + table + where + ") h"
+}
+
+func createSeedTblSQL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		name VARCHAR(255) PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`
+}
+
+func selectSeedSetCountSQL(table string) string {
+	return "SELECT COUNT(*) FROM " + table + " WHERE name = $1"
+}
+
+func insertSeedSetSQL(table string) string {
+	return "INSERT INTO " + table + " (name) VALUES ($1)"
+}
 
 func createMigrationsTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
 	ctx context.Context,
 	dbOrTX DBOrTX[TDBRow, TDBResult],
-	timeout time.Duration,
+	config *Config,
 ) error {
 
-	ctxCreateMigsTbl, cancelCreateMigsTable := context.WithTimeout(ctx, timeout)
+	ctxCreateMigsTbl, cancelCreateMigsTable := context.WithTimeout(ctx, config.Timeout)
 	defer cancelCreateMigsTable()
 
-	_, err := dbOrTX.ExecContext(ctxCreateMigsTbl, createMigsTblSQL)
+	if config.Schema != "" {
+		if _, err := dbOrTX.ExecContext(ctxCreateMigsTbl, createSchemaSQL(config.Schema)); err != nil {
+			return fmt.Errorf("failed to create schema %s if not exists: %w", config.Schema, err)
+		}
+	}
+
+	_, err := dbOrTX.ExecContext(ctxCreateMigsTbl, createMigsTblSQL(config.migrationsTable()))
 	if err != nil {
 		return fmt.Errorf("failed to create migrations table if not exists: %w", err)
 	}
@@ -70,16 +359,712 @@ func createMigrationsTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
 	return nil
 }
 
+// setSearchPath sets the search_path to Config.Schema, if any, for the
+// transaction dbOrTX, so that the migrations create and use the objects of
+// the tenant schema. It fails with ErrSchemaNoTX if dbOrTX isn't a
+// transaction (see checkSchemaNoTX).
+func setSearchPath[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	if config.Schema == "" {
+		return nil
+	}
+
+	if _, ok := dbOrTX.(interface{ Commit() error }); !ok {
+		return fmt.Errorf("%w (schema %s)", ErrSchemaNoTX, config.Schema)
+	}
+
+	ctxSet, cancelSet := context.WithTimeout(ctx, config.Timeout)
+	defer cancelSet()
+
+	if _, err := dbOrTX.ExecContext(ctxSet, setSearchPathSQL(config.Schema)); err != nil {
+		return fmt.Errorf("failed to set search_path to schema %s: %w", config.Schema, err)
+	}
+
+	return nil
+}
+
+// checkSchemaNoTX checks, before migrating, that no migration runs without a
+// transaction if Config.Schema is set (see ErrSchemaNoTX).
+func checkSchemaNoTX[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	config *Config,
+) error {
+
+	if config.Schema == "" {
+		return nil
+	}
+
+	for _, migration := range migrations {
+		if migration.UpDownNoTX != nil {
+			return fmt.Errorf(
+				"%w (schema %s): migration %s runs without a transaction (UpDownNoTX); "+
+					"run it with UpDown, or migrate the schema with a database URL setting its search_path "+
+					"and without Config.Schema",
+				ErrSchemaNoTX, config.Schema, migration.ID())
+		}
+	}
+
+	return nil
+}
+
+func addTagsColumnIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxAddColumn, cancelAddColumn := context.WithTimeout(ctx, config.Timeout)
+	defer cancelAddColumn()
+
+	_, err := dbOrTX.ExecContext(ctxAddColumn, addTagsColumnSQL(config.migrationsTable()))
+	if err != nil {
+		return fmt.Errorf("failed to add tags column to migrations table: %w", err)
+	}
+
+	return nil
+}
+
+func addContractedAtColumnIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxAddColumn, cancelAddColumn := context.WithTimeout(ctx, config.Timeout)
+	defer cancelAddColumn()
+
+	_, err := dbOrTX.ExecContext(ctxAddColumn, addContractedAtColumnSQL(config.migrationsTable()))
+	if err != nil {
+		return fmt.Errorf("failed to add contracted_at column to migrations table: %w", err)
+	}
+
+	return nil
+}
+
+func addChecksumColumnIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxAddColumn, cancelAddColumn := context.WithTimeout(ctx, config.Timeout)
+	defer cancelAddColumn()
+
+	_, err := dbOrTX.ExecContext(ctxAddColumn, addChecksumColumnSQL(config.migrationsTable()))
+	if err != nil {
+		return fmt.Errorf("failed to add checksum column to migrations table: %w", err)
+	}
+
+	return nil
+}
+
+// alterVersionBigintIfNeeded widens the version column of the migrations
+// table if it's still an INTEGER: altering it takes an exclusive lock on the
+// table, even when it's already a BIGINT.
+func alterVersionBigintIfNeeded[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxCheckColumn, cancelCheckColumn := context.WithTimeout(ctx, config.Timeout)
+	defer cancelCheckColumn()
+	var integer int
+	err := dbOrTX.QueryRowContext(ctxCheckColumn, selectVersionIntegerSQL(), config.Schema, config.TableName).
+		Scan(&integer)
+	if err != nil {
+		return fmt.Errorf("failed to check the type of the version column of migrations table: %w", err)
+	}
+	if integer == 0 {
+		return nil
+	}
+
+	ctxAlterColumn, cancelAlterColumn := context.WithTimeout(ctx, config.Timeout)
+	defer cancelAlterColumn()
+
+	_, err = dbOrTX.ExecContext(ctxAlterColumn, alterVersionBigintSQL(config.migrationsTable()))
+	if err != nil {
+		return fmt.Errorf("failed to change the version column of migrations table to BIGINT: %w", err)
+	}
+
+	return nil
+}
+
+// setMigrationChecksum records the checksum of the applied migration with
+// the given version.
+func setMigrationChecksum[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	checksum string,
+	config *Config,
+) error {
+
+	ctxUpdate, cancelUpdate := context.WithTimeout(ctx, config.Timeout)
+	defer cancelUpdate()
+	_, err := dbOrTX.ExecContext(ctxUpdate, updateMigChecksumSQL(config.migrationsTable()), version, checksum)
+	if err != nil {
+		return fmt.Errorf("failed to record the checksum of migration version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// getAppliedVersions returns the versions of the applied migrations, mapped
+// to their recorded checksums if withChecksums is set (empty if they have
+// none), or else to empty strings.
+func getAppliedVersions[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	withChecksums bool,
+	config *Config,
+) (map[int]string, error) {
+
+	query := selectAppliedVersionsSQL(config.migrationsTable())
+	if withChecksums {
+		query = selectAppliedChecksumsSQL(config.migrationsTable())
+	}
+
+	ctxGetVersions, cancelGetVersions := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetVersions()
+	var versions string
+	if err := dbOrTX.QueryRowContext(ctxGetVersions, query).Scan(&versions); err != nil {
+		return nil, fmt.Errorf("failed to get the applied migrations: %w", err)
+	}
+
+	applied := make(map[int]string)
+	for entry := range strings.SplitSeq(versions, ",") {
+		if entry == "" {
+			continue
+		}
+		versionStr, checksum, _ := strings.Cut(entry, ":")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid applied migration version %q: %w", versionStr, err)
+		}
+		applied[version] = checksum
+	}
+	return applied, nil
+}
+
+// isMigrationContracted tells whether the contract phase of the migration
+// with the given version was applied.
+func isMigrationContracted[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	config *Config,
+) (bool, error) {
+
+	ctxGet, cancelGet := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGet()
+	var count int
+	err := dbOrTX.QueryRowContext(ctxGet, selectMigContractedSQL(config.migrationsTable()), version).
+		Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf(
+			"failed to get contract phase of migration version %d: %w", version, err)
+	}
+	return count > 0, nil
+}
+
+// setMigrationContracted records whether the contract phase of the migration
+// with the given version is applied.
+func setMigrationContracted[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	contracted bool,
+	config *Config,
+) error {
+
+	query := updateMigContractedSQL(config.migrationsTable())
+	if !contracted {
+		query = updateMigUncontractedSQL(config.migrationsTable())
+	}
+
+	ctxSet, cancelSet := context.WithTimeout(ctx, config.Timeout)
+	defer cancelSet()
+	if _, err := dbOrTX.ExecContext(ctxSet, query, version); err != nil {
+		return fmt.Errorf(
+			"failed to record contract phase of migration version %d: %w", version, err)
+	}
+	return nil
+}
+
+// getMigrationTags returns the tag selector the migration with the given
+// version was applied (or skipped) with.
+func getMigrationTags[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	config *Config,
+) ([]string, error) {
+
+	ctxGetTags, cancelGetTags := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetTags()
+	var tags string
+	err := dbOrTX.QueryRowContext(ctxGetTags, selectMigTagsSQL(config.migrationsTable()), version).
+		Scan(&tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags of migration version %d: %w", version, err)
+	}
+	return parseTags(tags), nil
+}
+
+func createRepeatableTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxCreateTbl, cancelCreateTbl := context.WithTimeout(ctx, config.Timeout)
+	defer cancelCreateTbl()
+
+	_, err := dbOrTX.ExecContext(
+		ctxCreateTbl, createRepeatableTblSQL(repeatableTableName(config.migrationsTable())))
+	if err != nil {
+		return fmt.Errorf("failed to create repeatable migrations table if not exists: %w", err)
+	}
+
+	return nil
+}
+
+// getRepeatableStatus compares the given checksum of a repeatable migration
+// with the one recorded when it was last applied.
+func getRepeatableStatus[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	name, checksum string,
+	config *Config,
+) (repeatableStatus, error) {
+
+	ctxGetChecksum, cancelGetChecksum := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetChecksum()
+	var dbChecksum string
+	err := dbOrTX.QueryRowContext(ctxGetChecksum,
+		selectRepeatableChecksumSQL(repeatableTableName(config.migrationsTable())), name).
+		Scan(&dbChecksum)
+	if err != nil {
+		return "", fmt.Errorf(
+			"failed to get checksum of repeatable migration %s: %w", name, err)
+	}
+
+	switch dbChecksum {
+	case "":
+		return repeatablePending, nil
+	case checksum:
+		return repeatableApplied, nil
+	default:
+		return repeatableChanged, nil
+	}
+}
+
+func upsertRepeatable[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	name, checksum string,
+	config *Config,
+) error {
+
+	upsertCtx, cancelUpsert := context.WithTimeout(ctx, config.Timeout)
+	defer cancelUpsert()
+	_, err := dbOrTX.ExecContext(upsertCtx,
+		upsertRepeatableSQL(repeatableTableName(config.migrationsTable())), name, checksum)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to record repeatable migration %s into repeatable migrations table: %w",
+			name, err)
+	}
+
+	return nil
+}
+
+func createBatchTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxCreateTbl, cancelCreateTbl := context.WithTimeout(ctx, config.Timeout)
+	defer cancelCreateTbl()
+
+	_, err := dbOrTX.ExecContext(ctxCreateTbl, createBatchTblSQL(batchTableName(config.migrationsTable())))
+	if err != nil {
+		return fmt.Errorf("failed to create batched migrations table if not exists: %w", err)
+	}
+
+	return nil
+}
+
+// getBatchCursor returns the cursor of the next batch of the batched
+// migration with the given version, and the number of batches applied so far
+// ("" and 0 if it was not started).
+func getBatchCursor[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	config *Config,
+) (string, int, error) {
+
+	ctxGetCursor, cancelGetCursor := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetCursor()
+	var cursor string
+	var batches int
+	err := dbOrTX.QueryRowContext(ctxGetCursor,
+		selectBatchCursorSQL(batchTableName(config.migrationsTable())), version).
+		Scan(&cursor, &batches)
+	if err != nil {
+		return "", 0, fmt.Errorf(
+			"failed to get cursor of batched migration version %d: %w", version, err)
+	}
+	return cursor, batches, nil
+}
+
+// checkBatchCursor locks the cursor of the batched migration with the given
+// version in the transaction tx, and checks that it is still the given one,
+// with the given number of batches applied.
+func checkBatchCursor[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	tx DBOrTX[TDBRow, TDBResult],
+	version int,
+	cursor string,
+	batches int,
+	config *Config,
+) error {
+
+	ctxLockCursor, cancelLockCursor := context.WithTimeout(ctx, config.Timeout)
+	defer cancelLockCursor()
+	var lockedCursor string
+	var lockedBatches int
+	err := tx.QueryRowContext(ctxLockCursor,
+		lockBatchCursorSQL(batchTableName(config.migrationsTable())), version).
+		Scan(&lockedCursor, &lockedBatches)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to lock cursor of batched migration version %d: %w", version, err)
+	}
+
+	if lockedCursor != cursor || lockedBatches != batches {
+		return fmt.Errorf(
+			"%w: version %d: expected batch %d (cursor: %q), found batch %d (cursor: %q)",
+			ErrBatchCursorMoved, version, batches, cursor, lockedBatches, lockedCursor)
+	}
+
+	return nil
+}
+
+func upsertBatchCursor[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	cursor string,
+	batches int,
+	config *Config,
+) error {
+
+	upsertCtx, cancelUpsert := context.WithTimeout(ctx, config.Timeout)
+	defer cancelUpsert()
+	_, err := dbOrTX.ExecContext(upsertCtx,
+		upsertBatchCursorSQL(batchTableName(config.migrationsTable())), version, cursor, batches)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to record cursor of batched migration version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+func deleteBatchCursor[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	version int,
+	config *Config,
+) error {
+
+	deleteCtx, cancelDelete := context.WithTimeout(ctx, config.Timeout)
+	defer cancelDelete()
+	_, err := dbOrTX.ExecContext(deleteCtx,
+		deleteBatchCursorSQL(batchTableName(config.migrationsTable())), version)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to delete cursor of batched migration version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+func createGraphTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxCreateTbl, cancelCreateTbl := context.WithTimeout(ctx, config.Timeout)
+	defer cancelCreateTbl()
+
+	_, err := dbOrTX.ExecContext(ctxCreateTbl, createGraphTblSQL(graphTableName(config.migrationsTable())))
+	if err != nil {
+		return fmt.Errorf("failed to create migrations graph table if not exists: %w", err)
+	}
+
+	return nil
+}
+
+// getAppliedIDs returns the IDs of the applied migrations, when they are
+// tracked by ID.
+func getAppliedIDs[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) (map[string]bool, error) {
+
+	ctxGetIDs, cancelGetIDs := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetIDs()
+	var ids string
+	err := dbOrTX.QueryRowContext(ctxGetIDs, selectAppliedIDsSQL(graphTableName(config.migrationsTable()))).
+		Scan(&ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the applied migrations: %w", err)
+	}
+
+	applied := make(map[string]bool)
+	for id := range strings.SplitSeq(ids, ",") {
+		if id != "" {
+			applied[id] = true
+		}
+	}
+	return applied, nil
+}
+
+func isMigrationIDApplied[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	id string,
+	config *Config,
+) (bool, error) {
+
+	ctxGet, cancelGet := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGet()
+	var count int
+	err := dbOrTX.QueryRowContext(ctxGet,
+		selectAppliedIDCountSQL(graphTableName(config.migrationsTable())), id).
+		Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if migration %s is applied: %w", id, err)
+	}
+	return count > 0, nil
+}
+
+func createHistoryTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxCreateTbl, cancelCreateTbl := context.WithTimeout(ctx, config.Timeout)
+	defer cancelCreateTbl()
+
+	_, err := dbOrTX.ExecContext(ctxCreateTbl, createHistoryTblSQL(historyTableName(config.migrationsTable())))
+	if err != nil {
+		return fmt.Errorf("failed to create migrations history table if not exists: %w", err)
+	}
+
+	return nil
+}
+
+func insertHistoryEntry[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	entry historyEntry,
+	config *Config,
+) error {
+
+	insertCtx, cancelInsert := context.WithTimeout(ctx, config.Timeout)
+	defer cancelInsert()
+	_, err := dbOrTX.ExecContext(insertCtx, insertHistorySQL(historyTableName(config.migrationsTable())),
+		entry.Version, entry.Module, string(entry.Direction), entry.Phase, entry.StartedAt,
+		entry.DurationMS, entry.OSUser, entry.Outcome, entry.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %s %s in the history: %w",
+			entry.id(), entry.Direction, err)
+	}
+	return nil
+}
+
+func getHistory[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	filter historyFilter,
+	config *Config,
+) ([]historyEntry, error) {
+
+	conditions, args := filter.conditions()
+
+	ctxGetHistory, cancelGetHistory := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetHistory()
+	var entriesJSON string
+	err := dbOrTX.QueryRowContext(ctxGetHistory,
+		selectHistorySQL(historyTableName(config.migrationsTable()), conditions), args...).
+		Scan(&entriesJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the migrations history: %w", err)
+	}
+
+	var entries []historyEntry
+	if err := json.Unmarshal([]byte(entriesJSON), &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode the migrations history: %w", err)
+	}
+	return entries, nil
+}
+
+func insertAppliedID[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	id string,
+	config *Config,
+) error {
+
+	insertCtx, cancelInsert := context.WithTimeout(ctx, config.Timeout)
+	defer cancelInsert()
+	_, err := dbOrTX.ExecContext(insertCtx, insertAppliedIDSQL(graphTableName(config.migrationsTable())), id)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", id, err)
+	}
+	return nil
+}
+
+func deleteAppliedID[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	id string,
+	config *Config,
+) error {
+
+	deleteCtx, cancelDelete := context.WithTimeout(ctx, config.Timeout)
+	defer cancelDelete()
+	_, err := dbOrTX.ExecContext(deleteCtx, deleteAppliedIDSQL(graphTableName(config.migrationsTable())), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete migration %s: %w", id, err)
+	}
+	return nil
+}
+
+func createSeedTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxCreateTbl, cancelCreateTbl := context.WithTimeout(ctx, config.Timeout)
+	defer cancelCreateTbl()
+
+	_, err := dbOrTX.ExecContext(ctxCreateTbl, createSeedTblSQL(seedTableName(config.migrationsTable())))
+	if err != nil {
+		return fmt.Errorf("failed to create seed sets table if not exists: %w", err)
+	}
+
+	return nil
+}
+
+func isSeedSetApplied[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	name string,
+	config *Config,
+) (bool, error) {
+
+	ctxGetSeedSet, cancelGetSeedSet := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetSeedSet()
+	var count int
+	err := dbOrTX.QueryRowContext(ctxGetSeedSet,
+		selectSeedSetCountSQL(seedTableName(config.migrationsTable())), name).
+		Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to get status of seed set %s: %w", name, err)
+	}
+	return count > 0, nil
+}
+
+func insertSeedSet[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	name string,
+	config *Config,
+) error {
+
+	insertCtx, cancelInsert := context.WithTimeout(ctx, config.Timeout)
+	defer cancelInsert()
+	_, err := dbOrTX.ExecContext(insertCtx, insertSeedSetSQL(seedTableName(config.migrationsTable())), name)
+	if err != nil {
+		return fmt.Errorf("failed to insert seed set %s into seed sets table: %w", name, err)
+	}
+
+	return nil
+}
+
+func tableExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	table string,
+	config *Config,
+) (bool, error) {
+
+	ctxExists, cancelExists := context.WithTimeout(ctx, config.Timeout)
+	defer cancelExists()
+	var exists int
+	if err := dbOrTX.QueryRowContext(ctxExists, tableExistsSQL(), table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check if table %s exists: %w", table, err)
+	}
+	return exists > 0, nil
+}
+
+// getMigrationsColumns returns the columns of the migrations table, none if
+// it doesn't exist.
+func getMigrationsColumns[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) (map[string]bool, error) {
+
+	ctxGetColumns, cancelGetColumns := context.WithTimeout(ctx, config.Timeout)
+	defer cancelGetColumns()
+	var names string
+	err := dbOrTX.QueryRowContext(ctxGetColumns, selectColumnsSQL(), config.Schema, config.TableName).
+		Scan(&names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the columns of migrations table: %w", err)
+	}
+
+	columns := make(map[string]bool)
+	for name := range strings.SplitSeq(names, ",") {
+		if name != "" {
+			columns[name] = true
+		}
+	}
+	return columns, nil
+}
+
 func getDBVersion[TDBRow DBRow, TDBResult DBResult](
 	ctx context.Context,
 	dbOrTX DBOrTX[TDBRow, TDBResult],
-	timeout time.Duration,
+	config *Config,
 ) (int, error) {
 
-	ctxGetDBVersion, cancelGetDBVersion := context.WithTimeout(ctx, timeout)
+	ctxGetDBVersion, cancelGetDBVersion := context.WithTimeout(ctx, config.Timeout)
 	defer cancelGetDBVersion()
 	var dbVersion int
-	err := dbOrTX.QueryRowContext(ctxGetDBVersion, selectDBVersionSQL).
+	err := dbOrTX.QueryRowContext(ctxGetDBVersion, selectDBVersionSQL(config.migrationsTable())).
 		Scan(&dbVersion)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get current DB version: %w", err)
@@ -91,12 +1076,18 @@ func insertDBVersion[TDBRow DBRow, TDBResult DBResult](
 	ctx context.Context,
 	dbOrTX DBOrTX[TDBRow, TDBResult],
 	version int,
-	timeout time.Duration,
+	config *Config,
 ) error {
 
-	versionCtx, cancelVersion := context.WithTimeout(ctx, timeout)
+	versionCtx, cancelVersion := context.WithTimeout(ctx, config.Timeout)
 	defer cancelVersion()
-	_, err := dbOrTX.ExecContext(versionCtx, insertMigVersionSQL, version)
+	var err error
+	if len(config.Tags) > 0 {
+		_, err = dbOrTX.ExecContext(versionCtx,
+			insertMigVersionTagsSQL(config.migrationsTable()), version, formatTags(config.Tags))
+	} else {
+		_, err = dbOrTX.ExecContext(versionCtx, insertMigVersionSQL(config.migrationsTable()), version)
+	}
 	if err != nil {
 		return fmt.Errorf(
 			"failed to insert migration version %d into migrations table: %w",
@@ -110,12 +1101,12 @@ func deleteDBVersion[TDBRow DBRow, TDBResult DBResult](
 	ctx context.Context,
 	dbOrTX DBOrTX[TDBRow, TDBResult],
 	version int,
-	timeout time.Duration,
+	config *Config,
 ) error {
 
-	versionCtx, cancelVersion := context.WithTimeout(ctx, timeout)
+	versionCtx, cancelVersion := context.WithTimeout(ctx, config.Timeout)
 	defer cancelVersion()
-	_, err := dbOrTX.ExecContext(versionCtx, deleteMigVersionSQL, version)
+	_, err := dbOrTX.ExecContext(versionCtx, deleteMigVersionSQL(config.migrationsTable()), version)
 	if err != nil {
 		return fmt.Errorf(
 			"failed to delete migration version %d from migrations table: %w",
@@ -143,7 +1134,7 @@ func executeInTx[
 	var txOptions TTXO
 	tx, err := db.BeginTx(txCtx, txOptions)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return withPhase(PhaseBegin, fmt.Errorf("failed to begin transaction: %w", err))
 	}
 
 	if err := fn(ctx, tx); err != nil {
@@ -152,7 +1143,7 @@ func executeInTx[
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return withPhase(PhaseCommit, fmt.Errorf("failed to commit transaction: %w", err))
 	}
 
 	return nil
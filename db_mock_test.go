@@ -106,3 +106,6 @@ func (d *dbMock) Close() error {
 
 // Migration mock
 type migrationMock = Migration[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock]
+
+// Repeatable migration mock
+type repeatableMock = Repeatable[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock]
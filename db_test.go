@@ -10,10 +10,11 @@ import (
 )
 
 func TestCreateMigrationsTableIfNotExists(t *testing.T) {
-	expectedSQL := createMigsTblSQL
+	expectedSQL := createMigsTblSQL(migrationsTableName)
 
 	testCases := []struct {
 		name      string
+		schema    string
 		setupMock func(*dbOrTxMock, *dbResultMock)
 		wantErr   string
 	}{
@@ -35,6 +36,28 @@ func TestCreateMigrationsTableIfNotExists(t *testing.T) {
 			wantErr: "failed to create migrations table if not exists: " +
 				"database connection error",
 		},
+		{
+			name:   "success - schema and table created",
+			schema: "tenant_a",
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				dbOrTX.On("ExecContext", mock.Anything, "CREATE SCHEMA IF NOT EXISTS tenant_a").
+					Return(result, nil).
+					Once()
+				dbOrTX.On("ExecContext", mock.Anything, createMigsTblSQL("tenant_a.gosmig")).
+					Return(result, nil).
+					Once()
+			},
+		},
+		{
+			name:   "error - schema creation fails",
+			schema: "tenant_a",
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				dbOrTX.On("ExecContext", mock.Anything, "CREATE SCHEMA IF NOT EXISTS tenant_a").
+					Return(result, errors.New("permission denied")).
+					Once()
+			},
+			wantErr: "failed to create schema tenant_a if not exists: permission denied",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -44,8 +67,11 @@ func TestCreateMigrationsTableIfNotExists(t *testing.T) {
 
 			tc.setupMock(dbOrTX, result)
 
+			config := DefaultConfig()
+			config.Schema = tc.schema
+
 			ctx := context.Background()
-			err := createMigrationsTableIfNotExists(ctx, dbOrTX, defaultTimeout)
+			err := createMigrationsTableIfNotExists(ctx, dbOrTX, config)
 
 			dbOrTX.AssertExpectations(t)
 
@@ -61,7 +87,7 @@ func TestCreateMigrationsTableIfNotExists(t *testing.T) {
 }
 
 func TestGetDBVersion(t *testing.T) {
-	expectedSQL := selectDBVersionSQL
+	expectedSQL := selectDBVersionSQL(migrationsTableName)
 
 	testCases := []struct {
 		name      string
@@ -131,7 +157,7 @@ func TestGetDBVersion(t *testing.T) {
 			tc.setupMock(dbOrTX, row)
 
 			ctx := context.Background()
-			version, err := getDBVersion(ctx, dbOrTX, defaultTimeout)
+			version, err := getDBVersion(ctx, dbOrTX, DefaultConfig())
 
 			dbOrTX.AssertExpectations(t)
 			row.AssertExpectations(t)
@@ -149,7 +175,7 @@ func TestGetDBVersion(t *testing.T) {
 }
 
 func TestInsertDBVersion(t *testing.T) {
-	expectedSQL := insertMigVersionSQL
+	expectedSQL := insertMigVersionSQL(migrationsTableName)
 
 	testCases := []struct {
 		name      string
@@ -187,7 +213,7 @@ func TestInsertDBVersion(t *testing.T) {
 			tc.setupMock(dbOrTX, result)
 
 			ctx := context.Background()
-			err := insertDBVersion(ctx, dbOrTX, tc.version, defaultTimeout)
+			err := insertDBVersion(ctx, dbOrTX, tc.version, DefaultConfig())
 
 			dbOrTX.AssertExpectations(t)
 
@@ -202,7 +228,7 @@ func TestInsertDBVersion(t *testing.T) {
 }
 
 func TestDeleteDBVersion(t *testing.T) {
-	expectedSQL := deleteMigVersionSQL
+	expectedSQL := deleteMigVersionSQL(migrationsTableName)
 
 	testCases := []struct {
 		name      string
@@ -240,7 +266,7 @@ func TestDeleteDBVersion(t *testing.T) {
 			tc.setupMock(dbOrTX, result)
 
 			ctx := context.Background()
-			err := deleteDBVersion(ctx, dbOrTX, tc.version, defaultTimeout)
+			err := deleteDBVersion(ctx, dbOrTX, tc.version, DefaultConfig())
 
 			dbOrTX.AssertExpectations(t)
 
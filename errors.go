@@ -1,10 +1,175 @@
 package gosmig
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	errDBVersionChangedUp = errors.New(
+	// ErrDBVersionChangedUp is returned when the database version changed
+	// (e.g. because of a concurrent run) while applying a migration up.
+	ErrDBVersionChangedUp = errors.New(
 		"database version changed while applying migration up")
-	errDBVersionChangedDown = errors.New(
+
+	// ErrDBVersionChangedDown is returned when the database version changed
+	// (e.g. because of a concurrent run) while rolling back a migration.
+	ErrDBVersionChangedDown = errors.New(
 		"database version changed while applying migration down")
+
+	// ErrBatchCursorMoved is returned when the cursor of a batched migration
+	// changed (e.g. because of a concurrent run) since it was read.
+	ErrBatchCursorMoved = errors.New(
+		"cursor of batched migration changed while applying it")
+
+	// ErrGraphBackfill is returned when migrations adopt modules or
+	// dependencies in a database migrated without them, and some of the
+	// versions applied so far can't be recorded by ID.
+	ErrGraphBackfill = errors.New(
+		"applied migrations can't be tracked by ID")
+
+	// ErrMigrationsOutsideSets is returned when migration sets are run on a
+	// database whose migrations table (the one of New, named after
+	// Config.TableName alone) has migrations applied.
+	ErrMigrationsOutsideSets = errors.New(
+		"migrations applied outside of the migration sets")
+
+	// ErrLockTimeout is returned when the migrations lock could not be
+	// acquired within Config.LockTimeout.
+	ErrLockTimeout = errors.New(
+		"timed out waiting for the migrations lock")
+
+	// ErrNoMigrations is returned when no migrations are provided.
+	ErrNoMigrations = errors.New("no migrations provided")
+
+	// ErrInvalidMigrations is returned when the provided migrations are invalid.
+	ErrInvalidMigrations = errors.New("invalid migration(s)")
+
+	// ErrSquashedRange is returned when the database version is inside the
+	// range of migrations squashed into a baseline, so neither the baseline nor
+	// the squashed migrations can be applied to it.
+	ErrSquashedRange = errors.New("database version is inside a squashed range")
+
+	// ErrBaselineRollback is returned when trying to roll back a baseline migration.
+	ErrBaselineRollback = errors.New("a baseline migration can't be rolled back")
+
+	// ErrIrreversibleMigration is returned when trying to roll back an
+	// irreversible migration (see Migration.Irreversible).
+	ErrIrreversibleMigration = errors.New("an irreversible migration can't be rolled back")
+
+	// ErrTargetNotRun is the error of the targets which RunTargets didn't run
+	// because another target failed (see RunTargetsConfig.FailFast).
+	ErrTargetNotRun = errors.New("target not run because another target failed")
+
+	// ErrPendingMigrations is returned by Check when some migrations are not
+	// applied yet: the database is behind the code.
+	ErrPendingMigrations = errors.New("pending migrations")
+
+	// ErrDBAhead is returned by Check when migrations unknown to the code are
+	// applied: the database is ahead of the code (e.g. of a rolled back deploy).
+	ErrDBAhead = errors.New("database ahead of the code, unknown migrations applied")
+
+	// ErrChecksumDrift is returned by Check when the checksum of an applied
+	// migration differs from the one recorded when it was applied.
+	ErrChecksumDrift = errors.New("checksum drift, migrations changed since they were applied")
+
+	// ErrSchemaNoTX is returned when Config.Schema is set and a migration runs
+	// without a transaction (UpDownNoTX): its search_path could only be set for
+	// the session of whichever pooled connection runs it, and would leak to the
+	// later users of that connection.
+	ErrSchemaNoTX = errors.New("migrations without a transaction can't run in a schema")
+
+	// ErrInvalidConfig is returned when the provided (or loaded) config is invalid.
+	ErrInvalidConfig = errors.New("invalid config")
+)
+
+type (
+	// Direction is the direction in which a migration is run.
+	Direction string
+
+	// TxMode tells whether a migration runs in a transaction or not.
+	TxMode string
+
+	// Phase is the step of running a migration.
+	Phase string
 )
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+
+	TxModeTX   TxMode = "TX"
+	TxModeNoTX TxMode = "no TX"
+
+	// PhaseBegin is the beginning of the migration transaction.
+	PhaseBegin Phase = "begin"
+	// PhaseFunc is the call of the migration Up or Down function.
+	PhaseFunc Phase = "func"
+	// PhaseBookkeeping is the check and update of the migrations table.
+	PhaseBookkeeping Phase = "bookkeeping"
+	// PhaseCommit is the commit of the migration transaction.
+	PhaseCommit Phase = "commit"
+)
+
+// MigrationError is returned when applying or rolling back a migration fails.
+// Use errors.As to get it, and errors.Is to check the underlying error
+// (e.g. ErrDBVersionChangedUp).
+type MigrationError struct {
+	Version   int
+	Module    string // if any (see Migration.Module)
+	Direction Direction
+	TxMode    TxMode
+	Phase     Phase
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	if e.Module != "" {
+		return fmt.Sprintf("migration %s/%d %s failed (%s, %s phase): %v",
+			e.Module, e.Version, e.Direction, e.TxMode, e.Phase, e.Err)
+	}
+	return fmt.Sprintf("migration version %d %s failed (%s, %s phase): %v",
+		e.Version, e.Direction, e.TxMode, e.Phase, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// phaseError tags an error with the phase in which it occurred, so that the
+// phase can be reported in the MigrationError wrapping it.
+type phaseError struct {
+	phase Phase
+	err   error
+}
+
+func (e *phaseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
+
+func withPhase(phase Phase, err error) error {
+	return &phaseError{phase: phase, err: err}
+}
+
+func newMigrationError(
+	version int, direction Direction, txMode TxMode, err error,
+) *MigrationError {
+
+	migErr := &MigrationError{
+		Version:   version,
+		Direction: direction,
+		TxMode:    txMode,
+		Phase:     PhaseFunc,
+		Err:       err,
+	}
+
+	var phaseErr *phaseError
+	if errors.As(err, &phaseErr) {
+		migErr.Phase = phaseErr.phase
+	}
+
+	return migErr
+}
@@ -0,0 +1,148 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationError(t *testing.T) {
+	errBoom := errors.New("boom")
+	migErr := &MigrationError{
+		Version:   7,
+		Direction: DirectionDown,
+		TxMode:    TxModeNoTX,
+		Phase:     PhaseBookkeeping,
+		Err:       errBoom,
+	}
+
+	require.EqualError(t, migErr, "migration version 7 down failed (no TX, bookkeeping phase): boom")
+	require.ErrorIs(t, migErr, errBoom)
+}
+
+func TestNewMigrationError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		wantPhase Phase
+	}{
+		{
+			name:      "untagged error defaults to func phase",
+			err:       errors.New("boom"),
+			wantPhase: PhaseFunc,
+		},
+		{
+			name:      "tagged error",
+			err:       withPhase(PhaseCommit, errors.New("boom")),
+			wantPhase: PhaseCommit,
+		},
+		{
+			name: "wrapped tagged error",
+			err: fmt.Errorf("outer: %w",
+				withPhase(PhaseBookkeeping, errors.New("boom"))),
+			wantPhase: PhaseBookkeeping,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migErr := newMigrationError(3, DirectionUp, TxModeTX, tc.err)
+			require.Equal(t, 3, migErr.Version)
+			require.Equal(t, DirectionUp, migErr.Direction)
+			require.Equal(t, TxModeTX, migErr.TxMode)
+			require.Equal(t, tc.wantPhase, migErr.Phase)
+			require.ErrorIs(t, migErr, tc.err)
+		})
+	}
+}
+
+func TestRunCmdUpMigrationErrorPhases(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	setupGetDBVersion := func(dbOrTX interface {
+		On(string, ...any) *mock.Call
+	}, row *dbRowMock, version int) {
+		dbOrTX.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(row).
+			Once()
+		row.On("Scan", mock.Anything).
+			Run(func(args mock.Arguments) {
+				*(args.Get(0).([]any)[0].(*int)) = version
+			}).
+			Return(nil).
+			Once()
+	}
+
+	testCases := []struct {
+		name      string
+		setupMock func(*dbMock, *txMock, *dbRowMock, *dbResultMock)
+		wantPhase Phase
+		wantErrIs error
+	}{
+		{
+			name: "begin fails",
+			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
+				setupGetDBVersion(db, row, 0)
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, errBoom).Once()
+			},
+			wantPhase: PhaseBegin,
+			wantErrIs: errBoom,
+		},
+		{
+			name: "version changed concurrently",
+			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
+				setupGetDBVersion(db, row, 0)
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				setupGetDBVersion(tx, row, 1)
+				tx.On("Rollback").Return(nil).Once()
+			},
+			wantPhase: PhaseBookkeeping,
+			wantErrIs: ErrDBVersionChangedUp,
+		},
+		{
+			name: "commit fails",
+			setupMock: func(db *dbMock, tx *txMock, row *dbRowMock, result *dbResultMock) {
+				setupGetDBVersion(db, row, 0)
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				setupGetDBVersion(tx, row, 0)
+				tx.On("ExecContext", mock.Anything, mock.Anything).Return(result, nil).Once()
+				tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 1).
+					Return(result, nil).
+					Once()
+				tx.On("Commit").Return(errBoom).Once()
+			},
+			wantPhase: PhaseCommit,
+			wantErrIs: errBoom,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			row := new(dbRowMock)
+			result := new(dbResultMock)
+			tc.setupMock(db, tx, row, result)
+
+			_, err := runCmdUp(
+				context.Background(), createTestMigrations(1), db, io.Discard, 0, DefaultConfig())
+
+			var migErr *MigrationError
+			require.ErrorAs(t, err, &migErr)
+			require.Equal(t, 1, migErr.Version)
+			require.Equal(t, DirectionUp, migErr.Direction)
+			require.Equal(t, TxModeTX, migErr.TxMode)
+			require.Equal(t, tc.wantPhase, migErr.Phase)
+			require.ErrorIs(t, err, tc.wantErrIs)
+
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+			row.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package gosmig
+
+// Exit codes of the migration tool. They are grouped by category, so that
+// scripts (e.g. CI pipelines) can branch on them.
+const (
+	// ExitOK is returned when the command succeeded.
+	ExitOK = 0
+
+	// ExitFailure is returned for failures not covered by the other
+	// categories, e.g. a database error while reading the status.
+	ExitFailure = 1
+
+	// ExitUsageError is returned when the command line arguments or the config
+	// file are invalid.
+	ExitUsageError = 2
+
+	// ExitConnectionError is returned when connecting to (or disconnecting
+	// from) the database failed.
+	ExitConnectionError = 3
+
+	// ExitLockTimeout is returned when the migrations lock could not be
+	// acquired within Config.LockTimeout.
+	ExitLockTimeout = 4
+
+	// ExitMigrationFailure is returned when applying or rolling back a
+	// migration failed.
+	ExitMigrationFailure = 5
+
+	// ExitValidationFailure is returned when the migrations are invalid.
+	ExitValidationFailure = 6
+
+	// ExitChecksumDrift is returned by check when the checksum of an applied
+	// migration differs from the one recorded when it was applied.
+	ExitChecksumDrift = 7
+
+	// ExitPendingMigrations is returned by `status --exit-code` and by check
+	// when there are pending migrations.
+	ExitPendingMigrations = 8
+
+	// ExitDBAhead is returned by check when migrations unknown to the code
+	// are applied, and by up, up-one and down when they are applied above
+	// the version they target (unless forced).
+	ExitDBAhead = 9
+
+	// ExitNothingToApply is returned by `up --exit-code` and
+	// `up-one --exit-code` when there was nothing to apply, so that scripts can
+	// tell it apart from migrations having been applied (ExitOK).
+	ExitNothingToApply = 10
+)
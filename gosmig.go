@@ -3,12 +3,17 @@ package gosmig
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -17,20 +22,102 @@ const (
 	cmdUp      = "up"
 	cmdUpOne   = "up-one"
 	cmdDown    = "down"
+	cmdGoto    = "goto"
 	cmdStatus  = "status"
+	cmdPlan    = "plan"
 	cmdVersion = "version"
+	cmdCheck   = "check"
+	cmdHistory = "history"
+	cmdUnlock  = "unlock"
+
+	cmdResetBatch = "reset-batch"
+
+	cmdCompletion = "completion"
+	cmdValidate   = "validate"
+	cmdCreate     = "create"
+	cmdSquash     = "squash"
+	cmdSeed       = "seed"
+	cmdSeedStatus = "seed-status"
+
+	// cmdComplete lists the candidates of a completion for the completion
+	// scripts. It's hidden from the usage.
+	cmdComplete = "__complete"
 )
 
 var allCommands = []string{
 	cmdUp,
 	cmdUpOne,
 	cmdDown,
+	cmdGoto,
 	cmdStatus,
+	cmdPlan,
 	cmdVersion,
+	cmdCheck,
+	cmdHistory,
+	cmdUnlock,
+	cmdResetBatch,
+	cmdCompletion,
+	cmdValidate,
+	cmdCreate,
+	cmdSquash,
+	cmdSeed,
+	cmdSeedStatus,
+}
+
+// hiddenCommands are the commands which aren't shown in the usage.
+var hiddenCommands = []string{
+	cmdComplete,
+}
+
+// offlineCommands are the commands that don't need a database connection.
+var offlineCommands = []string{
+	cmdCompletion,
+	cmdValidate,
+	cmdCreate,
+	cmdComplete,
+}
+
+// commandNbArgs holds the number of arguments of the commands that take any.
+var commandNbArgs = map[string]int{
+	cmdComplete:   1,
+	cmdCompletion: 1,
+	cmdCreate:     1,
+	cmdGoto:       1,
+	cmdResetBatch: 1,
+	cmdSeed:       1,
+}
+
+// lockingCommands are the commands that change the database, and thus run
+// while holding the migrations lock (if enabled via Config.LockTimeout).
+var lockingCommands = []string{
+	cmdUp,
+	cmdUpOne,
+	cmdDown,
+	cmdGoto,
+	cmdResetBatch,
+	cmdSeed,
+}
+
+// migratingCommands are the commands that apply or roll back migrations.
+var migratingCommands = []string{
+	cmdUp,
+	cmdUpOne,
+	cmdDown,
+	cmdGoto,
+}
+
+// readOnlyCommands are the commands that only read the tracking tables,
+// without creating them (nor adding their missing columns), so that they
+// don't lock them when run at every startup of a service: the missing tables
+// are read as empty ones (see readAppliedVersions).
+var readOnlyCommands = []string{
+	cmdStatus,
+	cmdCheck,
 }
 
 // New creates a new gosmig instance. It returns a function that runs the migration tool
-// when called, and an error if the provided migrations are invalid.
+// when called, and an error if the provided migrations are invalid, unless it's run with
+// the validate command, which reports all their problems instead.
 //
 // The returned function should be called to execute the migration commands.
 // It handles command-line arguments (gosmig <db_url> and <command>), connects to
@@ -47,6 +134,8 @@ var allCommands = []string{
 //     and returns a connected database instance or an error.
 //   - config: A Config struct specifying the configuration options, such as timeout duration
 //     for database operations. If nil, default configuration is used.
+//   - opts: Optional features, i.e. repeatable migrations (see WithRepeatables)
+//     and seed sets (see WithSeedSets).
 //
 // Returns:
 //   - A function that executes the migration tool when called.
@@ -123,13 +212,15 @@ func New[
 	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
 	connectToDB func(url string, timeout time.Duration) (TDB, error),
 	config *Config,
+	opts ...Option[TDBRow, TDBResult, TTX, TTXO, TDB],
 ) (func(), error) { // coverage-ignore
 
 	getArgs := func() []string {
 		return os.Args[1:]
 	}
 
-	return newGosmig(migrations, connectToDB, config, getArgs, os.Exit, os.Stdout, os.Stderr)
+	return newGosmig(
+		migrations, connectToDB, config, getArgs, os.Exit, os.Stdout, os.Stderr, opts...)
 }
 
 func newGosmig[
@@ -145,10 +236,11 @@ func newGosmig[
 	getArgs func() []string,
 	osExit func(int),
 	out, errOut io.Writer,
+	opts ...Option[TDBRow, TDBResult, TTX, TTXO, TDB],
 ) (func(), error) {
 
 	if len(migrations) == 0 {
-		return nil, fmt.Errorf("no migrations provided")
+		return nil, ErrNoMigrations
 	}
 
 	if connectToDB == nil {
@@ -177,16 +269,45 @@ func newGosmig[
 		return nil, fmt.Errorf("errOut writer is nil")
 	}
 
-	if err := validateMigrations(migrations); err != nil {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := validateMigrations(migrations, config.Versioning); err != nil && !validateRun(getArgs) {
+		return nil, err
+	}
+
+	optional, err := newOptions(opts...)
+	if err != nil {
 		return nil, err
 	}
 
 	return func() {
-		var errExitCode int
+		args, err := parseArgs(getArgs())
+		if err != nil {
+			errExit(ExitUsageError, err, errOut, osExit)
+			return
+		}
+
+		if args.set != "" {
+			errExit(ExitUsageError, errors.New("--set needs migration sets (see NewSets)"), errOut, osExit)
+			return
+		}
+
+		if slices.Contains(offlineCommands, args.command) {
+			if err := runOfflineCmd(migrations, args, out, config); err != nil {
+				exitCode := ExitUsageError
+				if errors.Is(err, ErrInvalidMigrations) {
+					exitCode = ExitValidationFailure
+				}
+				errExit(exitCode, err, errOut, osExit)
+			}
+			return
+		}
 
-		url, command, err := parseArgs(getArgs())
+		config, url, err := resolveConfig(*config, args)
 		if err != nil {
-			errExit(errExitCode+1, err, errOut, osExit)
+			errExit(ExitUsageError, err, errOut, osExit)
 			return
 		}
 
@@ -194,71 +315,500 @@ func newGosmig[
 
 		db, err := connectToDB(url, config.Timeout)
 		if err != nil {
-			errExit(errExitCode+2, err, errOut, osExit)
+			errExit(ExitConnectionError, err, errOut, osExit)
 			return
 		}
 		defer func() {
 			if err := db.Close(); err != nil {
-				errExit(errExitCode+3, err, errOut, osExit)
+				errExit(ExitConnectionError, err, errOut, osExit)
 				return
 			}
 		}()
 
-		if err := createMigrationsTableIfNotExists(ctx, db, config.Timeout); err != nil {
-			errExit(errExitCode+4, err, errOut, osExit)
+		nbPending, exitCode, err := runDBCmd(ctx, migrations, optional, db, url, args, out, config)
+		if err != nil {
+			errExit(exitCode, err, errOut, osExit)
+			return
+		}
+		if args.exitCode && nbPending > 0 {
+			errExit(ExitPendingMigrations, pendingErr(args.command, nbPending), errOut, osExit)
 			return
 		}
+	}, nil
+}
 
-		switch command {
-		case cmdUp:
-			if err := runCmdUp(ctx, migrations, db, out, 0, config.Timeout); err != nil {
-				errExit(errExitCode+5, err, errOut, osExit)
-				return
-			}
-		case cmdUpOne:
-			if err := runCmdUp(ctx, migrations, db, out, 1, config.Timeout); err != nil {
-				errExit(errExitCode+6, err, errOut, osExit)
-				return
+// validateRun tells whether the tool is run with the validate command, which
+// reports all the problems of invalid migrations (and exits with
+// ExitValidationFailure) rather than New failing on the first check.
+func validateRun(getArgs func() []string) bool {
+	args, err := parseArgs(getArgs())
+	return err == nil && args.command == cmdValidate
+}
+
+// runDBCmd runs a command which needs the database, once the tracking tables
+// are created (except for the read-only commands) and, for the commands
+// changing the database, the migrations lock is acquired. Its span has the
+// given attributes, if any (e.g. the target of RunTargets). It returns the
+// number of pending migrations (or seed sets) for the status commands, and the
+// exit code along with the error, if any.
+func runDBCmd[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	optional options[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	url string,
+	args cliArgs,
+	out io.Writer,
+	config *Config,
+	attrs ...attribute.KeyValue,
+) (nbPending int, exitCode int, err error) {
+
+	ctx, span := startCmdSpan(ctx, args.command, config, attrs...)
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	defer func() { observeCmd(ctx, migrations, db, args.command, start, err, config) }()
+
+	if !slices.Contains(readOnlyCommands, args.command) {
+		if err := createTrackingTables(ctx, migrations, optional, db, config); err != nil {
+			return 0, ExitFailure, err
+		}
+	}
+
+	if slices.Contains(migratingCommands, args.command) {
+		if err := checkSchemaNoTX(migrations, config); err != nil {
+			return 0, ExitFailure, err
+		}
+	}
+
+	if config.LockTimeout > 0 && slices.Contains(lockingCommands, args.command) {
+		lockStart := time.Now()
+		releaseLock, err := acquireLock(ctx, db, config)
+		observeLockWait(lockStart, config)
+		if err != nil {
+			if errors.Is(err, ErrLockTimeout) {
+				return 0, ExitLockTimeout, err
 			}
-		case cmdDown:
-			if err := runCmdDown(ctx, migrations, db, out, config.Timeout); err != nil {
-				errExit(errExitCode+7, err, errOut, osExit)
-				return
+			return 0, ExitFailure, err
+		}
+		defer func() {
+			if releaseErr := releaseLock(); releaseErr != nil && err == nil {
+				exitCode, err = ExitFailure, releaseErr
 			}
-		case cmdStatus:
-			if err := runCmdStatus(ctx, migrations, db, out, config.Timeout); err != nil {
-				errExit(errExitCode+8, err, errOut, osExit)
-				return
+		}()
+	}
+
+	switch args.command {
+	case cmdUp:
+		runUp := func() (int, error) { return runCmdUp(ctx, migrations, db, out, 0, config) }
+		if args.atomic {
+			runUp = func() (int, error) { return runCmdUpAtomic(ctx, migrations, db, out, config) }
+		}
+		// The contract phases are only applied when asked for, once the code
+		// using what they drop is gone.
+		var nbApplied int
+		if args.phase != phaseContract {
+			n, err := runUp()
+			if err != nil {
+				return 0, migrateExitCode(err), err
 			}
-		case cmdVersion:
-			if err := runCmdVersion(ctx, db, out, config.Timeout); err != nil {
-				errExit(errExitCode+9, err, errOut, osExit)
-				return
+			nbApplied += n
+		} else {
+			n, err := runContractPhase(ctx, migrations, db, out, config)
+			if err != nil {
+				return 0, migrateExitCode(err), err
 			}
+			nbApplied += n
 		}
-	}, nil
+		n, err := runRepeatables(ctx, optional.repeatables, db, out, config)
+		if err != nil {
+			return 0, ExitMigrationFailure, err
+		}
+		nbApplied += n
+		if args.exitCode && nbApplied == 0 {
+			return 0, ExitNothingToApply, errNothingToApply
+		}
+	case cmdUpOne:
+		nbApplied, err := runCmdUp(ctx, migrations, db, out, 1, config)
+		if err != nil {
+			return 0, migrateExitCode(err), err
+		}
+		if args.exitCode && nbApplied == 0 {
+			return 0, ExitNothingToApply, errNothingToApply
+		}
+	case cmdDown:
+		if err := runCmdDown(ctx, migrations, db, out, config); err != nil {
+			return 0, migrateExitCode(err), err
+		}
+	case cmdGoto:
+		if _, err := runCmdGoto(ctx, migrations, db, out, args.target, config); err != nil {
+			return 0, migrateExitCode(err), err
+		}
+	case cmdStatus:
+		nbPending, err := runCmdStatus(ctx, migrations, optional.repeatables, db, out, config)
+		if err != nil {
+			return 0, ExitFailure, err
+		}
+		return nbPending, 0, nil
+	case cmdPlan:
+		nbPending, err := runCmdPlan(ctx, migrations, optional.repeatables, db, out, args.phase, config)
+		if err != nil {
+			return 0, migrateExitCode(err), err
+		}
+		return nbPending, 0, nil
+	case cmdVersion:
+		runVersion := runCmdVersion[TDBRow, TDBResult, TDB]
+		if usesGraph(migrations) {
+			runVersion = runGraphVersion[TDBRow, TDBResult, TDB]
+		}
+		if err := runVersion(ctx, db, out, config); err != nil {
+			return 0, ExitFailure, err
+		}
+	case cmdCheck:
+		if err := runCmdCheck(ctx, migrations, db, out, config); err != nil {
+			return 0, checkExitCode(err), err
+		}
+	case cmdHistory:
+		if err := runCmdHistory(ctx, db, out, args.historyFilter, args.json, config); err != nil {
+			return 0, ExitFailure, err
+		}
+	case cmdUnlock:
+		if err := runCmdUnlock(ctx, db, out, config); err != nil {
+			return 0, ExitFailure, err
+		}
+	case cmdResetBatch:
+		if err := runCmdResetBatch(ctx, migrations, db, out, args.target, config); err != nil {
+			return 0, ExitFailure, err
+		}
+	case cmdSquash:
+		if err := runCmdSquash(ctx, migrations, db, url, out, config, args.upto); err != nil {
+			return 0, ExitFailure, err
+		}
+	case cmdSeed:
+		if err := runCmdSeed(ctx, optional.seedSets, db, out, config, args.commandArgs[0]); err != nil {
+			return 0, ExitFailure, err
+		}
+	case cmdSeedStatus:
+		nbPending, err := runCmdSeedStatus(ctx, optional.seedSets, db, out, config)
+		if err != nil {
+			return 0, ExitFailure, err
+		}
+		return nbPending, 0, nil
+	}
+
+	return 0, 0, nil
+}
+
+// errNothingToApply is the error of up and up-one run with --exit-code when
+// there was nothing to apply.
+var errNothingToApply = errors.New("no migrations to apply")
+
+// migrateExitCode returns the exit code of up, up-one or down failing with err.
+func migrateExitCode(err error) int {
+	if errors.Is(err, ErrDBAhead) {
+		return ExitDBAhead
+	}
+	return ExitMigrationFailure
 }
 
-func parseArgs(args []string) (string, string, error) {
-	if len(args) != 2 {
-		return "", "", errors.New("wrong number of arguments")
+// pendingErr is the error of the status commands run with --exit-code when
+// there are pending migrations (or seed sets).
+func pendingErr(command string, nbPending int) error {
+	if command == cmdSeedStatus {
+		return fmt.Errorf("%d pending seed set(s)", nbPending)
+	}
+	return fmt.Errorf("%d pending migration(s)", nbPending)
+}
+
+// createTrackingTables creates the tables tracking the migrations (widening
+// the version column and adding the columns of the tags and of the contract
+// phases if needed), their history if enabled, and the batched migrations,
+// repeatable migrations and seed sets if there are any, if they don't exist.
+func createTrackingTables[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	optional options[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) error {
+
+	if err := createMigrationsTableIfNotExists(ctx, db, config); err != nil {
+		return err
+	}
+
+	if hasBigintVersions(migrations) {
+		if err := alterVersionBigintIfNeeded(ctx, db, config); err != nil {
+			return err
+		}
+	}
+
+	if usesTags(migrations, config) {
+		if err := addTagsColumnIfNotExists(ctx, db, config); err != nil {
+			return err
+		}
+	}
+
+	if usesGraph(migrations) {
+		if err := createGraphTable(ctx, migrations, db, config); err != nil {
+			return err
+		}
+	}
+
+	if hasContract(migrations) {
+		if err := addContractedAtColumnIfNotExists(ctx, db, config); err != nil {
+			return err
+		}
+	}
+
+	if hasChecksum(migrations) {
+		if err := addChecksumColumnIfNotExists(ctx, db, config); err != nil {
+			return err
+		}
 	}
 
-	url := args[0]
-	command := args[1]
-	if !slices.Contains(allCommands, command) {
-		return "", "", fmt.Errorf("unknown command: %q", command)
+	if hasBatched(migrations) {
+		if err := createBatchTableIfNotExists(ctx, db, config); err != nil {
+			return err
+		}
+	}
+
+	if config.History {
+		if err := createHistoryTableIfNotExists(ctx, db, config); err != nil {
+			return err
+		}
+	}
+
+	if len(optional.repeatables) > 0 {
+		if err := createRepeatableTableIfNotExists(ctx, db, config); err != nil {
+			return err
+		}
+	}
+
+	if len(optional.seedSets) > 0 {
+		if err := createSeedTableIfNotExists(ctx, db, config); err != nil {
+			return err
+		}
 	}
 
-	return url, command, nil
+	return nil
+}
+
+func runOfflineCmd[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	args cliArgs,
+	out io.Writer,
+	config *Config,
+) error {
+
+	switch args.command {
+	case cmdCompletion:
+		return runCmdCompletion(out, args.commandArgs[0], programName())
+	case cmdComplete:
+		versions := make([]int, len(migrations))
+		for i, migration := range migrations {
+			versions[i] = migration.Version
+		}
+		return runCmdComplete(out, args.commandArgs[0], versions)
+	case cmdValidate:
+		return runCmdValidate(migrations, out, config)
+	case cmdCreate:
+		return runCmdCreate(migrations, out, config, args.commandArgs[0], args.sql, args.noTX)
+	}
+
+	return nil
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+type cliArgs struct {
+	url           string
+	command       string
+	commandArgs   []string
+	configFile    string
+	env           string
+	exitCode      bool
+	sql           bool
+	noTX          bool
+	atomic        bool
+	phase         string
+	upto          int
+	target        int
+	tags          string
+	set           string
+	force         bool
+	historyFilter historyFilter
+	json          bool
+}
+
+// parseArgs parses the command-line arguments. Flags may appear anywhere
+// among the positional arguments, which are an optional database URL (it may
+// come from the config file instead) followed by the command and its
+// arguments (if any).
+func parseArgs(args []string) (cliArgs, error) {
+	var parsed cliArgs
+
+	flags := flag.NewFlagSet(toolName, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&parsed.configFile, "config", "", "path of the config file")
+	flags.StringVar(&parsed.env, "env", "", "environment to use from the config file")
+	flags.BoolVar(&parsed.exitCode, "exit-code", false, "status, plan: exit with ExitPendingMigrations if there are pending migrations; up, up-one: exit with ExitNothingToApply if there was nothing to apply")
+	flags.BoolVar(&parsed.sql, "sql", false, "create: write .up.sql and .down.sql files instead of a Go file")
+	flags.BoolVar(&parsed.noTX, "no-tx", false, "create: scaffold a migration which runs without a transaction")
+	flags.BoolVar(&parsed.atomic, "atomic", false, "up: apply all the pending migrations in a single transaction")
+	flags.StringVar(&parsed.phase, "phase", "", "up, plan: apply the expand (default) or the contract phase of the migrations")
+	flags.IntVar(&parsed.upto, "upto", 0, "squash: version to squash the migrations up to")
+	flags.StringVar(&parsed.tags, "tags", "", "comma-separated tags selecting the migrations to apply")
+	flags.StringVar(&parsed.set, "set", "", "name of the migration set to run the command on (see NewSets)")
+	flags.BoolVar(&parsed.force, "force", false, "up, up-one, down, goto: run even if unknown migrations are applied above the target version")
+	flags.IntVar(&parsed.historyFilter.version, "version", 0, "history: show only the entries of this migration version")
+	since := flags.String("since", "", "history: show only the entries since this time")
+	until := flags.String("until", "", "history: show only the entries before this time")
+	direction := flags.String("direction", "", "history: show only the entries of this direction (up or down)")
+	flags.BoolVar(&parsed.json, "json", false, "history: write the entries as JSON")
+
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return cliArgs{}, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	switch {
+	case len(positional) > 0 && isCommand(positional[0]):
+		parsed.command = positional[0]
+		parsed.commandArgs = positional[1:]
+	case len(positional) > 1:
+		parsed.url = positional[0]
+		parsed.command = positional[1]
+		parsed.commandArgs = positional[2:]
+	default:
+		return cliArgs{}, errors.New("wrong number of arguments")
+	}
+
+	if !isCommand(parsed.command) {
+		return cliArgs{}, fmt.Errorf("unknown command: %q", parsed.command)
+	}
+
+	if len(parsed.commandArgs) != commandNbArgs[parsed.command] {
+		return cliArgs{}, errors.New("wrong number of arguments")
+	}
+
+	if parsed.command == cmdGoto || parsed.command == cmdResetBatch {
+		var err error
+		if parsed.target, err = strconv.Atoi(parsed.commandArgs[0]); err != nil || parsed.target < 0 {
+			return cliArgs{}, fmt.Errorf("invalid version: %q", parsed.commandArgs[0])
+		}
+	}
+
+	if parsed.phase != "" && !slices.Contains(phases, parsed.phase) {
+		return cliArgs{}, fmt.Errorf(
+			"invalid phase: %q (expected %s)", parsed.phase, strings.Join(phases, " or "))
+	}
+
+	var err error
+	if *since != "" {
+		if parsed.historyFilter.since, err = parseHistoryTime(*since); err != nil {
+			return cliArgs{}, err
+		}
+	}
+	if *until != "" {
+		if parsed.historyFilter.until, err = parseHistoryTime(*until); err != nil {
+			return cliArgs{}, err
+		}
+	}
+	if *direction != "" {
+		parsed.historyFilter.direction = Direction(*direction)
+		if !slices.Contains([]Direction{DirectionUp, DirectionDown}, parsed.historyFilter.direction) {
+			return cliArgs{}, fmt.Errorf("invalid direction: %q (expected up or down)", *direction)
+		}
+	}
+
+	return parsed, nil
+}
+
+func isCommand(name string) bool {
+	return slices.Contains(allCommands, name) || slices.Contains(hiddenCommands, name)
+}
+
+// resolveConfig applies the command-line flags and the config file (if any)
+// to a copy of the given config, and returns it along with the database URL.
+// The URL and tags given on the command line take precedence over the config
+// file ones.
+func resolveConfig(config Config, args cliArgs) (*Config, string, error) {
+	if args.configFile != "" {
+		config.ConfigFile = args.configFile
+	}
+	if args.env != "" {
+		config.Env = args.env
+	}
+
+	url := args.url
+	if config.ConfigFile != "" {
+		fileURL, err := applyConfigFile(&config)
+		if err != nil {
+			return nil, "", err
+		}
+		if url == "" {
+			url = fileURL
+		}
+	}
+
+	if args.force {
+		config.AllowUnknownVersions = true
+	}
+
+	if args.tags != "" {
+		config.Tags = parseTags(args.tags)
+		if err := config.validate(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if url == "" {
+		return nil, "", errors.New("missing database URL")
+	}
+
+	return &config, url, nil
 }
 
 func usage() string {
 	return fmt.Sprintf(
-		"Usage: %s <db_url> <%s>", toolName, strings.Join(allCommands, "|"))
+		"Usage: %s [--config <file> [--env <name>]] [--exit-code] [--sql] [--no-tx] [--atomic] [--phase <expand|contract>] [--upto <version>] [--tags <tag,...>] [--set <name>] [--force] [--version <version>] [--since <time>] [--until <time>] [--direction <up|down>] [--json] [<db_url>] <%s> [<args>]",
+		toolName, strings.Join(allCommands, "|"))
 }
 
+// errExit prints the error (preceded by the usage, for usage errors) and
+// exits with the given code.
 func errExit(errCode int, err error, output io.Writer, osExit func(int)) {
-	_, _ = fmt.Fprintf(output, "%s\n%v\n", usage(), err)
+	if errCode == ExitUsageError {
+		_, _ = fmt.Fprintln(output, usage())
+	}
+	_, _ = fmt.Fprintln(output, err)
 	osExit(errCode)
 }
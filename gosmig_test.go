@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -21,6 +24,7 @@ func TestNewGosmig(t *testing.T) {
 		migrations  []MigrationSQL
 		connectToDB func(url string, timeout time.Duration) (*sql.DB, error)
 		config      *Config
+		repeatables []RepeatableSQL
 		getArgs     func() []string
 		osExit      func(int)
 		out, errOut io.Writer
@@ -115,6 +119,59 @@ func TestNewGosmig(t *testing.T) {
 			errOut:  nil,
 			wantErr: "errOut writer is nil",
 		},
+		{
+			name:       "invalid config",
+			migrations: []MigrationSQL{{Version: 1}},
+			connectToDB: func(url string, timeout time.Duration) (*sql.DB, error) {
+				return nil, nil
+			},
+			config:  &Config{TableName: "bad name"},
+			getArgs: func() []string { return nil },
+			osExit:  func(code int) {},
+			out:     io.Discard,
+			errOut:  io.Discard,
+			wantErr: `invalid migrations table name: "bad name"`,
+		},
+		{
+			name:       "schema-qualified table name with a schema",
+			migrations: []MigrationSQL{{Version: 1}},
+			connectToDB: func(url string, timeout time.Duration) (*sql.DB, error) {
+				return nil, nil
+			},
+			config:  &Config{TableName: "public.gosmig", Schema: "tenant_a"},
+			getArgs: func() []string { return nil },
+			osExit:  func(code int) {},
+			out:     io.Discard,
+			errOut:  io.Discard,
+			wantErr: `invalid config: the migrations table name "public.gosmig" must not be ` +
+				"schema-qualified when a schema is set",
+		},
+		{
+			name:       "unknown versioning scheme",
+			migrations: []MigrationSQL{{Version: 1}},
+			connectToDB: func(url string, timeout time.Duration) (*sql.DB, error) {
+				return nil, nil
+			},
+			config:  &Config{Versioning: "semver"},
+			getArgs: func() []string { return nil },
+			osExit:  func(code int) {},
+			out:     io.Discard,
+			errOut:  io.Discard,
+			wantErr: `unknown versioning scheme: "semver"`,
+		},
+		{
+			name:       "sequential version with timestamp versioning",
+			migrations: []MigrationSQL{{Version: 1}},
+			connectToDB: func(url string, timeout time.Duration) (*sql.DB, error) {
+				return nil, nil
+			},
+			config:  &Config{Versioning: VersioningTimestamp},
+			getArgs: func() []string { return nil },
+			osExit:  func(code int) {},
+			out:     io.Discard,
+			errOut:  io.Discard,
+			wantErr: "migration version 1 is not a YYYYMMDDhhmmss timestamp",
+		},
 		{
 			name: "invalid migration",
 			migrations: []MigrationSQL{
@@ -130,6 +187,25 @@ func TestNewGosmig(t *testing.T) {
 			errOut:  io.Discard,
 			wantErr: "migration 1 UpDown must have both Up and Down functions defined",
 		},
+		{
+			name: "invalid repeatable migration",
+			migrations: []MigrationSQL{
+				{Version: 1, UpDown: &UpDownSQL{
+					Up:   func(ctx context.Context, tx *sql.Tx) error { return nil },
+					Down: func(ctx context.Context, tx *sql.Tx) error { return nil },
+				}},
+			},
+			connectToDB: func(url string, timeout time.Duration) (*sql.DB, error) {
+				return nil, nil
+			},
+			config:      nil,
+			repeatables: []RepeatableSQL{{Name: "views", Checksum: "abc"}},
+			getArgs:     func() []string { return nil },
+			osExit:      func(code int) {},
+			out:         io.Discard,
+			errOut:      io.Discard,
+			wantErr:     "repeatable migration views must have an Up function defined",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -142,6 +218,7 @@ func TestNewGosmig(t *testing.T) {
 				tc.osExit,
 				tc.out,
 				tc.errOut,
+				WithRepeatables(tc.repeatables),
 			)
 			if tc.wantErr != "" {
 				require.ErrorContains(t, err, tc.wantErr)
@@ -173,7 +250,7 @@ func TestNewGosmig(t *testing.T) {
 			migrations, connectToDB, nil, getArgs, osExit, &outW, &errW)
 		require.NoError(t, err)
 		goSMig()
-		require.Equal(t, 1, exitCode)
+		require.Equal(t, ExitUsageError, exitCode)
 		require.Contains(t, errW.String(), "wrong number of arguments")
 	})
 
@@ -199,7 +276,7 @@ func TestNewGosmig(t *testing.T) {
 			migrations, connectToDB, nil, getArgs, osExit, &outW, &errW)
 		require.NoError(t, err)
 		goSMig()
-		require.Equal(t, 2, exitCode)
+		require.Equal(t, ExitConnectionError, exitCode)
 		require.Contains(t, errW.String(), "failed to connect to database: timed out")
 	})
 
@@ -225,7 +302,7 @@ func TestNewGosmig(t *testing.T) {
 			return dbMockInstance, nil
 		}
 		getArgs := func() []string {
-			return []string{"postgres://localhost/db", "status"}
+			return []string{"postgres://localhost/db", "version"}
 		}
 		var exitCode int
 		osExit := func(code int) { exitCode = code }
@@ -235,13 +312,268 @@ func TestNewGosmig(t *testing.T) {
 			migrations, connectToDB, nil, getArgs, osExit, &outW, &errW)
 		require.NoError(t, err)
 		goSMig()
-		require.Equal(t, 3, exitCode)
+		require.Equal(t, ExitConnectionError, exitCode)
 		dbMockInstance.AssertExpectations(t)
 		require.Contains(t, errW.String(), errCreateMigrationsTable.Error())
 		require.Contains(t, errW.String(), errCloseDB.Error())
 	})
 
-	for i, cmd := range allCommands {
+	t.Run("missing database URL", func(t *testing.T) {
+		migrations := []MigrationSQL{
+			{
+				Version: 1,
+				UpDown: &UpDownSQL{
+					Up:   func(ctx context.Context, tx *sql.Tx) error { return nil },
+					Down: func(ctx context.Context, tx *sql.Tx) error { return nil },
+				},
+			},
+		}
+		connectToDB := func(url string, timeout time.Duration) (*sql.DB, error) {
+			return nil, nil
+		}
+		getArgs := func() []string { return []string{"up"} }
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		goSMig, err := newGosmig(
+			migrations, connectToDB, nil, getArgs, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitUsageError, exitCode)
+		require.Contains(t, errW.String(), "missing database URL")
+	})
+
+	t.Run("lock timeout", func(t *testing.T) {
+		dbResultMockInstance := new(dbResultMock)
+		dbResultMockInstance.On("RowsAffected").Return(int64(0), nil)
+
+		dbMockInstance := new(dbMock)
+		dbMockInstance.On("ExecContext", mock.Anything, mock.Anything).
+			Return(dbResultMockInstance, nil)
+		dbMockInstance.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+			Return(dbResultMockInstance, nil)
+		dbMockInstance.On("QueryRowContext", mock.Anything, selectLockHolderSQL(migrationsTableName)).
+			Return(lockHolderRow("ci@runner-7 (pid 42) since 2026-10-18 09:30:00 UTC"))
+		dbMockInstance.On("Close").Return(nil)
+
+		migrations := []migrationMock{
+			{
+				Version: 1,
+				UpDown: &UpDown[*dbRowMock, *dbResultMock, *txMock]{
+					Up:   func(ctx context.Context, tx *txMock) error { return nil },
+					Down: func(ctx context.Context, tx *txMock) error { return nil },
+				},
+			},
+		}
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			return dbMockInstance, nil
+		}
+		getArgs := func() []string { return []string{"postgres://localhost/db", "up"} }
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		goSMig, err := newGosmig(
+			migrations, connectToDB, &Config{LockTimeout: time.Millisecond},
+			getArgs, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitLockTimeout, exitCode)
+		require.Contains(t, errW.String(), ErrLockTimeout.Error())
+		require.Contains(t, errW.String(), "held by ci@runner-7 (pid 42)")
+		dbMockInstance.AssertExpectations(t)
+	})
+
+	t.Run("executes offline command without connecting to the database", func(t *testing.T) {
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			require.FailNow(t, "connectToDB should not be called")
+			return nil, nil
+		}
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		goSMig, err := newGosmig(
+			createTestMigrations(1, 2), connectToDB, nil,
+			func() []string { return []string{"completion", "bash"} }, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, 0, exitCode)
+		require.Empty(t, errW.String())
+		require.Contains(t, outW.String(), "complete -F")
+
+		outW.Reset()
+		goSMig, err = newGosmig(
+			createTestMigrations(1, 2), connectToDB, nil,
+			func() []string { return []string{"completion", "tcsh"} }, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitUsageError, exitCode)
+		require.Contains(t, errW.String(), `unsupported shell "tcsh"`)
+
+		outW.Reset()
+		exitCode = 0
+		goSMig, err = newGosmig(
+			createTestMigrations(2, 1), connectToDB, nil,
+			func() []string { return []string{"__complete", "versions"} }, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, 0, exitCode)
+		require.Equal(t, "1\n2\n", outW.String())
+	})
+
+	t.Run("validate exits with validation failure code", func(t *testing.T) {
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			require.FailNow(t, "connectToDB should not be called")
+			return nil, nil
+		}
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		goSMig, err := newGosmig(
+			createTestMigrations(1, 2), connectToDB, nil,
+			func() []string { return []string{"validate"} }, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, 0, exitCode)
+		require.Contains(t, outW.String(), "0 error(s), 0 warning(s)")
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, "003_create_t.up.sql"), []byte("CREATE TABLE t (c INT);"), 0o644))
+		outW.Reset()
+		goSMig, err = newGosmig(
+			createTestMigrations(1, 2), connectToDB, &Config{MigrationsDir: dir},
+			func() []string { return []string{"validate"} }, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitValidationFailure, exitCode)
+		require.Contains(t, errW.String(), ErrInvalidMigrations.Error())
+		require.Contains(t, outW.String(), "ERROR   missing down SQL file for migration 3")
+
+		// Invalid migrations make New fail, except for the validate command,
+		// which reports all their problems.
+		invalid := createTestMigrations(1, 1, 2)
+		invalid[2].UpDown.Down = nil
+		_, err = newGosmig(invalid, connectToDB, nil,
+			func() []string { return []string{"postgres://localhost/db", "up"} }, osExit, &outW, &errW)
+		require.ErrorIs(t, err, ErrInvalidMigrations)
+
+		outW.Reset()
+		exitCode = 0
+		goSMig, err = newGosmig(invalid, connectToDB, nil,
+			func() []string { return []string{"validate"} }, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitValidationFailure, exitCode)
+		require.Contains(t, outW.String(), "migration version 1 is defined 2 times")
+		require.Contains(t, outW.String(), "2 error(s)")
+	})
+
+	t.Run("status with --exit-code and pending migrations", func(t *testing.T) {
+		// Without creating the tracking tables.
+		dbMockInstance := new(dbMock)
+		expectMigrationsColumns(dbMockInstance, migrationsTableName)
+		dbMockInstance.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+			Return(appliedVersionsRow(1))
+		dbMockInstance.On("Close").Return(nil)
+
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			return dbMockInstance, nil
+		}
+		getArgs := func() []string {
+			return []string{"postgres://localhost/db", "status", "--exit-code"}
+		}
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		goSMig, err := newGosmig(
+			createTestMigrations(1, 2, 3), connectToDB, nil, getArgs, osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitPendingMigrations, exitCode)
+		require.Equal(t, "2 pending migration(s)\n", errW.String())
+		require.Contains(t, outW.String(), "3          [ ] PENDING")
+		dbMockInstance.AssertExpectations(t)
+	})
+
+	t.Run("plan with --exit-code and migrations to apply", func(t *testing.T) {
+		dbMockInstance := new(dbMock)
+		dbMockInstance.On("ExecContext", mock.Anything, mock.Anything).
+			Return(new(dbResultMock), nil)
+		dbMockInstance.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(dbVersionRow(1)).
+			Once()
+		dbMockInstance.On("Close").Return(nil)
+
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			return dbMockInstance, nil
+		}
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		goSMig, err := newGosmig(
+			createTestMigrations(1, 2, 3), connectToDB, nil,
+			func() []string { return []string{"postgres://localhost/db", "plan", "--exit-code"} },
+			osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitPendingMigrations, exitCode)
+		require.Equal(t, "2 pending migration(s)\n", errW.String())
+		require.Equal(t, "[ ] Apply migration version 2\n"+
+			"[ ] Apply migration version 3\n"+
+			"2 step(s) planned\n", outW.String())
+		dbMockInstance.AssertExpectations(t)
+	})
+
+	t.Run("up with --exit-code and nothing to apply", func(t *testing.T) {
+		dbMockInstance := new(dbMock)
+		dbMockInstance.On("ExecContext", mock.Anything, mock.Anything).
+			Return(new(dbResultMock), nil)
+		dbMockInstance.On("Close").Return(nil)
+
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			return dbMockInstance, nil
+		}
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		for _, cmd := range []string{cmdUp, cmdUpOne} {
+			dbMockInstance.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(2)).
+				Once()
+			exitCode = 0
+			errW.Reset()
+			goSMig, err := newGosmig(
+				createTestMigrations(1, 2), connectToDB, nil,
+				func() []string { return []string{"postgres://localhost/db", cmd, "--exit-code"} },
+				osExit, &outW, &errW)
+			require.NoError(t, err)
+			goSMig()
+			require.Equal(t, ExitNothingToApply, exitCode, cmd)
+			require.Equal(t, "no migrations to apply\n", errW.String(), cmd)
+		}
+		dbMockInstance.AssertExpectations(t)
+	})
+
+	wantExitCodes := map[string]int{
+		cmdUp:      ExitMigrationFailure,
+		cmdUpOne:   ExitMigrationFailure,
+		cmdDown:    ExitMigrationFailure,
+		cmdStatus:  ExitFailure,
+		cmdVersion: ExitFailure,
+	}
+	for _, cmd := range allCommands {
+		if slices.Contains(offlineCommands, cmd) ||
+			slices.Contains([]string{cmdSquash, cmdSeed, cmdSeedStatus, cmdCheck, cmdHistory, cmdUnlock,
+				cmdGoto, cmdPlan, cmdResetBatch}, cmd) {
+			continue // squash and the seed commands are tested below, the others in their tests
+		}
 		t.Run(fmt.Sprintf("executes command %s", cmd), func(t *testing.T) {
 			dbRowMockInstance := new(dbRowMock)
 			dbVersion := 0
@@ -253,15 +585,25 @@ func TestNewGosmig(t *testing.T) {
 				scanErr = fmt.Errorf("scan error when getting db version for command %s", cmd)
 			}
 			dbRowMockInstance.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
-				arg := args.Get(0).([]any)
-				*(arg[0].(*int)) = dbVersion
+				switch dest := args.Get(0).([]any)[0].(type) {
+				case *int:
+					*dest = dbVersion
+				case *string: // the applied versions, for status
+					*dest = strconv.Itoa(dbVersion)
+				}
 			}).Return(scanErr)
 
 			dbMockInstance := new(dbMock)
-			dbMockInstance.On("QueryRowContext", mock.Anything, mock.Anything).
-				Return(dbRowMockInstance, nil)
-			dbMockInstance.On("ExecContext", mock.Anything, mock.Anything).
-				Return(new(dbResultMock), nil)
+			if slices.Contains(readOnlyCommands, cmd) {
+				// The scan error is the one of the columns of the migrations table.
+				dbMockInstance.On("QueryRowContext", mock.Anything, selectColumnsSQL(), "", migrationsTableName).
+					Return(dbRowMockInstance, nil)
+			} else {
+				dbMockInstance.On("QueryRowContext", mock.Anything, mock.Anything).
+					Return(dbRowMockInstance, nil)
+				dbMockInstance.On("ExecContext", mock.Anything, mock.Anything).
+					Return(new(dbResultMock), nil)
+			}
 
 			txMockInstance := new(txMock)
 			if scanErr == nil {
@@ -311,7 +653,7 @@ func TestNewGosmig(t *testing.T) {
 				migrations, connectToDB, nil, getArgs, osExit, &outW, &errW)
 			require.NoError(t, err)
 			goSMig()
-			require.Equal(t, 5+i, exitCode)
+			require.Equal(t, wantExitCodes[cmd], exitCode)
 			dbRowMockInstance.AssertExpectations(t)
 			dbMockInstance.AssertExpectations(t)
 			txMockInstance.AssertExpectations(t)
@@ -331,15 +673,237 @@ func TestNewGosmig(t *testing.T) {
 			require.Empty(t, outW)
 		})
 	}
+
+	t.Run("executes command squash", func(t *testing.T) {
+		dbRowMockInstance := new(dbRowMock)
+		dbRowMockInstance.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*int)) = 1
+		}).Return(nil)
+
+		dbMockInstance := new(dbMock)
+		dbMockInstance.On("ExecContext", mock.Anything, mock.Anything).
+			Return(new(dbResultMock), nil)
+		dbMockInstance.On("QueryRowContext", mock.Anything, mock.Anything).
+			Return(dbRowMockInstance)
+		dbMockInstance.On("Close").Return(nil)
+
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			return dbMockInstance, nil
+		}
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		dumper := &fakeSchemaDumper{schema: "CREATE TABLE t (c INT);\n"}
+		config := &Config{MigrationsDir: t.TempDir(), SchemaDumper: dumper}
+		goSMig, err := newGosmig(
+			createTestMigrations(1, 2), connectToDB, config,
+			func() []string { return []string{"postgres://localhost/db", "squash", "--upto", "1"} },
+			osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, 0, exitCode, errW.String())
+		require.Equal(t, "postgres://localhost/db", dumper.gotURL)
+		require.Contains(t, outW.String(), "Squashed migrations up to version 1 into a baseline")
+
+		outW.Reset()
+		config.SchemaDumper = nil
+		goSMig, err = newGosmig(
+			createTestMigrations(1, 2), connectToDB, config,
+			func() []string { return []string{"postgres://localhost/db", "squash", "--upto", "1"} },
+			osExit, &outW, &errW)
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitFailure, exitCode)
+		require.Contains(t, errW.String(), "squash needs a schema dumper")
+	})
+
+	t.Run("executes commands seed and seed-status", func(t *testing.T) {
+		dbRowMockInstance := new(dbRowMock)
+		dbRowMockInstance.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*int)) = 0
+		}).Return(nil)
+
+		txMockInstance := new(txMock)
+		txMockInstance.On("ExecContext", mock.Anything, insertSeedSetSQL("gosmig_seed"), "dev").
+			Return(new(dbResultMock), nil).
+			Once()
+		txMockInstance.On("Commit").Return(nil).Once()
+
+		dbMockInstance := new(dbMock)
+		dbMockInstance.On("ExecContext", mock.Anything, createSeedTblSQL("gosmig_seed")).
+			Return(new(dbResultMock), nil)
+		dbMockInstance.On("ExecContext", mock.Anything, mock.Anything).
+			Return(new(dbResultMock), nil)
+		dbMockInstance.On("QueryRowContext", mock.Anything, mock.Anything, mock.Anything).
+			Return(dbRowMockInstance)
+		dbMockInstance.On("QueryRowContext", mock.Anything, mock.Anything).
+			Return(dbRowMockInstance)
+		dbMockInstance.On("BeginTx", mock.Anything, mock.Anything).
+			Return(txMockInstance, nil).
+			Once()
+		dbMockInstance.On("Close").Return(nil)
+
+		connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+			return dbMockInstance, nil
+		}
+		var exitCode int
+		osExit := func(code int) { exitCode = code }
+		var outW, errW strings.Builder
+
+		var seeded bool
+		seedSets := []SeedSet[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock]{{
+			Name: "dev",
+			Seed: func(ctx context.Context, tx *txMock) error {
+				seeded = true
+				return nil
+			},
+		}}
+
+		goSMig, err := newGosmig(
+			createTestMigrations(1), connectToDB, nil,
+			func() []string { return []string{"postgres://localhost/db", "seed", "dev"} },
+			osExit, &outW, &errW, WithSeedSets(seedSets))
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, 0, exitCode, errW.String())
+		require.True(t, seeded)
+		require.Equal(t, "[x] Applied seed set dev\n", outW.String())
+		txMockInstance.AssertExpectations(t)
+
+		outW.Reset()
+		goSMig, err = newGosmig(
+			createTestMigrations(1), connectToDB, nil,
+			func() []string { return []string{"postgres://localhost/db", "seed-status", "--exit-code"} },
+			osExit, &outW, &errW, WithSeedSets(seedSets))
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitPendingMigrations, exitCode)
+		require.Equal(t, "1 pending seed set(s)\n", errW.String())
+		require.Contains(t, outW.String(), "dev      [ ] PENDING  -")
+
+		errW.Reset()
+		goSMig, err = newGosmig(
+			createTestMigrations(1), connectToDB, nil,
+			func() []string { return []string{"postgres://localhost/db", "seed", "prod"} },
+			osExit, &outW, &errW, WithSeedSets(seedSets))
+		require.NoError(t, err)
+		goSMig()
+		require.Equal(t, ExitFailure, exitCode)
+		require.Equal(t, "unknown seed set: \"prod\"\n", errW.String())
+	})
+}
+
+func TestNewGosmigDBAhead(t *testing.T) {
+	testCases := []struct {
+		name       string
+		migrations []migrationMock
+		args       []string
+	}{
+		{
+			name:       "down",
+			migrations: createTestMigrations(1, 2),
+			args:       []string{"postgres://localhost/db", "down"},
+		},
+		{
+			name: "contract phase",
+			migrations: []migrationMock{
+				createTestContractMigration(1, nil),
+				createTestContractMigration(2, nil),
+			},
+			args: []string{"postgres://localhost/db", "up", "--phase", "contract"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbMockInstance := new(dbMock)
+			dbMockInstance.On("ExecContext", mock.Anything, createMigsTblSQL(migrationsTableName)).
+				Return(new(dbResultMock), nil)
+			if hasContract(tc.migrations) {
+				dbMockInstance.On("ExecContext", mock.Anything, addContractedAtColumnSQL(migrationsTableName)).
+					Return(new(dbResultMock), nil).
+					Once()
+			}
+			dbMockInstance.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(3)).
+				Once()
+			dbMockInstance.On("Close").Return(nil)
+
+			connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+				return dbMockInstance, nil
+			}
+			var exitCode int
+			osExit := func(code int) { exitCode = code }
+			var outW, errW strings.Builder
+
+			goSMig, err := newGosmig(
+				tc.migrations, connectToDB, nil,
+				func() []string { return tc.args },
+				osExit, &outW, &errW)
+			require.NoError(t, err)
+			goSMig()
+			require.Equal(t, ExitDBAhead, exitCode)
+			require.Contains(t, errW.String(), "database ahead of the code, unknown migrations applied: "+
+				"database version 3 > version 2")
+			require.Empty(t, outW.String())
+			dbMockInstance.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewGosmigWidensVersionColumn(t *testing.T) {
+	testCases := []struct {
+		name      string
+		isInteger bool
+	}{
+		{name: "integer column", isInteger: true},
+		{name: "bigint column"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbMockInstance := new(dbMock)
+			dbMockInstance.On("ExecContext", mock.Anything, createMigsTblSQL(migrationsTableName)).
+				Return(new(dbResultMock), nil).
+				Once()
+			dbMockInstance.On("QueryRowContext", mock.Anything, selectVersionIntegerSQL(), "", migrationsTableName).
+				Return(boolRow(tc.isInteger)).
+				Once()
+			if tc.isInteger {
+				dbMockInstance.On("ExecContext", mock.Anything, alterVersionBigintSQL(migrationsTableName)).
+					Return(new(dbResultMock), nil).
+					Once()
+			}
+			dbMockInstance.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(20261016093000)).
+				Once()
+			dbMockInstance.On("Close").Return(nil)
+
+			connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+				return dbMockInstance, nil
+			}
+			var outW, errW strings.Builder
+
+			goSMig, err := newGosmig(
+				createTestMigrations(20261016093000), connectToDB, nil,
+				func() []string { return []string{"postgres://localhost/db", "version"} },
+				func(int) {}, &outW, &errW)
+			require.NoError(t, err)
+			goSMig()
+			require.Empty(t, errW.String())
+			require.Contains(t, outW.String(), "20261016093000")
+			dbMockInstance.AssertExpectations(t)
+		})
+	}
 }
 
 func TestParseArgs(t *testing.T) {
 	type testCase struct {
-		name        string
-		args        []string
-		wantURL     string
-		wantCommand string
-		wantErr     string
+		name     string
+		args     []string
+		wantArgs cliArgs
+		wantErr  string
 	}
 
 	testCases := []testCase{
@@ -348,25 +912,176 @@ func TestParseArgs(t *testing.T) {
 			args:    []string{"postgres://localhost/db"},
 			wantErr: "wrong number of arguments",
 		},
+		{
+			name:    "too many arguments",
+			args:    []string{"postgres://localhost/db", "up", "extra"},
+			wantErr: "wrong number of arguments",
+		},
 		{
 			name:    "unknown command",
 			args:    []string{"postgres://localhost/db", "invalid"},
 			wantErr: "unknown command",
 		},
+		{
+			name:    "unknown flag",
+			args:    []string{"--unknown", "postgres://localhost/db", "up"},
+			wantErr: "flag provided but not defined: -unknown",
+		},
+		{
+			name:    "missing command argument",
+			args:    []string{"completion"},
+			wantErr: "wrong number of arguments",
+		},
+		{
+			name: "command without URL",
+			args: []string{"status"},
+			wantArgs: cliArgs{
+				command:     cmdStatus,
+				commandArgs: []string{},
+			},
+		},
+		{
+			name: "command with argument",
+			args: []string{"completion", "zsh"},
+			wantArgs: cliArgs{
+				command:     cmdCompletion,
+				commandArgs: []string{"zsh"},
+			},
+		},
+		{
+			name: "flags before and after positional arguments",
+			args: []string{"--config", "gosmig.json", "up", "--env=dev"},
+			wantArgs: cliArgs{
+				command:     cmdUp,
+				commandArgs: []string{},
+				configFile:  "gosmig.json",
+				env:         "dev",
+			},
+		},
+		{
+			name: "URL and flags interspersed",
+			args: []string{"postgres://localhost/db", "--env", "prod", "down"},
+			wantArgs: cliArgs{
+				url:         "postgres://localhost/db",
+				command:     cmdDown,
+				commandArgs: []string{},
+				env:         "prod",
+			},
+		},
+		{
+			name: "create flags",
+			args: []string{"create", "add_users", "--sql", "--no-tx"},
+			wantArgs: cliArgs{
+				command:     cmdCreate,
+				commandArgs: []string{"add_users"},
+				sql:         true,
+				noTX:        true,
+			},
+		},
+		{
+			name: "atomic flag",
+			args: []string{"postgres://localhost/db", "up", "--atomic"},
+			wantArgs: cliArgs{
+				url:         "postgres://localhost/db",
+				command:     cmdUp,
+				commandArgs: []string{},
+				atomic:      true,
+			},
+		},
+		{
+			name: "force flag",
+			args: []string{"postgres://localhost/db", "down", "--force"},
+			wantArgs: cliArgs{
+				url:         "postgres://localhost/db",
+				command:     cmdDown,
+				commandArgs: []string{},
+				force:       true,
+			},
+		},
+		{
+			name: "phase flag",
+			args: []string{"postgres://localhost/db", "up", "--phase", "contract"},
+			wantArgs: cliArgs{
+				url:         "postgres://localhost/db",
+				command:     cmdUp,
+				commandArgs: []string{},
+				phase:       phaseContract,
+			},
+		},
+		{
+			name:    "invalid phase",
+			args:    []string{"postgres://localhost/db", "up", "--phase", "migrate"},
+			wantErr: `invalid phase: "migrate" (expected expand or contract)`,
+		},
+		{
+			name: "tags flag",
+			args: []string{"postgres://localhost/db", "up", "--tags", "prod,eu"},
+			wantArgs: cliArgs{
+				url:         "postgres://localhost/db",
+				command:     cmdUp,
+				commandArgs: []string{},
+				tags:        "prod,eu",
+			},
+		},
+		{
+			name: "history flags",
+			args: []string{
+				"postgres://localhost/db", "history", "--version", "3", "--since", "2026-10-01",
+				"--direction", "down", "--json"},
+			wantArgs: cliArgs{
+				url:         "postgres://localhost/db",
+				command:     cmdHistory,
+				commandArgs: []string{},
+				historyFilter: historyFilter{
+					version:   3,
+					since:     time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+					direction: DirectionDown,
+				},
+				json: true,
+			},
+		},
+		{
+			name:    "invalid direction",
+			args:    []string{"postgres://localhost/db", "history", "--direction", "sideways"},
+			wantErr: `invalid direction: "sideways" (expected up or down)`,
+		},
+		{
+			name:    "invalid goto version",
+			args:    []string{"postgres://localhost/db", "goto", "latest"},
+			wantErr: `invalid version: "latest"`,
+		},
+		{
+			name:    "negative goto version",
+			args:    []string{"postgres://localhost/db", "goto", "--", "-1"},
+			wantErr: `invalid version: "-1"`,
+		},
 	}
 
 	for _, cmd := range allCommands {
+		args := []string{"postgres://localhost/db", cmd}
+		commandArgs := []string{}
+		for i := range commandNbArgs[cmd] {
+			commandArgs = append(commandArgs, fmt.Sprintf("arg%d", i))
+		}
+		wantArgs := cliArgs{
+			url:         "postgres://localhost/db",
+			command:     cmd,
+			commandArgs: commandArgs,
+		}
+		if cmd == cmdGoto || cmd == cmdResetBatch {
+			commandArgs[0] = "7"
+			wantArgs.target = 7
+		}
 		testCases = append(testCases, testCase{
-			name:        fmt.Sprintf("valid command %s", cmd),
-			args:        []string{"postgres://localhost/db", cmd},
-			wantURL:     "postgres://localhost/db",
-			wantCommand: cmd,
+			name:     fmt.Sprintf("valid command %s", cmd),
+			args:     append(args, commandArgs...),
+			wantArgs: wantArgs,
 		})
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			gotURL, gotCommand, err := parseArgs(tc.args)
+			gotArgs, err := parseArgs(tc.args)
 
 			if tc.wantErr != "" {
 				require.Error(t, err)
@@ -374,31 +1089,169 @@ func TestParseArgs(t *testing.T) {
 				return
 			}
 
+			require.NoError(t, err)
+			require.Equal(t, tc.wantArgs, gotArgs)
+		})
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	configFile := writeTestConfigFile(t, "gosmig.json", `{
+		"environments": {
+			"dev": {"url": "postgres://localhost/dev", "table": "dev_migrations"},
+			"prod": {"url": "postgres://localhost/prod", "timeout": "1m"}
+		}
+	}`)
+
+	testCases := []struct {
+		name       string
+		config     Config
+		args       cliArgs
+		wantURL    string
+		wantConfig Config
+		wantErr    string
+	}{
+		{
+			name:    "missing URL",
+			config:  *DefaultConfig(),
+			args:    cliArgs{command: cmdUp},
+			wantErr: "missing database URL",
+		},
+		{
+			name:       "URL from command line, no config file",
+			config:     *DefaultConfig(),
+			args:       cliArgs{url: "postgres://localhost/db", command: cmdUp},
+			wantURL:    "postgres://localhost/db",
+			wantConfig: *DefaultConfig(),
+		},
+		{
+			name:    "URL from config file environment selected by flag",
+			config:  *DefaultConfig(),
+			args:    cliArgs{command: cmdUp, configFile: configFile, env: "dev"},
+			wantURL: "postgres://localhost/dev",
+			wantConfig: Config{
+				Timeout:    defaultTimeout,
+				TableName:  "dev_migrations",
+				Versioning: VersioningSequential,
+				ConfigFile: configFile,
+				Env:        "dev",
+			},
+		},
+		{
+			name: "config file and environment from config, URL from command line",
+			config: Config{
+				Timeout:    defaultTimeout,
+				TableName:  migrationsTableName,
+				Versioning: VersioningSequential,
+				ConfigFile: configFile,
+				Env:        "prod",
+			},
+			args:    cliArgs{url: "postgres://localhost/db", command: cmdUp},
+			wantURL: "postgres://localhost/db",
+			wantConfig: Config{
+				Timeout:    time.Minute,
+				TableName:  migrationsTableName,
+				Versioning: VersioningSequential,
+				ConfigFile: configFile,
+				Env:        "prod",
+			},
+		},
+		{
+			name:    "config file error",
+			config:  *DefaultConfig(),
+			args:    cliArgs{command: cmdUp, configFile: configFile, env: "staging"},
+			wantErr: `environment "staging" not found in config file (available: dev, prod)`,
+		},
+		{
+			name:    "tags from command line",
+			config:  *DefaultConfig(),
+			args:    cliArgs{url: "postgres://localhost/db", command: cmdUp, tags: "prod, eu"},
+			wantURL: "postgres://localhost/db",
+			wantConfig: Config{
+				Timeout:    defaultTimeout,
+				TableName:  migrationsTableName,
+				Versioning: VersioningSequential,
+				Tags:       []string{"prod", "eu"},
+			},
+		},
+		{
+			name:    "force from command line",
+			config:  *DefaultConfig(),
+			args:    cliArgs{url: "postgres://localhost/db", command: cmdDown, force: true},
+			wantURL: "postgres://localhost/db",
+			wantConfig: Config{
+				Timeout:              defaultTimeout,
+				TableName:            migrationsTableName,
+				Versioning:           VersioningSequential,
+				AllowUnknownVersions: true,
+			},
+		},
+		{
+			name:    "invalid tags from command line",
+			config:  *DefaultConfig(),
+			args:    cliArgs{url: "postgres://localhost/db", command: cmdUp, tags: "prod;eu"},
+			wantErr: `invalid config: tag "prod;eu" must only contain letters, digits, _ and -`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotConfig, gotURL, err := resolveConfig(tc.config, tc.args)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+
 			require.NoError(t, err)
 			require.Equal(t, tc.wantURL, gotURL)
-			require.Equal(t, tc.wantCommand, gotCommand)
+			require.Equal(t, tc.wantConfig, *gotConfig)
 		})
 	}
 }
 
 func TestUsage(t *testing.T) {
-	want := "Usage: gosmig <db_url> <up|up-one|down|status|version>"
+	want := "Usage: gosmig [--config <file> [--env <name>]] [--exit-code] [--sql] [--no-tx] " +
+		"[--atomic] [--phase <expand|contract>] [--upto <version>] [--tags <tag,...>] [--set <name>] [--force] " +
+		"[--version <version>] [--since <time>] [--until <time>] [--direction <up|down>] [--json] " +
+		"[<db_url>] <up|up-one|down|goto|status|plan|version|check|history|unlock|reset-batch|completion|validate|create|squash|seed|seed-status> [<args>]"
 	require.Equal(t, want, usage())
 }
 
 func TestErrExit(t *testing.T) {
-	var exitCode int
-	mockOsExit := func(code int) {
-		exitCode = code
+	testCases := []struct {
+		name       string
+		errCode    int
+		err        error
+		wantOutput string
+	}{
+		{
+			name:    "usage error",
+			errCode: ExitUsageError,
+			err:     errors.New("wrong number of arguments"),
+			wantOutput: usage() + "\n" +
+				"wrong number of arguments\n",
+		},
+		{
+			name:       "other error",
+			errCode:    ExitConnectionError,
+			err:        fmt.Errorf("failed to connect to database: %s", "timed out"),
+			wantOutput: "failed to connect to database: timed out\n",
+		},
 	}
 
-	err := fmt.Errorf("failed to connect to database: %s", "timed out")
-	var output strings.Builder
-	errExit(3, err, &output, mockOsExit)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var exitCode int
+			mockOsExit := func(code int) {
+				exitCode = code
+			}
+
+			var output strings.Builder
+			errExit(tc.errCode, tc.err, &output, mockOsExit)
 
-	wantOutput := `Usage: gosmig <db_url> <up|up-one|down|status|version>
-failed to connect to database: timed out
-`
-	require.Equal(t, wantOutput, output.String())
-	require.Equal(t, 3, exitCode)
+			require.Equal(t, tc.wantOutput, output.String())
+			require.Equal(t, tc.errCode, exitCode)
+		})
+	}
 }
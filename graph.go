@@ -0,0 +1,534 @@
+package gosmig
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// usesGraph tells whether the migrations have modules or dependencies, in
+// which case they are ordered topologically and tracked by ID instead of by
+// version (see Migration.Module).
+func usesGraph[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) bool {
+
+	return slices.ContainsFunc(migrations, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return m.Module != "" || len(m.DependsOn) > 0
+	})
+}
+
+// graphValidationErrs checks the dependencies of migrations with modules or
+// dependencies, and that they don't use the features which rely on a linear
+// version chain.
+func graphValidationErrs[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) []string {
+
+	ids := make(map[string]bool, len(migrations))
+	for _, mig := range migrations {
+		ids[mig.ID()] = true
+	}
+
+	var errs []string
+	for _, mig := range migrations {
+		for _, feature := range []struct {
+			name string
+			used bool
+		}{
+			{"Baseline", mig.Baseline},
+			{"Batched", mig.Batched != nil},
+			{"Contract", mig.Contract != nil},
+			{"Tags", len(mig.Tags) > 0},
+		} {
+			if feature.used {
+				errs = append(errs, fmt.Sprintf(
+					"migration %s: %s is not supported for migrations with modules or dependencies",
+					mig.ID(), feature.name))
+			}
+		}
+
+		for _, dep := range mig.DependsOn {
+			if !ids[dep] {
+				errs = append(errs, fmt.Sprintf(
+					"migration %s depends on unknown migration %s", mig.ID(), dep))
+			}
+		}
+	}
+
+	if _, err := sortMigrationsGraph(migrations); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	return errs
+}
+
+// sortMigrationsGraph returns the migrations in topological order: each one
+// after the previous version of its module and after the migrations it
+// depends on. Among the migrations which are ready, the ones with the lowest
+// versions come first. It fails if the dependencies have a cycle.
+func sortMigrationsGraph[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) ([]Migration[TDBRow, TDBResult, TTX, TTXO, TDB], error) {
+
+	remaining := slices.Clone(migrations)
+	slices.SortFunc(remaining, func(a, b Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) int {
+		return cmp.Or(cmp.Compare(a.Version, b.Version), cmp.Compare(a.Module, b.Module))
+	})
+
+	deps := make(map[string][]string, len(remaining))
+	prevByModule := make(map[string]string)
+	for _, mig := range remaining {
+		id := mig.ID()
+		if prev, ok := prevByModule[mig.Module]; ok {
+			deps[id] = append(deps[id], prev)
+		}
+		prevByModule[mig.Module] = id
+		deps[id] = append(deps[id], mig.DependsOn...)
+	}
+
+	// Dependencies on unknown migrations are reported by graphValidationErrs.
+	pending := make(map[string]bool, len(remaining))
+	for _, mig := range remaining {
+		pending[mig.ID()] = true
+	}
+	pendingDep := func(id string) (string, bool) {
+		for _, dep := range deps[id] {
+			if pending[dep] {
+				return dep, true
+			}
+		}
+		return "", false
+	}
+
+	ordered := make([]Migration[TDBRow, TDBResult, TTX, TTXO, TDB], 0, len(remaining))
+	for len(remaining) > 0 {
+		i := slices.IndexFunc(remaining, func(mig Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+			_, blocked := pendingDep(mig.ID())
+			return !blocked
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("dependency cycle: %s", findCycle(remaining[0].ID(), pendingDep))
+		}
+
+		ordered = append(ordered, remaining[i])
+		delete(pending, remaining[i].ID())
+		remaining = slices.Delete(remaining, i, i+1)
+	}
+
+	return ordered, nil
+}
+
+// findCycle follows the pending dependencies from the given migration, all of
+// which have one, until it loops, and returns the loop (e.g. "a/1 -> b/1 -> a/1").
+func findCycle(id string, pendingDep func(id string) (string, bool)) string {
+	var path []string
+	for !slices.Contains(path, id) {
+		path = append(path, id)
+		id, _ = pendingDep(id)
+	}
+	path = append(path[slices.Index(path, id):], id)
+	return strings.Join(path, " -> ")
+}
+
+// createGraphTable creates the table tracking the migrations by ID if it
+// doesn't exist and, in the same transaction, records in it the migrations
+// applied so far by version, so that adopting modules or dependencies doesn't
+// apply them again. The versions must then be the IDs of migrations without a
+// module, or else it fails with ErrGraphBackfill. This is only done when the
+// table is created: the versions aren't deleted when their migrations are
+// rolled back by ID, and would otherwise be recorded again.
+func createGraphTable[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) error {
+
+	graphTable := graphTableName(config.migrationsTable())
+	return executeInTx(ctx, db, func(ctx context.Context, tx TTX) error {
+		exists, err := tableExists(ctx, tx, graphTable, config)
+		if err != nil || exists {
+			return err
+		}
+
+		if err := createGraphTableIfNotExists(ctx, tx, config); err != nil {
+			return err
+		}
+
+		appliedVersions, err := getAppliedVersions(ctx, tx, false, config)
+		if err != nil {
+			return err
+		}
+
+		ids := make(map[string]bool, len(migrations))
+		for _, migration := range migrations {
+			ids[migration.ID()] = true
+		}
+
+		for _, version := range slices.Sorted(maps.Keys(appliedVersions)) {
+			id := strconv.Itoa(version)
+			if !ids[id] {
+				return fmt.Errorf(
+					"%w: version %d is applied, but no migration without a module has it "+
+						"(record the applied migrations in %s by ID)",
+					ErrGraphBackfill, version, graphTable)
+			}
+			if err := insertAppliedID(ctx, tx, id, config); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, config.Timeout)
+}
+
+// runGraphUp applies the pending migrations with modules or dependencies, in
+// topological order, or only the first limit ones if limit > 0. It returns
+// the number of migrations applied.
+func runGraphUp[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	limit int,
+	config *Config,
+) (int, error) {
+
+	ordered, err := sortMigrationsGraph(migrations)
+	if err != nil {
+		return 0, err
+	}
+
+	applied, err := getAppliedIDs(ctx, db, config)
+	if err != nil {
+		return 0, err
+	}
+
+	var nbAppliedMigrations int
+
+	for _, migration := range ordered {
+		id := migration.ID()
+		if applied[id] {
+			continue
+		}
+
+		if migration.UpDown != nil {
+			err = runMigration(ctx, db, migration, DirectionUp, TxModeTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeInTx(ctx, db, migrateGraphUp(id, migration.UpDown.Up, config), config.Timeout)
+				})
+			if err != nil {
+				return 0, newGraphMigrationError(migration, DirectionUp, TxModeTX, err)
+			}
+		} else {
+			err = runMigration(ctx, db, migration, DirectionUp, TxModeNoTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeNoTx(ctx, db, migrateGraphUp(id, migration.UpDownNoTX.Up, config), config.Timeout)
+				})
+			if err != nil {
+				return 0, newGraphMigrationError(migration, DirectionUp, TxModeNoTX, err)
+			}
+		}
+
+		_, _ = fmt.Fprintf(output, "[x] Applied migration %s\n", id)
+
+		nbAppliedMigrations++
+		if limit > 0 && nbAppliedMigrations == limit {
+			break
+		}
+	}
+
+	if nbAppliedMigrations == 0 {
+		_, _ = fmt.Fprintln(output, "No migrations to apply")
+		return 0, nil
+	}
+
+	_, _ = fmt.Fprintf(output, "%d migration(s) applied\n", nbAppliedMigrations)
+
+	return nbAppliedMigrations, nil
+}
+
+// runGraphDown rolls back the last applied migration, in topological order,
+// among the migrations with modules or dependencies, so that no applied
+// migration depends on it.
+func runGraphDown[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+) error {
+
+	ordered, err := sortMigrationsGraph(migrations)
+	if err != nil {
+		return err
+	}
+
+	applied, err := getAppliedIDs(ctx, db, config)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range slices.Backward(ordered) {
+		id := migration.ID()
+		if !applied[id] {
+			continue
+		}
+
+		if migration.Irreversible {
+			return fmt.Errorf("%w: migration %s", ErrIrreversibleMigration, id)
+		}
+
+		if migration.UpDown != nil {
+			err = runMigration(ctx, db, migration, DirectionDown, TxModeTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeInTx(ctx, db, migrateGraphDown(id, migration.UpDown.Down, config), config.Timeout)
+				})
+			if err != nil {
+				return newGraphMigrationError(migration, DirectionDown, TxModeTX, err)
+			}
+		} else {
+			err = runMigration(ctx, db, migration, DirectionDown, TxModeNoTX, "", false, config,
+				func(ctx context.Context) error {
+					return executeNoTx(ctx, db, migrateGraphDown(id, migration.UpDownNoTX.Down, config), config.Timeout)
+				})
+			if err != nil {
+				return newGraphMigrationError(migration, DirectionDown, TxModeNoTX, err)
+			}
+		}
+
+		_, _ = fmt.Fprintf(output, "[x]-->[ ] Rolled back migration %s\n", id)
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(output, "No migrations to roll back")
+
+	return nil
+}
+
+// writeGraphStatus writes the status of the migrations with modules or
+// dependencies, in reverse topological order after the applied ones unknown
+// to the code, and returns the number of pending ones.
+func writeGraphStatus[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	w io.Writer,
+	config *Config,
+) (int, error) {
+
+	ordered, err := sortMigrationsGraph(migrations)
+	if err != nil {
+		return 0, err
+	}
+
+	applied, err := readAppliedIDs(ctx, db, config)
+	if err != nil {
+		return 0, err
+	}
+
+	var unknown []string
+	for id := range applied {
+		if !slices.ContainsFunc(ordered, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+			return m.ID() == id
+		}) {
+			unknown = append(unknown, id)
+		}
+	}
+	slices.Sort(unknown)
+
+	idWidth := 10
+	for _, migration := range ordered {
+		idWidth = max(idWidth, len(migration.ID()))
+	}
+	for _, id := range unknown {
+		idWidth = max(idWidth, len(id))
+	}
+
+	var nbPending int
+	_, _ = fmt.Fprintf(w, "%-*s %-12s\n", idWidth, "ID", "STATUS")
+	// The unknown migrations have no place in the graph of the code, so they
+	// come first.
+	for _, id := range slices.Backward(unknown) {
+		line := fmt.Sprintf("%-*s %-12s", idWidth, id, "[?] UNKNOWN")
+		_, _ = fmt.Fprintln(w, strings.TrimRight(line, " ")+" (applied, but unknown to the code)")
+	}
+	for _, migration := range slices.Backward(ordered) {
+		status := "[x] APPLIED"
+		if !applied[migration.ID()] {
+			status = "[ ] PENDING"
+			nbPending++
+		}
+
+		var notes []string
+		if len(migration.DependsOn) > 0 {
+			notes = append(notes, "depends on: "+strings.Join(migration.DependsOn, ", "))
+		}
+		if migration.Irreversible {
+			notes = append(notes, "irreversible")
+		}
+
+		line := fmt.Sprintf("%-*s %-12s", idWidth, migration.ID(), status)
+		if len(notes) > 0 {
+			line = strings.TrimRight(line, " ") + " (" + strings.Join(notes, "; ") + ")"
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+
+	return nbPending, nil
+}
+
+// runGraphVersion writes the number of applied migrations, since migrations
+// with modules or dependencies have no database version.
+func runGraphVersion[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	ctx context.Context,
+	dbOrTX TDBOrTX,
+	output io.Writer,
+	config *Config,
+) error {
+
+	applied, err := getAppliedIDs(ctx, dbOrTX, config)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(output, "Applied migrations:\n%d\n", len(applied))
+	return nil
+}
+
+func migrateGraphUp[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	id string,
+	up func(ctx context.Context, dbOrTX TDBOrTX) error,
+	config *Config,
+) func(context.Context, TDBOrTX) error {
+
+	return func(ctx context.Context, dbOrTX TDBOrTX) error {
+		applied, err := isMigrationIDApplied(ctx, dbOrTX, id, config)
+		if err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		if applied {
+			return withPhase(PhaseBookkeeping, fmt.Errorf(
+				"%w: migration %s already applied", ErrDBVersionChangedUp, id))
+		}
+
+		if err := setSearchPath(ctx, dbOrTX, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		migCtx, cancelMig := context.WithTimeout(ctx, config.Timeout)
+		defer cancelMig()
+		if err := up(migCtx, dbOrTX); err != nil {
+			return withPhase(PhaseFunc, fmt.Errorf(
+				"failed to apply migration.up %s: %w", id, err))
+		}
+
+		if err := insertAppliedID(ctx, dbOrTX, id, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		return nil
+	}
+}
+
+func migrateGraphDown[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	id string,
+	down func(ctx context.Context, dbOrTX TDBOrTX) error,
+	config *Config,
+) func(context.Context, TDBOrTX) error {
+
+	return func(ctx context.Context, dbOrTX TDBOrTX) error {
+		applied, err := isMigrationIDApplied(ctx, dbOrTX, id, config)
+		if err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		if !applied {
+			return withPhase(PhaseBookkeeping, fmt.Errorf(
+				"%w: migration %s not applied", ErrDBVersionChangedDown, id))
+		}
+
+		if err := setSearchPath(ctx, dbOrTX, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		migCtx, cancelMig := context.WithTimeout(ctx, config.Timeout)
+		defer cancelMig()
+		if err := down(migCtx, dbOrTX); err != nil {
+			return withPhase(PhaseFunc, fmt.Errorf(
+				"failed to apply migration.down %s: %w", id, err))
+		}
+
+		if err := deleteAppliedID(ctx, dbOrTX, id, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		return nil
+	}
+}
+
+func newGraphMigrationError[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migration Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	direction Direction,
+	txMode TxMode,
+	err error,
+) *MigrationError {
+
+	migErr := newMigrationError(migration.Version, direction, txMode, err)
+	migErr.Module = migration.Module
+	return migErr
+}
@@ -0,0 +1,427 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestModuleMigration creates a migration of the given module, which
+// depends on the given migrations.
+func createTestModuleMigration(module string, version int, dependsOn ...string) migrationMock {
+	migration := createTestMigrations(version)[0]
+	migration.Module = module
+	migration.DependsOn = dependsOn
+	return migration
+}
+
+// appliedIDsRow mocks the row with the comma-separated IDs of the applied migrations.
+func appliedIDsRow(ids string) *dbRowMock {
+	row := new(dbRowMock)
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*string)) = ids
+		}).
+		Return(nil).
+		Once()
+	return row
+}
+
+// boolRow mocks a row with 1 if b, or else 0 (e.g. telling whether a table
+// exists).
+func boolRow(b bool) *dbRowMock {
+	if b {
+		return dbVersionRow(1)
+	}
+	return dbVersionRow(0)
+}
+
+func migrationIDs(migrations []migrationMock) []string {
+	ids := make([]string, len(migrations))
+	for i, migration := range migrations {
+		ids[i] = migration.ID()
+	}
+	return ids
+}
+
+func TestSortMigrationsGraph(t *testing.T) {
+	testCases := []struct {
+		name       string
+		migrations []migrationMock
+		wantIDs    []string
+		wantErr    string
+	}{
+		{
+			name: "modules and dependencies",
+			migrations: []migrationMock{
+				createTestModuleMigration("users", 2),
+				createTestModuleMigration("billing", 1, "users/2"),
+				createTestModuleMigration("users", 1),
+				createTestModuleMigration("billing", 2),
+				createTestModuleMigration("", 1),
+			},
+			wantIDs: []string{"1", "users/1", "users/2", "billing/1", "billing/2"},
+		},
+		{
+			name: "dependency cycle",
+			migrations: []migrationMock{
+				createTestModuleMigration("users", 1, "billing/2"),
+				createTestModuleMigration("billing", 1),
+				createTestModuleMigration("billing", 2, "users/1"),
+			},
+			wantErr: "dependency cycle: users/1 -> billing/2 -> users/1",
+		},
+		{
+			name:       "self dependency",
+			migrations: []migrationMock{createTestModuleMigration("users", 1, "users/1")},
+			wantErr:    "dependency cycle: users/1 -> users/1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ordered, err := sortMigrationsGraph(tc.migrations)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantIDs, migrationIDs(ordered))
+		})
+	}
+}
+
+func TestValidateMigrationsGraph(t *testing.T) {
+	tagged := createTestModuleMigration("billing", 2)
+	tagged.Tags = []string{"prod"}
+
+	err := validateMigrations([]migrationMock{
+		createTestModuleMigration("users", 1, "billing/1"),
+		createTestModuleMigration("users", 1),
+		createTestModuleMigration("billing", 1, "users/1"),
+		tagged,
+		createTestModuleMigration("billing", 3, "orders/1"),
+		createTestModuleMigration("bad module", 1),
+	}, VersioningSequential)
+
+	require.ErrorIs(t, err, ErrInvalidMigrations)
+	for _, want := range []string{
+		`migration 1 module "bad module" must only contain letters, digits, _ and -`,
+		"migration users/1 is defined 2 times",
+		"migration billing/2: Tags is not supported for migrations with modules or dependencies",
+		"migration billing/3 depends on unknown migration orders/1",
+		"dependency cycle: billing/1 -> users/1 -> billing/1",
+	} {
+		require.ErrorContains(t, err, want)
+	}
+}
+
+func TestRunGraphUp(t *testing.T) {
+	migrations := []migrationMock{
+		createTestModuleMigration("users", 1),
+		createTestModuleMigration("billing", 1, "users/1"),
+		createTestModuleMigration("users", 2),
+	}
+
+	testCases := []struct {
+		name       string
+		appliedIDs string
+		limit      int
+		wantIDs    []string
+		wantOut    string
+	}{
+		{
+			name:       "applies the pending migrations in topological order",
+			appliedIDs: "users/1",
+			wantIDs:    []string{"billing/1", "users/2"},
+			wantOut: "[x] Applied migration billing/1\n" +
+				"[x] Applied migration users/2\n" +
+				"2 migration(s) applied\n",
+		},
+		{
+			name:    "up-one",
+			limit:   1,
+			wantIDs: []string{"users/1"},
+			wantOut: "[x] Applied migration users/1\n1 migration(s) applied\n",
+		},
+		{
+			name:       "no migrations to apply",
+			appliedIDs: "users/1,users/2,billing/1",
+			wantOut:    "No migrations to apply\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			graphTable := graphTableName(migrationsTableName)
+			db.On("QueryRowContext", mock.Anything, selectAppliedIDsSQL(graphTable)).
+				Return(appliedIDsRow(tc.appliedIDs)).
+				Once()
+			for _, id := range tc.wantIDs {
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectAppliedIDCountSQL(graphTable), id).
+					Return(dbVersionRow(0)).
+					Once()
+				tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("ExecContext", mock.Anything, insertAppliedIDSQL(graphTable), id).
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Commit").Return(nil).Once()
+			}
+
+			var out bytes.Buffer
+			_, err := runCmdUp(context.Background(), migrations, db, &out, tc.limit, DefaultConfig())
+			require.NoError(t, err)
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+			require.Equal(t, tc.wantOut, out.String())
+		})
+	}
+}
+
+func TestRunGraphUpFailure(t *testing.T) {
+	db := new(dbMock)
+	tx := new(txMock)
+	graphTable := graphTableName(migrationsTableName)
+	db.On("QueryRowContext", mock.Anything, selectAppliedIDsSQL(graphTable)).
+		Return(appliedIDsRow("")).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectAppliedIDCountSQL(graphTable), "users/1").
+		Return(dbVersionRow(1)).
+		Once()
+	tx.On("Rollback").Return(nil).Once()
+
+	var out bytes.Buffer
+	_, err := runCmdUp(context.Background(),
+		[]migrationMock{createTestModuleMigration("users", 1)}, db, &out, 0, DefaultConfig())
+
+	var migErr *MigrationError
+	require.ErrorAs(t, err, &migErr)
+	require.ErrorIs(t, err, ErrDBVersionChangedUp)
+	require.EqualError(t, err, "migration users/1 up failed (TX, bookkeeping phase): failed to "+
+		"execute in transaction: database version changed while applying migration up: "+
+		"migration users/1 already applied")
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
+
+func TestRunGraphDown(t *testing.T) {
+	db := new(dbMock)
+	tx := new(txMock)
+	graphTable := graphTableName(migrationsTableName)
+	// users/2 is ordered last, but billing/1 is the last applied one.
+	db.On("QueryRowContext", mock.Anything, selectAppliedIDsSQL(graphTable)).
+		Return(appliedIDsRow("users/1,billing/1")).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectAppliedIDCountSQL(graphTable), "billing/1").
+		Return(dbVersionRow(1)).
+		Once()
+	tx.On("ExecContext", mock.Anything, "DROP TABLE test").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, deleteAppliedIDSQL(graphTable), "billing/1").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("Commit").Return(nil).Once()
+
+	var out bytes.Buffer
+	err := runCmdDown(context.Background(), []migrationMock{
+		createTestModuleMigration("users", 1),
+		createTestModuleMigration("billing", 1, "users/1"),
+		createTestModuleMigration("users", 2),
+	}, db, &out, DefaultConfig())
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+	require.Equal(t, "[x]-->[ ] Rolled back migration billing/1\n", out.String())
+}
+
+func TestRunCmdStatusGraph(t *testing.T) {
+	testCases := []struct {
+		name          string
+		appliedIDs    string
+		wantOut       string
+		wantNbPending int
+	}{
+		{
+			name:       "applied and pending",
+			appliedIDs: "users/1",
+			wantOut: "ID         STATUS      \n" +
+				"billing/1  [ ] PENDING (depends on: users/1)\n" +
+				"users/1    [x] APPLIED \n",
+			wantNbPending: 1,
+		},
+		{
+			name:       "applied, but unknown to the code",
+			appliedIDs: "users/1,users/2,billing/1,audit_logs/1",
+			wantOut: "ID           STATUS      \n" +
+				"users/2      [?] UNKNOWN (applied, but unknown to the code)\n" +
+				"audit_logs/1 [?] UNKNOWN (applied, but unknown to the code)\n" +
+				"billing/1    [x] APPLIED (depends on: users/1)\n" +
+				"users/1      [x] APPLIED \n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			db.On("QueryRowContext", mock.Anything, tableExistsSQL(), graphTableName(migrationsTableName)).
+				Return(boolRow(true)).
+				Once()
+			db.On("QueryRowContext", mock.Anything, selectAppliedIDsSQL(graphTableName(migrationsTableName))).
+				Return(appliedIDsRow(tc.appliedIDs)).
+				Once()
+
+			var out bytes.Buffer
+			nbPending, err := runCmdStatus(context.Background(), []migrationMock{
+				createTestModuleMigration("users", 1),
+				createTestModuleMigration("billing", 1, "users/1"),
+			}, nil, db, &out, DefaultConfig())
+			require.NoError(t, err)
+			db.AssertExpectations(t)
+
+			require.Equal(t, tc.wantOut, out.String())
+			require.Equal(t, tc.wantNbPending, nbPending)
+		})
+	}
+}
+
+func TestRunCmdUpAtomicRefusesGraph(t *testing.T) {
+	var out bytes.Buffer
+	_, err := runCmdUpAtomic(context.Background(),
+		[]migrationMock{createTestModuleMigration("users", 1)}, new(dbMock), &out, DefaultConfig())
+	require.EqualError(t, err, "can't apply the migrations atomically: migrations with modules "+
+		"or dependencies are applied one by one")
+}
+
+func TestCreateGraphTable(t *testing.T) {
+	graphTable := graphTableName(migrationsTableName)
+	migrations := []migrationMock{
+		createTestMigrations(1)[0],
+		createTestMigrations(2)[0],
+		createTestModuleMigration("billing", 1, "2"),
+	}
+
+	testCases := []struct {
+		name            string
+		exists          bool
+		appliedVersions []int
+		wantInserted    []string
+		wantErr         string
+	}{
+		{
+			name: "new database",
+		},
+		{
+			name:            "records the versions applied before the modules",
+			appliedVersions: []int{1, 2},
+			wantInserted:    []string{"1", "2"},
+		},
+		{
+			name:            "already tracked by ID",
+			exists:          true,
+			appliedVersions: []int{1, 2},
+		},
+		{
+			name:            "applied version of no migration without a module",
+			appliedVersions: []int{1, 2, 3},
+			wantInserted:    []string{"1", "2"},
+			wantErr: "failed to execute in transaction: applied migrations can't be tracked by ID: " +
+				"version 3 is applied, but no migration without a module has it " +
+				"(record the applied migrations in gosmig_graph by ID)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+			tx.On("QueryRowContext", mock.Anything, tableExistsSQL(), graphTable).
+				Return(boolRow(tc.exists)).
+				Once()
+			if !tc.exists {
+				tx.On("ExecContext", mock.Anything, createGraphTblSQL(graphTable)).
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+					Return(appliedVersionsRow(tc.appliedVersions...)).
+					Once()
+			}
+			for _, id := range tc.wantInserted {
+				tx.On("ExecContext", mock.Anything, insertAppliedIDSQL(graphTable), id).
+					Return(new(dbResultMock), nil).
+					Once()
+			}
+			if tc.wantErr != "" {
+				tx.On("Rollback").Return(nil).Once()
+			} else {
+				tx.On("Commit").Return(nil).Once()
+			}
+
+			err := createGraphTable(context.Background(), migrations, db, DefaultConfig())
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.ErrorIs(t, err, ErrGraphBackfill)
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCreateGraphTableAfterRollback(t *testing.T) {
+	// All the migrations were rolled back by ID, but their versions are still
+	// in the migrations table: they must not be recorded again.
+	migrations := []migrationMock{
+		createTestMigrations(1)[0],
+		createTestModuleMigration("billing", 1, "1"),
+	}
+	graphTable := graphTableName(migrationsTableName)
+
+	db := new(dbMock)
+	tx := new(txMock)
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil)
+	tx.On("QueryRowContext", mock.Anything, tableExistsSQL(), graphTable).
+		Return(boolRow(true)).
+		Once()
+	tx.On("Commit").Return(nil)
+
+	require.NoError(t, createGraphTable(context.Background(), migrations, db, DefaultConfig()))
+
+	db.On("QueryRowContext", mock.Anything, selectAppliedIDsSQL(graphTable)).
+		Return(appliedIDsRow("")).
+		Once()
+	for _, id := range []string{"1", "billing/1"} {
+		tx.On("QueryRowContext", mock.Anything, selectAppliedIDCountSQL(graphTable), id).
+			Return(dbVersionRow(0)).
+			Once()
+		tx.On("ExecContext", mock.Anything, insertAppliedIDSQL(graphTable), id).
+			Return(new(dbResultMock), nil).
+			Once()
+	}
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), nil).
+		Twice()
+
+	var out bytes.Buffer
+	_, err := runCmdUp(context.Background(), migrations, db, &out, 0, DefaultConfig())
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+	require.Equal(t, "[x] Applied migration 1\n"+
+		"[x] Applied migration billing/1\n"+
+		"2 migration(s) applied\n", out.String())
+}
@@ -0,0 +1,255 @@
+package gosmig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+)
+
+// The outcomes of the migrations recorded in the history.
+const (
+	historyOutcomeSuccess = "success"
+	historyOutcomeFailure = "failure"
+	historyOutcomeSkipped = "skipped"
+)
+
+// historyTimeLayout is the layout of the times shown by the history command,
+// in UTC.
+const historyTimeLayout = "2006-01-02 15:04:05"
+
+// historyEntry is an entry of the history: a migration applied or rolled
+// back, or a failed attempt.
+type historyEntry struct {
+	ID         int64     `json:"id"`
+	Version    int       `json:"version"`
+	Module     string    `json:"module,omitempty"`
+	Direction  Direction `json:"direction"`
+	Phase      string    `json:"phase,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	DBUser     string    `json:"db_user"`
+	OSUser     string    `json:"os_user"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// id returns the ID of the migration of the entry (see Migration.ID).
+func (e historyEntry) id() string {
+	if e.Module == "" {
+		return strconv.Itoa(e.Version)
+	}
+	return e.Module + "/" + strconv.Itoa(e.Version)
+}
+
+// historyFilter selects the history entries shown by the history command.
+// Zero fields don't filter.
+type historyFilter struct {
+	version   int
+	since     time.Time
+	until     time.Time
+	direction Direction
+}
+
+// conditions returns the SQL conditions of the filter, along with their
+// positional arguments.
+func (f historyFilter) conditions() ([]string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.version > 0 {
+		add("version = $%d", f.version)
+	}
+	if !f.since.IsZero() {
+		add("started_at >= $%d", f.since)
+	}
+	if !f.until.IsZero() {
+		add("started_at < $%d", f.until)
+	}
+	if f.direction != "" {
+		add("direction = $%d", string(f.direction))
+	}
+
+	return conditions, args
+}
+
+// parseHistoryTime parses the time of the --since and --until flags, either
+// RFC 3339 (e.g. 2026-10-18T09:30:00Z) or a date (e.g. 2026-10-18, in UTC).
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(
+			"invalid time: %q (expected e.g. 2026-10-18 or 2026-10-18T09:30:00Z)", value)
+	}
+	return t, nil
+}
+
+// currentOSUser returns the name of the user running the tool, recorded in
+// the history along with the database user.
+func currentOSUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// newHistoryEntry returns the history entry of a step of the migration (of
+// the given phase, if any, e.g. contract) started at start: skipped (e.g. by
+// the tag selector) if skipped, or failed with err, if not nil.
+func newHistoryEntry[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migration Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	direction Direction,
+	phase string,
+	skipped bool,
+	start time.Time,
+	err error,
+) historyEntry {
+
+	entry := historyEntry{
+		Version:    migration.Version,
+		Module:     migration.Module,
+		Direction:  direction,
+		Phase:      phase,
+		StartedAt:  start,
+		DurationMS: time.Since(start).Milliseconds(),
+		OSUser:     currentOSUser(),
+		Outcome:    historyOutcomeSuccess,
+	}
+
+	if skipped {
+		entry.Outcome = historyOutcomeSkipped
+	}
+
+	if err != nil {
+		entry.Outcome = historyOutcomeFailure
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
+
+// runMigration runs a step of the migration in a span (see traceMigration)
+// and, if Config.History is set, records it in the history, whether it
+// succeeded or not.
+func runMigration[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	db TDB,
+	migration Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	direction Direction,
+	txMode TxMode,
+	phase string,
+	skipped bool,
+	config *Config,
+	run func(ctx context.Context) error,
+) error {
+
+	start := time.Now()
+	err := traceMigration(ctx, migration, direction, txMode, phase, skipped, config, run)
+	if !config.History {
+		return err
+	}
+
+	recordHistory(ctx, db, config, newHistoryEntry(migration, direction, phase, skipped, start, err))
+
+	return err
+}
+
+// historyWarnings is where the failures to record the history are reported.
+var historyWarnings io.Writer = os.Stderr
+
+// recordHistory records the entries in the history, outside of the
+// transaction of the migrations (if any), so that their failures are
+// recorded too, even if the command was canceled. The migrations are already
+// committed or rolled back by then, so a failure to record them is only
+// reported as a warning, without failing them.
+func recordHistory[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	ctx context.Context,
+	db TDBOrTX,
+	config *Config,
+	entries ...historyEntry,
+) {
+
+	ctx = context.WithoutCancel(ctx)
+	for _, entry := range entries {
+		if err := insertHistoryEntry(ctx, db, entry, config); err != nil {
+			_, _ = fmt.Fprintf(historyWarnings, "warning: %v\n", err)
+			return
+		}
+	}
+}
+
+func runCmdHistory[TDBRow DBRow, TDBResult DBResult, TDB DBOrTX[TDBRow, TDBResult]](
+	ctx context.Context,
+	db TDB,
+	output io.Writer,
+	filter historyFilter,
+	asJSON bool,
+	config *Config,
+) error {
+
+	if !config.History {
+		return errors.New("the migrations history is disabled (see Config.History)")
+	}
+
+	entries, err := getHistory(ctx, db, filter, config)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	migrationWidth, dbUserWidth, osUserWidth := len("MIGRATION"), len("DB USER"), len("OS USER")
+	for _, entry := range entries {
+		migrationWidth = max(migrationWidth, len(entry.id()))
+		dbUserWidth = max(dbUserWidth, len(entry.DBUser))
+		osUserWidth = max(osUserWidth, len(entry.OSUser))
+	}
+
+	_, _ = fmt.Fprintf(output, "%-19s %-*s %-15s %-8s %10s %-*s %-*s %s\n",
+		"STARTED AT (UTC)", migrationWidth, "MIGRATION", "DIRECTION", "OUTCOME", "DURATION",
+		dbUserWidth, "DB USER", osUserWidth, "OS USER", "ERROR")
+	for _, entry := range entries {
+		direction := string(entry.Direction)
+		if entry.Phase != "" {
+			direction += " (" + entry.Phase + ")"
+		}
+		duration := time.Duration(entry.DurationMS) * time.Millisecond
+
+		_, _ = fmt.Fprintf(output, "%-19s %-*s %-15s %-8s %10s %-*s %-*s %s\n",
+			entry.StartedAt.UTC().Format(historyTimeLayout), migrationWidth, entry.id(), direction,
+			entry.Outcome, duration, dbUserWidth, entry.DBUser, osUserWidth, entry.OSUser, entry.Error)
+	}
+
+	return nil
+}
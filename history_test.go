@@ -0,0 +1,330 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// expectHistoryEntry mocks the recording of a history entry.
+func expectHistoryEntry(db *dbMock, version int, direction Direction, phase, outcome, errMsg string) {
+	db.On("ExecContext", mock.Anything, insertHistorySQL(historyTableName(migrationsTableName)),
+		version, "", string(direction), phase, mock.Anything, mock.Anything, mock.Anything,
+		outcome, errMsg).
+		Return(new(dbResultMock), nil).
+		Once()
+}
+
+func TestHistoryFilterConditions(t *testing.T) {
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		filter         historyFilter
+		wantConditions []string
+		wantArgs       []any
+	}{
+		{
+			name: "no filter",
+		},
+		{
+			name:           "version and direction",
+			filter:         historyFilter{version: 3, direction: DirectionDown},
+			wantConditions: []string{"version = $1", "direction = $2"},
+			wantArgs:       []any{3, "down"},
+		},
+		{
+			name:           "time range",
+			filter:         historyFilter{since: since, until: until},
+			wantConditions: []string{"started_at >= $1", "started_at < $2"},
+			wantArgs:       []any{since, until},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conditions, args := tc.filter.conditions()
+			require.Equal(t, tc.wantConditions, conditions)
+			require.Equal(t, tc.wantArgs, args)
+		})
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	got, err := parseHistoryTime("2026-10-18")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), got)
+
+	got, err = parseHistoryTime("2026-10-18T09:30:00+02:00")
+	require.NoError(t, err)
+	require.True(t, got.Equal(time.Date(2026, 10, 18, 7, 30, 0, 0, time.UTC)))
+
+	_, err = parseHistoryTime("yesterday")
+	require.EqualError(t, err,
+		`invalid time: "yesterday" (expected e.g. 2026-10-18 or 2026-10-18T09:30:00Z)`)
+}
+
+func TestRunMigrationHistory(t *testing.T) {
+	config := DefaultConfig()
+	config.History = true
+
+	// Up succeeds.
+	db := new(dbMock)
+	tx := new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(0)).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(0)).
+		Once()
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 1).
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("Commit").Return(nil).Once()
+	expectHistoryEntry(db, 1, DirectionUp, "", historyOutcomeSuccess, "")
+
+	var output bytes.Buffer
+	_, err := runCmdUp(context.Background(), createTestMigrations(1), db, &output, 0, config)
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+
+	// Down fails: the failure is recorded, even though its transaction is
+	// rolled back.
+	db = new(dbMock)
+	tx = new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(1)).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(1)).
+		Once()
+	tx.On("ExecContext", mock.Anything, "DROP TABLE test").
+		Return(new(dbResultMock), errors.New("table in use")).
+		Once()
+	tx.On("Rollback").Return(nil).Once()
+	expectHistoryEntry(db, 1, DirectionDown, "", historyOutcomeFailure,
+		"failed to execute in transaction: failed to apply migration.down version 1: table in use")
+
+	err = runCmdDown(context.Background(), createTestMigrations(1), db, &output, config)
+	require.ErrorContains(t, err, "table in use")
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
+
+func TestNewHistoryEntry(t *testing.T) {
+	testCases := []struct {
+		name        string
+		phase       string
+		skipped     bool
+		err         error
+		wantOutcome string
+		wantErr     string
+	}{
+		{name: "success", wantOutcome: historyOutcomeSuccess},
+		{name: "contract phase", phase: phaseContract, wantOutcome: historyOutcomeSuccess},
+		{name: "skipped", skipped: true, wantOutcome: historyOutcomeSkipped},
+		{
+			name:        "failure",
+			skipped:     true,
+			err:         errors.New("table in use"),
+			wantOutcome: historyOutcomeFailure,
+			wantErr:     "table in use",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			entry := newHistoryEntry(
+				createTestModuleMigration("users", 3), DirectionDown, tc.phase, tc.skipped, start, tc.err)
+
+			require.Equal(t, 3, entry.Version)
+			require.Equal(t, "users", entry.Module)
+			require.Equal(t, DirectionDown, entry.Direction)
+			require.Equal(t, tc.phase, entry.Phase)
+			require.Equal(t, start, entry.StartedAt)
+			require.Equal(t, tc.wantOutcome, entry.Outcome)
+			require.Equal(t, tc.wantErr, entry.Error)
+		})
+	}
+}
+
+func TestRunMigrationHistoryRecordFailure(t *testing.T) {
+	config := DefaultConfig()
+	config.History = true
+
+	db := new(dbMock)
+	tx := new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(0)).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Twice()
+	for _, version := range []int{0, 1} {
+		tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(dbVersionRow(version)).
+			Once()
+	}
+	tx.On("ExecContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(new(dbResultMock), nil)
+	tx.On("Commit").Return(nil).Twice()
+	db.On("ExecContext", mock.Anything, insertHistorySQL(historyTableName(migrationsTableName)),
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything).
+		Return(new(dbResultMock), errors.New("permission denied")).
+		Once()
+
+	// The second migration is recorded, even though the first one wasn't.
+	expectHistoryEntry(db, 2, DirectionUp, "", historyOutcomeSuccess, "")
+
+	var warnings bytes.Buffer
+	historyWarnings = &warnings
+	t.Cleanup(func() { historyWarnings = os.Stderr })
+
+	var output bytes.Buffer
+	nbApplied, err := runCmdUp(context.Background(), createTestMigrations(1, 2), db, &output, 0, config)
+
+	// The migration is committed: failing to record it doesn't fail it.
+	require.NoError(t, err)
+	require.Equal(t, 2, nbApplied)
+	require.Equal(t, "warning: failed to record migration 1 up in the history: permission denied\n",
+		warnings.String())
+	db.AssertExpectations(t)
+}
+
+func TestRunCmdUpAtomicHistory(t *testing.T) {
+	config := DefaultConfig()
+	config.History = true
+
+	db := new(dbMock)
+	tx := new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(0)).
+		Once()
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+	for _, version := range []int{0, 1} {
+		tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+			Return(dbVersionRow(version)).
+			Once()
+	}
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 1).
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+		Return(new(dbResultMock), errors.New("already exists")).
+		Once()
+	tx.On("Rollback").Return(nil).Once()
+	// Version 1 was rolled back along with version 2: only the failure is recorded.
+	expectHistoryEntry(db, 2, DirectionUp, "", historyOutcomeFailure,
+		"failed to apply migration.up version 2: already exists")
+
+	var output bytes.Buffer
+	_, err := runCmdUpAtomic(context.Background(), createTestMigrations(1, 2), db, &output, config)
+	require.ErrorContains(t, err, "already exists")
+	db.AssertExpectations(t)
+	tx.AssertExpectations(t)
+}
+
+func TestRunCmdHistory(t *testing.T) {
+	entriesJSON := `[` +
+		`{"id":1,"version":1,"module":"","direction":"up","phase":"","started_at":"2026-10-18T09:30:00+00:00",` +
+		`"duration_ms":1234,"db_user":"app","os_user":"alice","outcome":"success","error":""},` +
+		`{"id":2,"version":3,"module":"users","direction":"down","phase":"contract",` +
+		`"started_at":"2026-10-18T11:45:10+02:00","duration_ms":20,"db_user":"app","os_user":"bob",` +
+		`"outcome":"failure","error":"table in use"}]`
+	filter := historyFilter{direction: DirectionDown}
+
+	testCases := []struct {
+		name    string
+		history bool
+		asJSON  bool
+		wantOut string
+		wantErr string
+	}{
+		{
+			name:    "text",
+			history: true,
+			wantOut: "STARTED AT (UTC)    MIGRATION DIRECTION       OUTCOME    DURATION DB USER OS USER ERROR\n" +
+				"2026-10-18 09:30:00 1         up              success      1.234s app     alice   \n" +
+				"2026-10-18 09:45:10 users/3   down (contract) failure        20ms app     bob     table in use\n",
+		},
+		{
+			name:    "JSON",
+			history: true,
+			asJSON:  true,
+			wantOut: `[
+  {
+    "id": 1,
+    "version": 1,
+    "direction": "up",
+    "started_at": "2026-10-18T09:30:00Z",
+    "duration_ms": 1234,
+    "db_user": "app",
+    "os_user": "alice",
+    "outcome": "success"
+  },
+  {
+    "id": 2,
+    "version": 3,
+    "module": "users",
+    "direction": "down",
+    "phase": "contract",
+    "started_at": "2026-10-18T11:45:10+02:00",
+    "duration_ms": 20,
+    "db_user": "app",
+    "os_user": "bob",
+    "outcome": "failure",
+    "error": "table in use"
+  }
+]
+`,
+		},
+		{
+			name:    "disabled",
+			wantErr: "the migrations history is disabled (see Config.History)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			if tc.history {
+				db.On("QueryRowContext", mock.Anything,
+					selectHistorySQL(historyTableName(migrationsTableName), []string{"direction = $1"}),
+					"down").
+					Return(appliedIDsRow(entriesJSON)).
+					Once()
+			}
+
+			config := DefaultConfig()
+			config.History = tc.history
+
+			var output bytes.Buffer
+			err := runCmdHistory[*dbRowMock, *dbResultMock](
+				context.Background(), db, &output, filter, tc.asJSON, config)
+			db.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, output.String())
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package gosmig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const lockPollInterval = 500 * time.Millisecond
+
+// The lock is a single row in a dedicated table, which works across pooled
+// connections and with any database supporting INSERT ... ON CONFLICT.
+// The row records its holder, so that a lock left behind by a process which
+// died while holding it can be told apart and released with the unlock
+// command.
+
+func lockTableName(table string) string {
+	return table + "_lock"
+}
+
+func createLockTblSQL(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + lockTableName(table) + ` (
+		id INTEGER PRIMARY KEY,
+		holder VARCHAR(255) NOT NULL DEFAULT '',
+		locked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`
+}
+
+// addLockHolderColumnSQL adds the holder column to lock tables created
+// before it existed.
+func addLockHolderColumnSQL(table string) string {
+	return "ALTER TABLE " + lockTableName(table) +
+		" ADD COLUMN IF NOT EXISTS holder VARCHAR(255) NOT NULL DEFAULT ''"
+}
+
+func acquireLockSQL(table string) string {
+	return "INSERT INTO " + lockTableName(table) + " (id, holder) VALUES (1, $1) ON CONFLICT DO NOTHING"
+}
+
+// releaseLockSQL deletes the lock row if it's held by the given holder, so
+// that a process whose lock was released with the unlock command doesn't
+// release the lock of the next one.
+func releaseLockSQL(table string) string {
+	return "DELETE FROM " + lockTableName(table) + " WHERE id = 1 AND holder = $1"
+}
+
+func forceReleaseLockSQL(table string) string {
+	return "DELETE FROM " + lockTableName(table) + " WHERE id = 1"
+}
+
+// selectLockHolderSQL returns the holder of the lock and since when it holds
+// it (e.g. "ci@runner-7 (pid 42) since 2026-10-18 09:30:00 UTC"), or an
+// empty string if the lock isn't held, in a single row.
+func selectLockHolderSQL(table string) string {
+	return "SELECT COALESCE(MAX(COALESCE(NULLIF(holder, ''), 'unknown') || ' since ' || " +
+		"TO_CHAR(locked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI:SS') || ' UTC'), '') FROM " +
+		lockTableName(table)
+}
+
+// lockHolder identifies the current process as the holder of the lock.
+func lockHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s@%s (pid %d)", currentOSUser(), host, os.Getpid())
+}
+
+// createLockTableIfNotExists creates the lock table, or adds the holder
+// column to an existing one.
+func createLockTableIfNotExists[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	ctxCreate, cancelCreate := context.WithTimeout(ctx, config.Timeout)
+	defer cancelCreate()
+	if _, err := dbOrTX.ExecContext(ctxCreate, createLockTblSQL(config.migrationsTable())); err != nil {
+		return fmt.Errorf("failed to create lock table if not exists: %w", err)
+	}
+	if _, err := dbOrTX.ExecContext(ctxCreate, addLockHolderColumnSQL(config.migrationsTable())); err != nil {
+		return fmt.Errorf("failed to add holder column to lock table: %w", err)
+	}
+
+	return nil
+}
+
+// getLockHolder returns the holder of the lock and since when it holds it,
+// or an empty string if the lock isn't held.
+func getLockHolder[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) (string, error) {
+
+	ctxSelect, cancelSelect := context.WithTimeout(ctx, config.Timeout)
+	defer cancelSelect()
+
+	var holder string
+	row := dbOrTX.QueryRowContext(ctxSelect, selectLockHolderSQL(config.migrationsTable()))
+	if err := row.Scan(&holder); err != nil {
+		return "", fmt.Errorf("failed to get the holder of the migrations lock: %w", err)
+	}
+
+	return holder, nil
+}
+
+// acquireLock waits up to config.LockTimeout for the migrations lock.
+// It returns a function that releases the lock.
+func acquireLock[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) (func() error, error) {
+
+	if err := createLockTableIfNotExists(ctx, dbOrTX, config); err != nil {
+		return nil, err
+	}
+
+	holder := lockHolder()
+	deadline := time.Now().Add(config.LockTimeout)
+	for {
+		acquired, err := tryAcquireLock(ctx, dbOrTX, holder, config)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, lockTimeoutErr(ctx, dbOrTX, config)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to acquire migrations lock: %w", ctx.Err())
+		case <-time.After(min(remaining, lockPollInterval)):
+		}
+	}
+
+	release := func() error {
+		ctxRelease, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), config.Timeout)
+		defer cancelRelease()
+		if _, err := dbOrTX.ExecContext(ctxRelease, releaseLockSQL(config.migrationsTable()), holder); err != nil {
+			return fmt.Errorf("failed to release migrations lock: %w", err)
+		}
+		return nil
+	}
+
+	return release, nil
+}
+
+func tryAcquireLock[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	holder string,
+	config *Config,
+) (bool, error) {
+
+	ctxLock, cancelLock := context.WithTimeout(ctx, config.Timeout)
+	defer cancelLock()
+	result, err := dbOrTX.ExecContext(ctxLock, acquireLockSQL(config.migrationsTable()), holder)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire migrations lock: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire migrations lock: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// lockTimeoutErr returns the error of a lock timeout, telling who holds the
+// lock, if known.
+func lockTimeoutErr[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	err := fmt.Errorf("%w after %s", ErrLockTimeout, config.LockTimeout)
+	holder, holderErr := getLockHolder(ctx, dbOrTX, config)
+	if holderErr != nil || holder == "" {
+		return err
+	}
+	return fmt.Errorf(
+		"%w: held by %s (if that run is gone, release the lock with the %s command)",
+		err, holder, cmdUnlock)
+}
+
+// runCmdUnlock releases the migrations lock, whoever holds it, e.g. after
+// the process holding it died.
+func runCmdUnlock[TDBRow DBRow, TDBResult DBResult, TDB DBOrTX[TDBRow, TDBResult]](
+	ctx context.Context,
+	db TDB,
+	output io.Writer,
+	config *Config,
+) error {
+
+	if err := createLockTableIfNotExists(ctx, db, config); err != nil {
+		return err
+	}
+
+	holder, err := getLockHolder(ctx, db, config)
+	if err != nil {
+		return err
+	}
+	if holder == "" {
+		_, _ = fmt.Fprintln(output, "The migrations lock is not held")
+		return nil
+	}
+
+	ctxRelease, cancelRelease := context.WithTimeout(ctx, config.Timeout)
+	defer cancelRelease()
+	if _, err := db.ExecContext(ctxRelease, forceReleaseLockSQL(config.migrationsTable())); err != nil {
+		return fmt.Errorf("failed to release migrations lock: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(output, "Released the migrations lock held by %s\n", holder)
+	return nil
+}
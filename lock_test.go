@@ -0,0 +1,237 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// expectCreateLockTable mocks the creation of the lock table.
+func expectCreateLockTable(dbOrTX *dbOrTxMock, result *dbResultMock) {
+	dbOrTX.On("ExecContext", mock.Anything, createLockTblSQL(migrationsTableName)).
+		Return(result, nil).
+		Once()
+	dbOrTX.On("ExecContext", mock.Anything, addLockHolderColumnSQL(migrationsTableName)).
+		Return(result, nil).
+		Once()
+}
+
+// lockHolderRow mocks the row with the holder of the lock.
+func lockHolderRow(holder string) *dbRowMock {
+	row := new(dbRowMock)
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*string)) = holder
+		}).
+		Return(nil).
+		Once()
+	return row
+}
+
+func TestAcquireLock(t *testing.T) {
+	testCases := []struct {
+		name        string
+		lockTimeout time.Duration
+		setupMock   func(*dbOrTxMock, *dbResultMock)
+		wantErr     string
+		wantErrIs   error
+	}{
+		{
+			name:        "success - acquired on first try",
+			lockTimeout: time.Second,
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				expectCreateLockTable(dbOrTX, result)
+				dbOrTX.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil).
+					Once()
+				result.On("RowsAffected").Return(int64(1), nil).Once()
+				dbOrTX.On("ExecContext", mock.Anything, releaseLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil).
+					Once()
+			},
+		},
+		{
+			name:        "success - acquired after the lock is released by someone else",
+			lockTimeout: 5 * time.Second,
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				expectCreateLockTable(dbOrTX, result)
+				dbOrTX.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil).
+					Twice()
+				result.On("RowsAffected").Return(int64(0), nil).Once()
+				result.On("RowsAffected").Return(int64(1), nil).Once()
+				dbOrTX.On("ExecContext", mock.Anything, releaseLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil).
+					Once()
+			},
+		},
+		{
+			name:        "error - lock timeout",
+			lockTimeout: time.Millisecond,
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				expectCreateLockTable(dbOrTX, result)
+				dbOrTX.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil)
+				result.On("RowsAffected").Return(int64(0), nil)
+				dbOrTX.On("QueryRowContext", mock.Anything, selectLockHolderSQL(migrationsTableName)).
+					Return(lockHolderRow("ci@runner-7 (pid 42) since 2026-10-18 09:30:00 UTC")).
+					Once()
+			},
+			wantErr: "timed out waiting for the migrations lock after 1ms: " +
+				"held by ci@runner-7 (pid 42) since 2026-10-18 09:30:00 UTC " +
+				"(if that run is gone, release the lock with the unlock command)",
+			wantErrIs: ErrLockTimeout,
+		},
+		{
+			name:        "error - create lock table fails",
+			lockTimeout: time.Second,
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				dbOrTX.On("ExecContext", mock.Anything, createLockTblSQL(migrationsTableName)).
+					Return(result, errors.New("permission denied")).
+					Once()
+			},
+			wantErr: "failed to create lock table if not exists: permission denied",
+		},
+		{
+			name:        "error - insert lock row fails",
+			lockTimeout: time.Second,
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				expectCreateLockTable(dbOrTX, result)
+				dbOrTX.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+					Return(result, errors.New("connection reset")).
+					Once()
+			},
+			wantErr: "failed to acquire migrations lock: connection reset",
+		},
+		{
+			name:        "error - rows affected fails",
+			lockTimeout: time.Second,
+			setupMock: func(dbOrTX *dbOrTxMock, result *dbResultMock) {
+				expectCreateLockTable(dbOrTX, result)
+				dbOrTX.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil).
+					Once()
+				result.On("RowsAffected").Return(int64(0), errors.New("not supported")).Once()
+			},
+			wantErr: "failed to acquire migrations lock: not supported",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbOrTX := new(dbOrTxMock)
+			result := new(dbResultMock)
+			tc.setupMock(dbOrTX, result)
+
+			config := DefaultConfig()
+			config.LockTimeout = tc.lockTimeout
+
+			release, err := acquireLock(context.Background(), dbOrTX, config)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				if tc.wantErrIs != nil {
+					require.ErrorIs(t, err, tc.wantErrIs)
+				}
+				dbOrTX.AssertExpectations(t)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, release())
+			dbOrTX.AssertExpectations(t)
+			result.AssertExpectations(t)
+		})
+	}
+
+	t.Run("error - context canceled while waiting", func(t *testing.T) {
+		dbOrTX := new(dbOrTxMock)
+		result := new(dbResultMock)
+		dbOrTX.On("ExecContext", mock.Anything, mock.Anything).Return(result, nil)
+		dbOrTX.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+			Return(result, nil)
+		result.On("RowsAffected").Return(int64(0), nil)
+
+		config := DefaultConfig()
+		config.LockTimeout = time.Minute
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := acquireLock(ctx, dbOrTX, config)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("error - release fails", func(t *testing.T) {
+		dbOrTX := new(dbOrTxMock)
+		result := new(dbResultMock)
+		expectCreateLockTable(dbOrTX, result)
+		dbOrTX.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+			Return(result, nil)
+		dbOrTX.On("ExecContext", mock.Anything, releaseLockSQL(migrationsTableName), mock.Anything).
+			Return(result, errors.New("connection lost"))
+		result.On("RowsAffected").Return(int64(1), nil)
+
+		release, err := acquireLock(context.Background(), dbOrTX, DefaultConfig())
+		require.NoError(t, err)
+		require.EqualError(t, release(), "failed to release migrations lock: connection lost")
+	})
+}
+
+func TestRunCmdUnlock(t *testing.T) {
+	testCases := []struct {
+		name       string
+		holder     string
+		releaseErr error
+		wantOut    string
+		wantErr    string
+	}{
+		{
+			name:    "held",
+			holder:  "ci@runner-7 (pid 42) since 2026-10-18 09:30:00 UTC",
+			wantOut: "Released the migrations lock held by ci@runner-7 (pid 42) since 2026-10-18 09:30:00 UTC\n",
+		},
+		{
+			name:    "not held",
+			wantOut: "The migrations lock is not held\n",
+		},
+		{
+			name:       "release fails",
+			holder:     "ci@runner-7 (pid 42) since 2026-10-18 09:30:00 UTC",
+			releaseErr: errors.New("permission denied"),
+			wantErr:    "failed to release migrations lock: permission denied",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbOrTX := new(dbOrTxMock)
+			result := new(dbResultMock)
+			expectCreateLockTable(dbOrTX, result)
+			dbOrTX.On("QueryRowContext", mock.Anything, selectLockHolderSQL(migrationsTableName)).
+				Return(lockHolderRow(tc.holder)).
+				Once()
+			if tc.holder != "" {
+				dbOrTX.On("ExecContext", mock.Anything, forceReleaseLockSQL(migrationsTableName)).
+					Return(result, tc.releaseErr).
+					Once()
+			}
+
+			var output strings.Builder
+			err := runCmdUnlock[*dbRowMock, *dbResultMock](context.Background(), dbOrTX, &output, DefaultConfig())
+			dbOrTX.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, output.String())
+		})
+	}
+}
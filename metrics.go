@@ -0,0 +1,132 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"time"
+)
+
+// Metrics receives the metrics of the commands run against the database
+// (e.g. by a service applying its migrations at startup), to export them to
+// a monitoring system (see the promadapter module for Prometheus). They
+// are labeled with the migrations table, which tells apart the migration
+// sets and the schemas of the tenants. The methods must be safe for
+// concurrent use, since RunTargets runs the targets concurrently.
+type Metrics interface {
+	// SetVersion records the database version after a command (with modules
+	// or dependencies, the number of applied migrations).
+	SetVersion(table string, version int)
+
+	// SetPending records the number of pending migrations after a command.
+	SetPending(table string, nbPending int)
+
+	// ObserveRun records the duration of a command, and its error, if any.
+	ObserveRun(table, command string, duration time.Duration, err error)
+
+	// ObserveFailure records the failure of a migration.
+	ObserveFailure(table string, err *MigrationError)
+
+	// ObserveLockWait records how long a command waited for the migrations
+	// lock (see Config.LockTimeout), even if it timed out.
+	ObserveLockWait(table string, wait time.Duration)
+}
+
+// stateCommands are the commands after which the database version and the
+// number of pending migrations are recorded.
+var stateCommands = []string{
+	cmdUp,
+	cmdUpOne,
+	cmdDown,
+	cmdGoto,
+	cmdStatus,
+	cmdVersion,
+}
+
+// observeCmd records the metrics of the command started at start, if
+// Config.Metrics is set: its duration and its failure, if any, along with the
+// database version and the number of pending migrations after it.
+func observeCmd[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	command string,
+	start time.Time,
+	err error,
+	config *Config,
+) {
+
+	if config.Metrics == nil {
+		return
+	}
+
+	table := config.migrationsTable()
+	config.Metrics.ObserveRun(table, command, time.Since(start), err)
+
+	var migErr *MigrationError
+	if errors.As(err, &migErr) {
+		config.Metrics.ObserveFailure(table, migErr)
+	}
+
+	if !slices.Contains(stateCommands, command) {
+		return
+	}
+
+	version, nbPending, err := migrationsState(ctx, migrations, db, config)
+	if err != nil {
+		// The metrics are best effort: they don't fail the command.
+		return
+	}
+	config.Metrics.SetVersion(table, version)
+	config.Metrics.SetPending(table, nbPending)
+}
+
+// observeLockWait records how long the command waited for the migrations
+// lock, since start, if Config.Metrics is set.
+func observeLockWait(start time.Time, config *Config) {
+	if config.Metrics != nil {
+		config.Metrics.ObserveLockWait(config.migrationsTable(), time.Since(start))
+	}
+}
+
+// migrationsState returns the database version (with modules or
+// dependencies, the number of applied migrations) and the number of pending
+// migrations.
+func migrationsState[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	config *Config,
+) (version, nbPending int, err error) {
+
+	if usesGraph(migrations) {
+		applied, err := readAppliedIDs(ctx, db, config)
+		if err != nil {
+			return 0, 0, err
+		}
+		nbPending, err = writeGraphStatus(ctx, migrations, db, io.Discard, config)
+		return len(applied), nbPending, err
+	}
+
+	// The read-only commands don't create the tracking tables.
+	applied, _, err := readAppliedVersions(ctx, db, false, config)
+	if err != nil {
+		return 0, 0, err
+	}
+	version = maxAppliedVersion(applied)
+	nbPending, err = writeMigrationsStatus(ctx, migrations, db, io.Discard, config)
+	return version, nbPending, err
+}
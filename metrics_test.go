@@ -0,0 +1,148 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type metricsMock struct {
+	mock.Mock
+}
+
+func (m *metricsMock) SetVersion(table string, version int) {
+	m.Called(table, version)
+}
+
+func (m *metricsMock) SetPending(table string, nbPending int) {
+	m.Called(table, nbPending)
+}
+
+func (m *metricsMock) ObserveRun(table, command string, duration time.Duration, err error) {
+	m.Called(table, command, duration, err)
+}
+
+func (m *metricsMock) ObserveFailure(table string, err *MigrationError) {
+	m.Called(table, err)
+}
+
+func (m *metricsMock) ObserveLockWait(table string, wait time.Duration) {
+	m.Called(table, wait)
+}
+
+func TestRunDBCmdMetrics(t *testing.T) {
+	dropErr := errors.New("table in use")
+
+	testCases := []struct {
+		name        string
+		command     string
+		lockTimeout time.Duration
+		setupMocks  func(*dbMock, *txMock, *metricsMock)
+		wantErr     string
+	}{
+		{
+			name:    "status",
+			command: cmdStatus,
+			setupMocks: func(db *dbMock, tx *txMock, metrics *metricsMock) {
+				for range 3 { // status, then version and pending migrations
+					expectMigrationsColumns(db, migrationsTableName)
+					db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+						Return(appliedVersionsRow(1)).
+						Once()
+				}
+				metrics.On("ObserveRun", migrationsTableName, cmdStatus, mock.Anything, nil).Once()
+				metrics.On("SetVersion", migrationsTableName, 1).Once()
+				metrics.On("SetPending", migrationsTableName, 1).Once()
+			},
+		},
+		{
+			name:        "failing down",
+			command:     cmdDown,
+			lockTimeout: time.Second,
+			setupMocks: func(db *dbMock, tx *txMock, metrics *metricsMock) {
+				db.On("ExecContext", mock.Anything, createMigsTblSQL(migrationsTableName)).
+					Return(new(dbResultMock), nil).
+					Once()
+				result := new(dbResultMock)
+				db.On("ExecContext", mock.Anything, createLockTblSQL(migrationsTableName)).
+					Return(result, nil).
+					Once()
+				db.On("ExecContext", mock.Anything, addLockHolderColumnSQL(migrationsTableName)).
+					Return(result, nil).
+					Once()
+				db.On("ExecContext", mock.Anything, acquireLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil).
+					Once()
+				result.On("RowsAffected").Return(int64(1), nil).Once()
+				db.On("ExecContext", mock.Anything, releaseLockSQL(migrationsTableName), mock.Anything).
+					Return(result, nil).
+					Once()
+
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+				for range 2 { // version, then pending migrations
+					expectMigrationsColumns(db, migrationsTableName)
+					db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+						Return(appliedVersionsRow(1, 2)).
+						Once()
+				}
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+				tx.On("ExecContext", mock.Anything, "DROP TABLE test").
+					Return(new(dbResultMock), dropErr).
+					Once()
+				tx.On("Rollback").Return(nil).Once()
+
+				metrics.On("ObserveLockWait", migrationsTableName, mock.Anything).Once()
+				metrics.On("ObserveRun", migrationsTableName, cmdDown, mock.Anything, mock.MatchedBy(
+					func(err error) bool { return errors.Is(err, dropErr) })).
+					Once()
+				metrics.On("ObserveFailure", migrationsTableName, mock.MatchedBy(
+					func(err *MigrationError) bool {
+						return err.Version == 2 && err.Direction == DirectionDown
+					})).
+					Once()
+				metrics.On("SetVersion", migrationsTableName, 2).Once()
+				metrics.On("SetPending", migrationsTableName, 0).Once()
+			},
+			wantErr: "migration version 2 down failed (TX, func phase): failed to execute in " +
+				"transaction: failed to apply migration.down version 2: table in use",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			metrics := new(metricsMock)
+			tc.setupMocks(db, tx, metrics)
+
+			config := DefaultConfig()
+			config.LockTimeout = tc.lockTimeout
+			config.Metrics = metrics
+
+			var out bytes.Buffer
+			_, _, err := runDBCmd(context.Background(), createTestMigrations(1, 2),
+				options[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock]{},
+				db, "postgres://localhost/db", cliArgs{command: tc.command}, &out, config)
+
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+			metrics.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -1,10 +1,13 @@
 package gosmig
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
+	"maps"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -14,48 +17,169 @@ type (
 		Down func(ctx context.Context, tx TDBOrTX) error
 	}
 
+	// Batched is a data migration (e.g. a large backfill) applied in batches,
+	// each in its own transaction, so that a failed run is resumed where it
+	// stopped instead of from the start.
+	Batched[TDBRow DBRow, TDBResult DBResult, TTX TX[TDBRow, TDBResult]] struct {
+		// Batch processes the batch starting at the cursor ("" for the first
+		// batch) and returns the cursor of the next batch, and whether the
+		// migration is complete. The cursor is recorded in the same transaction,
+		// and the version only once the migration is complete.
+		Batch func(ctx context.Context, tx TTX, cursor string) (next string, done bool, err error)
+		// Down rolls back the whole migration, in a transaction.
+		Down func(ctx context.Context, tx TTX) error
+	}
+
 	Migration[TDBRow DBRow, TDBResult DBResult, TTX TX[TDBRow, TDBResult], TTXO TXOptions, TDB DB[TDBRow, TDBResult, TTX, TTXO]] struct {
 		Version    int
 		UpDown     *UpDown[TDBRow, TDBResult, TTX]
 		UpDownNoTX *UpDown[TDBRow, TDBResult, TDB]
+		Batched    *Batched[TDBRow, TDBResult, TTX]
+
+		// Contract is the contract phase of a migration split for zero-downtime
+		// deploys, whose UpDown, UpDownNoTX or Batched is then the expand phase.
+		// The expand phase (e.g. adding a column) is applied before the new code
+		// ships, and the contract phase (e.g. dropping the old column), which
+		// runs in a transaction, once the old code is gone (with up --phase contract).
+		Contract *UpDown[TDBRow, TDBResult, TTX]
+
+		// Checksum of the migration content. It is set by LoadSQLMigrations
+		// (hex SHA-256 of the up and down files) and is optional otherwise.
+		Checksum string
+
+		// Baseline marks a migration which replaces (squashes) all the migrations
+		// up to its version. It is set by LoadSQLMigrations for up files starting
+		// with "-- gosmig:baseline". A baseline is only applied to empty databases,
+		// databases past its version consider it applied, and it can't be rolled back.
+		Baseline bool
+
+		// Irreversible marks a migration which can't be rolled back (e.g. one
+		// dropping data), so it has no Down function. The down command refuses
+		// to roll it back. It is set by LoadSQLMigrations for up files starting
+		// with "-- gosmig:irreversible".
+		Irreversible bool
+
+		// Module groups the migration with the other ones of a module (e.g. of a
+		// monorepo), which have their own sequence of versions. The migrations
+		// are then identified by "<module>/<version>" (see ID), ordered
+		// topologically by DependsOn, and tracked by ID instead of by version.
+		Module string
+
+		// DependsOn lists the IDs of the migrations (e.g. "billing/3") which must
+		// be applied before this one, besides the previous version of its module.
+		DependsOn []string
+
+		// Tags restrict the migration to the environments whose tag selector
+		// (Config.Tags or --tags) contains at least one of them. Untagged
+		// migrations always run, and so do all migrations when there's no
+		// selector. Migrations which are not selected are skipped: their version
+		// is recorded, along with the selector, but Up isn't run.
+		//
+		// A skipped migration counts as applied for good: later runs, even with
+		// a selector which includes its tags, don't apply it (nor its Contract),
+		// since the database version is past it. To apply it, roll the database
+		// back below its version and run up again with the new selector.
+		Tags []string
 	}
 
 	MigrationSQL  = Migration[*sql.Row, sql.Result, *sql.Tx, *sql.TxOptions, *sql.DB]
 	UpDownSQL     = UpDown[*sql.Row, sql.Result, *sql.Tx]
 	UpDownNoTXSQL = UpDown[*sql.Row, sql.Result, *sql.DB]
+	BatchedSQL    = Batched[*sql.Row, sql.Result, *sql.Tx]
 )
 
+// ID identifies the migration: "<module>/<version>", or "<version>" if it
+// has no module.
+func (m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) ID() string {
+	if m.Module == "" {
+		return strconv.Itoa(m.Version)
+	}
+	return m.Module + "/" + strconv.Itoa(m.Version)
+}
+
 func (m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) validate() error {
 	if m.Version <= 0 {
 		return fmt.Errorf("migration version must be > 0")
 	}
 
-	if m.UpDown == nil && m.UpDownNoTX == nil {
+	var nbKinds int
+	for _, defined := range []bool{m.UpDown != nil, m.UpDownNoTX != nil, m.Batched != nil} {
+		if defined {
+			nbKinds++
+		}
+	}
+
+	if nbKinds == 0 {
 		return fmt.Errorf(
-			"migration %d must have UpDown xor UpDownNoTX fields defined",
+			"migration %d must have one of UpDown, UpDownNoTX or Batched fields defined",
 			m.Version)
 	}
 
-	if m.UpDown != nil && m.UpDownNoTX != nil {
+	if nbKinds > 1 {
 		return fmt.Errorf(
-			"migration %d must have only one of UpDown or UpDownNoTX fields defined",
+			"migration %d must have only one of UpDown, UpDownNoTX or Batched fields defined",
 			m.Version)
 	}
 
-	if m.UpDown != nil {
-		if m.UpDown.Up == nil || m.UpDown.Down == nil {
-			return fmt.Errorf(
-				"migration %d UpDown must have both Up and Down functions defined",
-				m.Version)
-		}
+	if m.Batched != nil && m.Batched.Batch == nil {
+		return fmt.Errorf("batched migration %d must have a Batch function defined", m.Version)
 	}
 
-	if m.UpDownNoTX != nil {
-		if m.UpDownNoTX.Up == nil || m.UpDownNoTX.Down == nil {
+	if m.Irreversible {
+		if (m.UpDown != nil && m.UpDown.Up == nil) ||
+			(m.UpDownNoTX != nil && m.UpDownNoTX.Up == nil) {
+			return fmt.Errorf(
+				"irreversible migration %d must have an Up function defined", m.Version)
+		}
+		if m.Contract != nil && m.Contract.Up == nil {
 			return fmt.Errorf(
-				"migration %d UpDownNoTX must have both Up and Down functions defined",
-				m.Version)
+				"irreversible migration %d Contract must have an Up function defined", m.Version)
 		}
+		if (m.UpDown != nil && m.UpDown.Down != nil) ||
+			(m.UpDownNoTX != nil && m.UpDownNoTX.Down != nil) ||
+			(m.Batched != nil && m.Batched.Down != nil) ||
+			(m.Contract != nil && m.Contract.Down != nil) {
+			return fmt.Errorf(
+				"irreversible migration %d must not have a Down function defined", m.Version)
+		}
+	} else {
+		if m.Batched != nil && m.Batched.Down == nil {
+			return fmt.Errorf(
+				"batched migration %d must have a Down function defined", m.Version)
+		}
+
+		if m.UpDown != nil {
+			if m.UpDown.Up == nil || m.UpDown.Down == nil {
+				return fmt.Errorf(
+					"migration %d UpDown must have both Up and Down functions defined",
+					m.Version)
+			}
+		}
+
+		if m.UpDownNoTX != nil {
+			if m.UpDownNoTX.Up == nil || m.UpDownNoTX.Down == nil {
+				return fmt.Errorf(
+					"migration %d UpDownNoTX must have both Up and Down functions defined",
+					m.Version)
+			}
+		}
+
+		if m.Contract != nil {
+			if m.Contract.Up == nil || m.Contract.Down == nil {
+				return fmt.Errorf(
+					"migration %d Contract must have both Up and Down functions defined",
+					m.Version)
+			}
+		}
+	}
+
+	if err := validateTags(m.Tags); err != nil {
+		return fmt.Errorf("migration %d %w", m.Version, err)
+	}
+
+	if m.Module != "" && !nameRegexp.MatchString(m.Module) {
+		return fmt.Errorf(
+			"migration %d module %q must only contain letters, digits, _ and -", m.Version, m.Module)
 	}
 
 	return nil
@@ -69,31 +193,96 @@ func validateMigrations[
 	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
 
 	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	versioning VersioningScheme,
 ) error {
 
+	migValidationErrs := migrationsValidationErrs(migrations)
+	migValidationErrs = append(migValidationErrs, versionsValidationErrs(migrations, versioning)...)
+	if len(migValidationErrs) > 0 {
+		return fmt.Errorf(
+			"%w: %s", ErrInvalidMigrations, strings.Join(migValidationErrs, "; "))
+	}
+
+	return nil
+}
+
+func migrationsValidationErrs[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) []string {
+
+	// Migrations with modules or dependencies have to be unique by ID rather than by version.
+	graph := usesGraph(migrations)
 	migVersionCounters := make(map[int]int)
+	migIDCounters := make(map[string]int)
 
 	var migValidationErrs []string
 	for _, mig := range migrations {
-		migVersionCounters[mig.Version]++
+		if graph {
+			migIDCounters[mig.ID()]++
+		} else {
+			migVersionCounters[mig.Version]++
+		}
 		if err := mig.validate(); err != nil {
 			migValidationErrs = append(migValidationErrs, err.Error())
 		}
 	}
 
-	for version, count := range migVersionCounters {
-		if count > 1 {
+	for _, version := range slices.Sorted(maps.Keys(migVersionCounters)) {
+		if count := migVersionCounters[version]; count > 1 {
 			migValidationErrs = append(migValidationErrs,
 				fmt.Sprintf("migration version %d is defined %d times", version, count))
 		}
 	}
 
-	if len(migValidationErrs) > 0 {
-		return fmt.Errorf(
-			"invalid migration(s): %s", strings.Join(migValidationErrs, "; "))
+	for _, id := range slices.Sorted(maps.Keys(migIDCounters)) {
+		if count := migIDCounters[id]; count > 1 {
+			migValidationErrs = append(migValidationErrs,
+				fmt.Sprintf("migration %s is defined %d times", id, count))
+		}
 	}
 
-	return nil
+	if graph {
+		migValidationErrs = append(migValidationErrs, graphValidationErrs(migrations)...)
+	}
+
+	if baselineVersion := latestBaselineVersion(migrations); baselineVersion > 0 {
+		for _, mig := range migrations {
+			if mig.Version > 0 && mig.Version < baselineVersion {
+				migValidationErrs = append(migValidationErrs, fmt.Sprintf(
+					"migration version %d is squashed into baseline %d and must be removed",
+					mig.Version, baselineVersion))
+			}
+		}
+	}
+
+	return migValidationErrs
+}
+
+// latestBaselineVersion returns the version of the latest baseline migration,
+// or 0 if there is none.
+func latestBaselineVersion[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) int {
+
+	var baselineVersion int
+	for _, mig := range migrations {
+		if mig.Baseline {
+			baselineVersion = max(baselineVersion, mig.Version)
+		}
+	}
+	return baselineVersion
 }
 
 func sortMigrationsDesc[
@@ -105,7 +294,7 @@ func sortMigrationsDesc[
 	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
 ) {
 	slices.SortFunc(migrations, func(a, b Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) int {
-		return b.Version - a.Version
+		return cmp.Compare(b.Version, a.Version)
 	})
 }
 
@@ -118,6 +307,6 @@ func sortMigrationsAsc[
 	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
 ) {
 	slices.SortFunc(migrations, func(a, b Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) int {
-		return a.Version - b.Version
+		return cmp.Compare(a.Version, b.Version)
 	})
 }
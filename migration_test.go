@@ -14,6 +14,9 @@ func TestMigrationValidate(t *testing.T) {
 	validDownFunc := func(ctx context.Context, tx *sql.Tx) error { return nil }
 	validUpNoTXFunc := func(ctx context.Context, db *sql.DB) error { return nil }
 	validDownNoTXFunc := func(ctx context.Context, db *sql.DB) error { return nil }
+	validBatchFunc := func(ctx context.Context, tx *sql.Tx, cursor string) (string, bool, error) {
+		return "", true, nil
+	}
 
 	testCases := []struct {
 		name      string
@@ -67,7 +70,7 @@ func TestMigrationValidate(t *testing.T) {
 			migration: MigrationSQL{
 				Version: 1,
 			},
-			wantErr: "must have UpDown xor UpDownNoTX fields defined",
+			wantErr: "must have one of UpDown, UpDownNoTX or Batched fields defined",
 		},
 		{
 			name: "both UpDown and UpDownNoTX defined",
@@ -82,7 +85,7 @@ func TestMigrationValidate(t *testing.T) {
 					Down: validDownNoTXFunc,
 				},
 			},
-			wantErr: "must have only one of UpDown or UpDownNoTX fields defined",
+			wantErr: "must have only one of UpDown, UpDownNoTX or Batched fields defined",
 		},
 		{
 			name: "UpDown missing Up function",
@@ -150,6 +153,130 @@ func TestMigrationValidate(t *testing.T) {
 			},
 			wantErr: "UpDownNoTX must have both Up and Down functions defined",
 		},
+		{
+			name: "valid irreversible migration",
+			migration: MigrationSQL{
+				Version:      1,
+				UpDown:       &UpDownSQL{Up: validUpFunc},
+				Irreversible: true,
+			},
+		},
+		{
+			name: "irreversible migration missing Up function",
+			migration: MigrationSQL{
+				Version:      1,
+				UpDownNoTX:   &UpDownNoTXSQL{},
+				Irreversible: true,
+			},
+			wantErr: "irreversible migration 1 must have an Up function defined",
+		},
+		{
+			name: "irreversible migration with a Down function",
+			migration: MigrationSQL{
+				Version:      1,
+				UpDownNoTX:   &UpDownNoTXSQL{Up: validUpNoTXFunc, Down: validDownNoTXFunc},
+				Irreversible: true,
+			},
+			wantErr: "irreversible migration 1 must not have a Down function defined",
+		},
+		{
+			name: "valid batched migration",
+			migration: MigrationSQL{
+				Version: 1,
+				Batched: &BatchedSQL{Batch: validBatchFunc, Down: validDownFunc},
+			},
+		},
+		{
+			name: "valid irreversible batched migration",
+			migration: MigrationSQL{
+				Version:      1,
+				Batched:      &BatchedSQL{Batch: validBatchFunc},
+				Irreversible: true,
+			},
+		},
+		{
+			name: "both UpDown and Batched defined",
+			migration: MigrationSQL{
+				Version: 1,
+				UpDown:  &UpDownSQL{Up: validUpFunc, Down: validDownFunc},
+				Batched: &BatchedSQL{Batch: validBatchFunc, Down: validDownFunc},
+			},
+			wantErr: "must have only one of UpDown, UpDownNoTX or Batched fields defined",
+		},
+		{
+			name: "batched migration missing Batch function",
+			migration: MigrationSQL{
+				Version: 1,
+				Batched: &BatchedSQL{Down: validDownFunc},
+			},
+			wantErr: "batched migration 1 must have a Batch function defined",
+		},
+		{
+			name: "batched migration missing Down function",
+			migration: MigrationSQL{
+				Version: 1,
+				Batched: &BatchedSQL{Batch: validBatchFunc},
+			},
+			wantErr: "batched migration 1 must have a Down function defined",
+		},
+		{
+			name: "irreversible batched migration with a Down function",
+			migration: MigrationSQL{
+				Version:      1,
+				Batched:      &BatchedSQL{Batch: validBatchFunc, Down: validDownFunc},
+				Irreversible: true,
+			},
+			wantErr: "irreversible migration 1 must not have a Down function defined",
+		},
+		{
+			name: "valid expand/contract migration",
+			migration: MigrationSQL{
+				Version:  1,
+				UpDown:   &UpDownSQL{Up: validUpFunc, Down: validDownFunc},
+				Contract: &UpDownSQL{Up: validUpFunc, Down: validDownFunc},
+			},
+		},
+		{
+			name: "contract phase missing Down function",
+			migration: MigrationSQL{
+				Version:  1,
+				UpDown:   &UpDownSQL{Up: validUpFunc, Down: validDownFunc},
+				Contract: &UpDownSQL{Up: validUpFunc},
+			},
+			wantErr: "migration 1 Contract must have both Up and Down functions defined",
+		},
+		{
+			name: "irreversible contract phase missing Up function",
+			migration: MigrationSQL{
+				Version:      1,
+				UpDown:       &UpDownSQL{Up: validUpFunc},
+				Contract:     &UpDownSQL{},
+				Irreversible: true,
+			},
+			wantErr: "irreversible migration 1 Contract must have an Up function defined",
+		},
+		{
+			name: "irreversible contract phase with a Down function",
+			migration: MigrationSQL{
+				Version:      1,
+				UpDown:       &UpDownSQL{Up: validUpFunc},
+				Contract:     &UpDownSQL{Up: validUpFunc, Down: validDownFunc},
+				Irreversible: true,
+			},
+			wantErr: "irreversible migration 1 must not have a Down function defined",
+		},
+		{
+			name: "invalid tag",
+			migration: MigrationSQL{
+				Version: 1,
+				UpDown: &UpDownSQL{
+					Up:   validUpFunc,
+					Down: validDownFunc,
+				},
+				Tags: []string{"prod", "eu west"},
+			},
+			wantErr: `migration 1 tag "eu west" must only contain letters, digits, _ and -`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -323,7 +450,7 @@ func TestValidateMigrations(t *testing.T) {
 				"invalid migration(s): ",
 				"migration version must be > 0",
 				"migration 1 UpDown must have both Up and Down functions defined",
-				"migration 2 must have UpDown xor UpDownNoTX fields defined",
+				"migration 2 must have one of UpDown, UpDownNoTX or Batched fields defined",
 			},
 		},
 		{
@@ -357,11 +484,26 @@ func TestValidateMigrations(t *testing.T) {
 				"migration 2 UpDown must have both Up and Down functions defined",
 			},
 		},
+		{
+			name: "migrations older than the latest baseline",
+			migrations: []MigrationSQL{
+				{Version: 1, UpDown: &UpDownSQL{Up: validUpFunc, Down: validDownFunc}},
+				{Version: 2, UpDown: &UpDownSQL{Up: validUpFunc, Down: validDownFunc}, Baseline: true},
+				{Version: 3, UpDown: &UpDownSQL{Up: validUpFunc, Down: validDownFunc}},
+				{Version: 4, UpDown: &UpDownSQL{Up: validUpFunc, Down: validDownFunc}, Baseline: true},
+				{Version: 5, UpDown: &UpDownSQL{Up: validUpFunc, Down: validDownFunc}},
+			},
+			wantErrs: []string{
+				"migration version 1 is squashed into baseline 4 and must be removed",
+				"migration version 2 is squashed into baseline 4 and must be removed",
+				"migration version 3 is squashed into baseline 4 and must be removed",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			err := validateMigrations(tc.migrations)
+			err := validateMigrations(tc.migrations, VersioningSequential)
 			if len(tc.wantErrs) > 0 {
 				for _, wantErr := range tc.wantErrs {
 					require.ErrorContains(t, err, wantErr)
@@ -0,0 +1,72 @@
+package gosmig
+
+type (
+	// Option sets an optional feature of a gosmig instance (see New).
+	Option[TDBRow DBRow, TDBResult DBResult, TTX TX[TDBRow, TDBResult], TTXO TXOptions, TDB DB[TDBRow, TDBResult, TTX, TTXO]] func(*options[TDBRow, TDBResult, TTX, TTXO, TDB])
+
+	options[TDBRow DBRow, TDBResult DBResult, TTX TX[TDBRow, TDBResult], TTXO TXOptions, TDB DB[TDBRow, TDBResult, TTX, TTXO]] struct {
+		repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB]
+		seedSets    []SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]
+	}
+)
+
+// newOptions applies the given options and validates the result.
+func newOptions[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	opts ...Option[TDBRow, TDBResult, TTX, TTXO, TDB],
+) (options[TDBRow, TDBResult, TTX, TTXO, TDB], error) {
+
+	var optional options[TDBRow, TDBResult, TTX, TTXO, TDB]
+	for _, opt := range opts {
+		opt(&optional)
+	}
+
+	if err := validateRepeatables(optional.repeatables); err != nil {
+		return optional, err
+	}
+
+	if err := validateSeedSets(optional.seedSets); err != nil {
+		return optional, err
+	}
+
+	return optional, nil
+}
+
+// WithRepeatables adds repeatable migrations, run by the up command after the
+// versioned migrations, and listed by the status command in their own section.
+func WithRepeatables[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB],
+) Option[TDBRow, TDBResult, TTX, TTXO, TDB] {
+
+	return func(opts *options[TDBRow, TDBResult, TTX, TTXO, TDB]) {
+		opts.repeatables = append(opts.repeatables, repeatables...)
+	}
+}
+
+// WithSeedSets adds seed sets, applied by name with the seed command and
+// listed by the seed-status command.
+func WithSeedSets[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	seedSets []SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB],
+) Option[TDBRow, TDBResult, TTX, TTXO, TDB] {
+
+	return func(opts *options[TDBRow, TDBResult, TTX, TTXO, TDB]) {
+		opts.seedSets = append(opts.seedSets, seedSets...)
+	}
+}
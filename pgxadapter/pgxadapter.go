@@ -0,0 +1,160 @@
+// Package pgxadapter lets gosmig run migrations with pgx natively, without
+// going through database/sql (pgx/v5/stdlib), so that migrations can use
+// pgx features like CopyFrom, batches and native types.
+//
+// Transactional migrations receive a *Tx, which embeds the pgx.Tx, and
+// non-transactional ones receive a *DB, which embeds the *pgxpool.Pool:
+//
+//	migrations := []pgxadapter.MigrationPGX{
+//		{
+//			Version: 1,
+//			UpDown: &pgxadapter.UpDownPGX{
+//				Up: func(ctx context.Context, tx *pgxadapter.Tx) error {
+//					_, err := tx.Exec(ctx, `CREATE TABLE users (id INT, name TEXT)`)
+//					return err
+//				},
+//				Down: func(ctx context.Context, tx *pgxadapter.Tx) error {
+//					_, err := tx.Exec(ctx, `DROP TABLE users`)
+//					return err
+//				},
+//			},
+//		},
+//	}
+//
+//	goSMig, err := gosmig.New(migrations, pgxadapter.Connect, nil)
+//
+// The tracking tables and the commands behave as with database/sql.
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/padurean/gosmig"
+)
+
+type (
+	// Row is a pgx.Row implementing gosmig.DBRow.
+	Row struct {
+		pgx.Row
+	}
+
+	// Result is a pgconn.CommandTag implementing gosmig.DBResult.
+	Result struct {
+		pgconn.CommandTag
+	}
+
+	// Tx is a pgx.Tx implementing gosmig.TX. Commit and Rollback are called
+	// by gosmig, so migrations must not call them.
+	Tx struct {
+		pgx.Tx
+	}
+
+	// DB is a *pgxpool.Pool implementing gosmig.DB.
+	DB struct {
+		*pgxpool.Pool
+	}
+
+	MigrationPGX    = gosmig.Migration[Row, Result, *Tx, pgx.TxOptions, *DB]
+	UpDownPGX       = gosmig.UpDown[Row, Result, *Tx]
+	UpDownNoTXPGX   = gosmig.UpDown[Row, Result, *DB]
+	RepeatablePGX   = gosmig.Repeatable[Row, Result, *Tx, pgx.TxOptions, *DB]
+	SeedSetPGX      = gosmig.SeedSet[Row, Result, *Tx, pgx.TxOptions, *DB]
+	BatchedPGX      = gosmig.Batched[Row, Result, *Tx]
+	MigrationSetPGX = gosmig.MigrationSet[Row, Result, *Tx, pgx.TxOptions, *DB]
+)
+
+// ErrLastInsertIDNotSupported is returned by Result.LastInsertId, as
+// PostgreSQL has no such thing; use INSERT ... RETURNING instead.
+var ErrLastInsertIDNotSupported = errors.New("LastInsertId is not supported by pgx")
+
+// Err returns nil: pgx reports the query errors when the row is scanned.
+func (r Row) Err() error {
+	return nil
+}
+
+func (r Result) LastInsertId() (int64, error) {
+	return 0, ErrLastInsertIDNotSupported
+}
+
+func (r Result) RowsAffected() (int64, error) {
+	return r.CommandTag.RowsAffected(), nil
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, sql string, args ...any) Row {
+	return Row{tx.QueryRow(ctx, sql, args...)}
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, sql string, args ...any) (Result, error) {
+	commandTag, err := tx.Exec(ctx, sql, args...)
+	return Result{commandTag}, err
+}
+
+func (tx *Tx) Commit() error {
+	return tx.Tx.Commit(context.Background())
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.Tx.Rollback(context.Background())
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, sql string, args ...any) Row {
+	return Row{db.QueryRow(ctx, sql, args...)}
+}
+
+func (db *DB) ExecContext(ctx context.Context, sql string, args ...any) (Result, error) {
+	commandTag, err := db.Exec(ctx, sql, args...)
+	return Result{commandTag}, err
+}
+
+func (db *DB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (*Tx, error) {
+	tx, err := db.Pool.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx}, nil
+}
+
+func (db *DB) Close() error {
+	db.Pool.Close()
+	return nil
+}
+
+// Connect creates a pgx connection pool for the given database URL and pings
+// the database. It can be passed as is to gosmig.New.
+func Connect(url string, timeout time.Duration) (*DB, error) {
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a database connection pool: %w", err)
+	}
+
+	ctxPing, cancelPing := context.WithTimeout(context.Background(), timeout)
+	defer cancelPing()
+	if err := pool.Ping(ctxPing); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping the database: %w", err)
+	}
+
+	return &DB{pool}, nil
+}
+
+// LoadSQLMigrations is gosmig.LoadSQLMigrations for pgx.
+func LoadSQLMigrations(fsys fs.FS) ([]MigrationPGX, error) {
+	return gosmig.LoadSQLMigrations[Row, Result, *Tx, pgx.TxOptions, *DB](fsys)
+}
+
+// LoadSQLRepeatables is gosmig.LoadSQLRepeatables for pgx.
+func LoadSQLRepeatables(fsys fs.FS) ([]RepeatablePGX, error) {
+	return gosmig.LoadSQLRepeatables[Row, Result, *Tx, pgx.TxOptions, *DB](fsys)
+}
+
+// LoadSQLSeedSet is gosmig.LoadSQLSeedSet for pgx.
+func LoadSQLSeedSet(fsys fs.FS, name string, minVersion int) (SeedSetPGX, error) {
+	return gosmig.LoadSQLSeedSet[Row, Result, *Tx, pgx.TxOptions, *DB](fsys, name, minVersion)
+}
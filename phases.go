@@ -0,0 +1,209 @@
+package gosmig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// The phases of the migrations split for zero-downtime deploys, which up
+// --phase applies one at a time. Without it, up applies the expand phases
+// only: the contract phases must be asked for, once the old code is gone.
+const (
+	phaseExpand   = "expand"
+	phaseContract = "contract"
+)
+
+var phases = []string{phaseExpand, phaseContract}
+
+// hasContract tells whether any of the migrations has a contract phase, in
+// which case the migrations table records when it was applied.
+func hasContract[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) bool {
+
+	return slices.ContainsFunc(migrations, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return m.Contract != nil
+	})
+}
+
+// runContractPhase applies, each in its own transaction, the contract phase
+// of the applied migrations whose contract phase is pending. It's a no-op if
+// none of the migrations has a contract phase, and fails with ErrDBAhead if the
+// database has migrations unknown to the code. It returns the number of
+// contract phases applied.
+func runContractPhase[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+) (int, error) {
+
+	if !hasContract(migrations) {
+		return 0, nil
+	}
+
+	sortMigrationsAsc(migrations)
+
+	dbVersion, err := getDBVersion(ctx, db, config)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkDBAhead(dbVersion, migrations[len(migrations)-1].Version, config); err != nil {
+		return 0, err
+	}
+
+	var nbContractedMigrations int
+
+	for _, migration := range migrations {
+		if migration.Contract == nil || migration.Version > dbVersion {
+			continue
+		}
+
+		if len(migration.Tags) > 0 {
+			// The expand phase of skipped migrations never ran, nor does their contract phase.
+			tags, err := getMigrationTags(ctx, db, migration.Version, config)
+			if err != nil {
+				return 0, err
+			}
+			if !selectedByTags(migration.Tags, tags) {
+				continue
+			}
+		}
+
+		contracted, err := isMigrationContracted(ctx, db, migration.Version, config)
+		if err != nil {
+			return 0, err
+		}
+		if contracted {
+			continue
+		}
+
+		err = runMigration(ctx, db, migration, DirectionUp, TxModeTX, phaseContract, false, config,
+			func(ctx context.Context) error {
+				return executeInTx(
+					ctx, db, migrateContract(migration.Version, migration.Contract.Up, config), config.Timeout)
+			})
+		if err != nil {
+			return 0, newMigrationError(migration.Version, DirectionUp, TxModeTX, err)
+		}
+
+		_, _ = fmt.Fprintf(
+			output, "[x] Applied contract phase of migration version %d\n", migration.Version)
+
+		nbContractedMigrations++
+	}
+
+	if nbContractedMigrations == 0 {
+		_, _ = fmt.Fprintln(output, "No contract phases to apply")
+		return 0, nil
+	}
+
+	_, _ = fmt.Fprintf(output, "%d contract phase(s) applied\n", nbContractedMigrations)
+
+	return nbContractedMigrations, nil
+}
+
+// migrateContract applies the contract phase of the migration, whose expand
+// phase must be applied.
+func migrateContract[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	version int,
+	up func(ctx context.Context, dbOrTX TDBOrTX) error,
+	config *Config,
+) func(context.Context, TDBOrTX) error {
+
+	return func(ctx context.Context, dbOrTX TDBOrTX) error {
+		dbVersion, err := getDBVersion(ctx, dbOrTX, config)
+		if err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		if version > dbVersion {
+			return withPhase(PhaseBookkeeping, fmt.Errorf(
+				"%w: migration version %d > current DB version %d",
+				ErrDBVersionChangedDown, version, dbVersion))
+		}
+
+		contracted, err := isMigrationContracted(ctx, dbOrTX, version, config)
+		if err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		if contracted {
+			return withPhase(PhaseBookkeeping, fmt.Errorf(
+				"%w: contract phase of migration version %d already applied",
+				ErrDBVersionChangedUp, version))
+		}
+
+		if err := setSearchPath(ctx, dbOrTX, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		migCtx, cancelMig := context.WithTimeout(ctx, config.Timeout)
+		defer cancelMig()
+		if err := up(migCtx, dbOrTX); err != nil {
+			return withPhase(PhaseFunc, fmt.Errorf(
+				"failed to apply migration.contract.up version %d: %w", version, err))
+		}
+
+		if err := setMigrationContracted(ctx, dbOrTX, version, true, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		return nil
+	}
+}
+
+// migrateUncontract rolls back the contract phase of the migration, before
+// its expand phase is rolled back.
+func migrateUncontract[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	version int,
+	down func(ctx context.Context, dbOrTX TDBOrTX) error,
+	config *Config,
+) func(context.Context, TDBOrTX) error {
+
+	return func(ctx context.Context, dbOrTX TDBOrTX) error {
+		contracted, err := isMigrationContracted(ctx, dbOrTX, version, config)
+		if err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		if !contracted {
+			return withPhase(PhaseBookkeeping, fmt.Errorf(
+				"%w: contract phase of migration version %d not applied",
+				ErrDBVersionChangedDown, version))
+		}
+
+		if err := setSearchPath(ctx, dbOrTX, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		migCtx, cancelMig := context.WithTimeout(ctx, config.Timeout)
+		defer cancelMig()
+		if err := down(migCtx, dbOrTX); err != nil {
+			return withPhase(PhaseFunc, fmt.Errorf(
+				"failed to apply migration.contract.down version %d: %w", version, err))
+		}
+
+		if err := setMigrationContracted(ctx, dbOrTX, version, false, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,231 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestContractMigration creates a migration whose contract phase drops
+// the old column, and fails with the given error, if any.
+func createTestContractMigration(version int, contractErr error) migrationMock {
+	migration := createTestMigrations(version)[0]
+	migration.Contract = &UpDown[*dbRowMock, *dbResultMock, *txMock]{
+		Up: func(ctx context.Context, tx *txMock) error {
+			if contractErr != nil {
+				return contractErr
+			}
+			_, err := tx.ExecContext(ctx, "ALTER TABLE test DROP COLUMN old_id")
+			return err
+		},
+		Down: func(ctx context.Context, tx *txMock) error {
+			_, err := tx.ExecContext(ctx, "ALTER TABLE test ADD COLUMN old_id INT")
+			return err
+		},
+	}
+	return migration
+}
+
+// contractedRow mocks the row telling whether the contract phase of a migration was applied.
+func contractedRow(contracted bool) *dbRowMock {
+	if contracted {
+		return dbVersionRow(1)
+	}
+	return dbVersionRow(0)
+}
+
+func TestRunContractPhase(t *testing.T) {
+	testCases := []struct {
+		name        string
+		migrations  []migrationMock
+		dbVersion   int
+		contractErr error
+		setupMock   func(*dbMock, *txMock)
+		wantOut     string
+		wantErr     string
+	}{
+		{
+			name:       "no contract phases",
+			migrations: createTestMigrations(1, 2),
+			setupMock:  func(db *dbMock, tx *txMock) {},
+		},
+		{
+			name: "applies the pending contract phases of the applied migrations",
+			migrations: []migrationMock{
+				createTestContractMigration(1, nil),
+				createTestContractMigration(2, nil),
+				createTestContractMigration(3, nil),
+			},
+			dbVersion: 2,
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+					Return(contractedRow(true)).
+					Once()
+				db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 2).
+					Return(contractedRow(false)).
+					Once()
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+				tx.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 2).
+					Return(contractedRow(false)).
+					Once()
+				tx.On("ExecContext", mock.Anything, "ALTER TABLE test DROP COLUMN old_id").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("ExecContext", mock.Anything, updateMigContractedSQL(migrationsTableName), 2).
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Commit").Return(nil).Once()
+			},
+			wantOut: "[x] Applied contract phase of migration version 2\n" +
+				"1 contract phase(s) applied\n",
+		},
+		{
+			name:       "nothing to contract",
+			migrations: []migrationMock{createTestContractMigration(1, nil)},
+			dbVersion:  1,
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+					Return(contractedRow(true)).
+					Once()
+			},
+			wantOut: "No contract phases to apply\n",
+		},
+		{
+			name:       "failing contract phase",
+			migrations: []migrationMock{createTestContractMigration(1, errors.New("column in use"))},
+			dbVersion:  1,
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+					Return(contractedRow(false)).
+					Once()
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(1)).
+					Once()
+				tx.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+					Return(contractedRow(false)).
+					Once()
+				tx.On("Rollback").Return(nil).Once()
+			},
+			wantErr: "migration version 1 up failed (TX, func phase): failed to execute in " +
+				"transaction: failed to apply migration.contract.up version 1: column in use",
+		},
+		{
+			name:       "database ahead of the code",
+			migrations: []migrationMock{createTestContractMigration(1, nil)},
+			dbVersion:  2,
+			setupMock:  func(db *dbMock, tx *txMock) {},
+			wantErr: "database ahead of the code, unknown migrations applied: database version 2 > version 1; " +
+				"deploy a release which has them, or force the command (--force)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			if hasContract(tc.migrations) {
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(tc.dbVersion)).
+					Once()
+			}
+			tc.setupMock(db, tx)
+
+			var out bytes.Buffer
+			_, err := runContractPhase(context.Background(), tc.migrations, db, &out, DefaultConfig())
+
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+			require.Equal(t, tc.wantOut, out.String())
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRunCmdDownContracted(t *testing.T) {
+	db := new(dbMock)
+	contractTx := new(txMock)
+	expandTx := new(txMock)
+	db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(1)).
+		Once()
+	db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+		Return(contractedRow(true)).
+		Once()
+
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(contractTx, nil).Once()
+	contractTx.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+		Return(contractedRow(true)).
+		Once()
+	contractTx.On("ExecContext", mock.Anything, "ALTER TABLE test ADD COLUMN old_id INT").
+		Return(new(dbResultMock), nil).
+		Once()
+	contractTx.On("ExecContext", mock.Anything, updateMigUncontractedSQL(migrationsTableName), 1).
+		Return(new(dbResultMock), nil).
+		Once()
+	contractTx.On("Commit").Return(nil).Once()
+
+	db.On("BeginTx", mock.Anything, mock.Anything).Return(expandTx, nil).Once()
+	expandTx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+		Return(dbVersionRow(1)).
+		Once()
+	expandTx.On("ExecContext", mock.Anything, "DROP TABLE test").
+		Return(new(dbResultMock), nil).
+		Once()
+	expandTx.On("ExecContext", mock.Anything, deleteMigVersionSQL(migrationsTableName), 1).
+		Return(new(dbResultMock), nil).
+		Once()
+	expandTx.On("Commit").Return(nil).Once()
+
+	var out bytes.Buffer
+	err := runCmdDown(context.Background(),
+		[]migrationMock{createTestContractMigration(1, nil)}, db, &out, DefaultConfig())
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+	contractTx.AssertExpectations(t)
+	expandTx.AssertExpectations(t)
+	require.Equal(t,
+		"[x]-->[~] Rolled back contract phase of migration version 1\n"+
+			"[x]-->[ ] Rolled back migration version 1\n",
+		out.String())
+}
+
+func TestRunCmdStatusWaitingOnContract(t *testing.T) {
+	db := new(dbMock)
+	expectMigrationsColumns(db, migrationsTableName)
+	db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+		Return(appliedVersionsRow(1, 2)).
+		Once()
+	db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 2).
+		Return(contractedRow(false)).
+		Once()
+	db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+		Return(contractedRow(true)).
+		Once()
+
+	var out bytes.Buffer
+	nbPending, err := runCmdStatus(context.Background(),
+		[]migrationMock{createTestContractMigration(1, nil), createTestContractMigration(2, nil)},
+		nil, db, &out, DefaultConfig())
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+
+	require.Equal(t,
+		"VERSION    STATUS      \n"+
+			"2          [x] APPLIED (waiting on contract)\n"+
+			"1          [x] APPLIED \n",
+		out.String())
+	require.Equal(t, 1, nbPending)
+}
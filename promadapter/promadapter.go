@@ -0,0 +1,138 @@
+// Package promadapter exports the metrics of gosmig to Prometheus, e.g. when
+// the migrations are applied at the startup of a long-lived service:
+//
+//	collector := promadapter.NewCollector()
+//	prometheus.MustRegister(collector)
+//
+//	config := gosmig.DefaultConfig()
+//	config.Metrics = collector
+//	goSMig, err := gosmig.New(migrations, connectToDB, config)
+//
+// All the metrics are labeled with the migrations table (e.g. gosmig), which
+// tells apart the migration sets and the schemas of the tenants.
+package promadapter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/padurean/gosmig"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gosmig"
+
+// The results of the runs of the commands.
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Collector is a prometheus.Collector of the metrics of gosmig, which
+// implements gosmig.Metrics.
+type Collector struct {
+	version      *prometheus.GaugeVec
+	pending      *prometheus.GaugeVec
+	lastDuration *prometheus.GaugeVec
+	runs         *prometheus.CounterVec
+	failures     *prometheus.CounterVec
+	lockWait     *prometheus.HistogramVec
+}
+
+var (
+	_ gosmig.Metrics       = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// NewCollector returns a collector, to register (e.g. with
+// prometheus.MustRegister) and to set as gosmig.Config.Metrics.
+func NewCollector() *Collector {
+	return &Collector{
+		version: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "schema_version",
+			Help: "Database version after the last command (with modules or dependencies, " +
+				"the number of applied migrations).",
+		}, []string{"table"}),
+		pending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pending_migrations",
+			Help:      "Number of pending migrations after the last command.",
+		}, []string{"table"}),
+		lastDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_run_duration_seconds",
+			Help:      "Duration of the last run of the command.",
+		}, []string{"table", "command"}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "runs_total",
+			Help:      "Number of runs of the command, by result (success or failure).",
+		}, []string{"table", "command", "result"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "migration_failures_total",
+			Help:      "Number of failures of the migration, by direction (up or down).",
+		}, []string{"table", "migration", "direction"}),
+		lockWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "lock_wait_seconds",
+			Help:      "Time spent waiting for the migrations lock.",
+			Buckets:   []float64{.01, .1, .5, 1, 5, 10, 30, 60, 300},
+		}, []string{"table"}),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.version, c.pending, c.lastDuration, c.runs, c.failures, c.lockWait}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// SetVersion implements gosmig.Metrics.
+func (c *Collector) SetVersion(table string, version int) {
+	c.version.WithLabelValues(table).Set(float64(version))
+}
+
+// SetPending implements gosmig.Metrics.
+func (c *Collector) SetPending(table string, nbPending int) {
+	c.pending.WithLabelValues(table).Set(float64(nbPending))
+}
+
+// ObserveRun implements gosmig.Metrics.
+func (c *Collector) ObserveRun(table, command string, duration time.Duration, err error) {
+	c.lastDuration.WithLabelValues(table, command).Set(duration.Seconds())
+
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	c.runs.WithLabelValues(table, command, result).Inc()
+}
+
+// ObserveFailure implements gosmig.Metrics. The migration label is its ID
+// (e.g. 3, or users/3 with a module).
+func (c *Collector) ObserveFailure(table string, err *gosmig.MigrationError) {
+	migration := strconv.Itoa(err.Version)
+	if err.Module != "" {
+		migration = err.Module + "/" + migration
+	}
+	c.failures.WithLabelValues(table, migration, string(err.Direction)).Inc()
+}
+
+// ObserveLockWait implements gosmig.Metrics.
+func (c *Collector) ObserveLockWait(table string, wait time.Duration) {
+	c.lockWait.WithLabelValues(table).Observe(wait.Seconds())
+}
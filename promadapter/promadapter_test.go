@@ -0,0 +1,50 @@
+package promadapter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/padurean/gosmig"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector()
+
+	collector.SetVersion("gosmig", 3)
+	collector.SetPending("gosmig", 2)
+	collector.ObserveRun("gosmig", "up", 1500*time.Millisecond, nil)
+	collector.ObserveRun("gosmig", "up", 2*time.Second, errors.New("boom"))
+	collector.ObserveFailure("gosmig", &gosmig.MigrationError{Version: 4, Direction: gosmig.DirectionUp})
+	collector.ObserveFailure("gosmig", &gosmig.MigrationError{
+		Version: 2, Module: "users", Direction: gosmig.DirectionDown,
+	})
+	collector.ObserveLockWait("gosmig", 200*time.Millisecond)
+
+	err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP gosmig_last_run_duration_seconds Duration of the last run of the command.
+# TYPE gosmig_last_run_duration_seconds gauge
+gosmig_last_run_duration_seconds{command="up",table="gosmig"} 2
+# HELP gosmig_migration_failures_total Number of failures of the migration, by direction (up or down).
+# TYPE gosmig_migration_failures_total counter
+gosmig_migration_failures_total{direction="down",migration="users/2",table="gosmig"} 1
+gosmig_migration_failures_total{direction="up",migration="4",table="gosmig"} 1
+# HELP gosmig_pending_migrations Number of pending migrations after the last command.
+# TYPE gosmig_pending_migrations gauge
+gosmig_pending_migrations{table="gosmig"} 2
+# HELP gosmig_runs_total Number of runs of the command, by result (success or failure).
+# TYPE gosmig_runs_total counter
+gosmig_runs_total{command="up",result="failure",table="gosmig"} 1
+gosmig_runs_total{command="up",result="success",table="gosmig"} 1
+# HELP gosmig_schema_version Database version after the last command (with modules or dependencies, the number of applied migrations).
+# TYPE gosmig_schema_version gauge
+gosmig_schema_version{table="gosmig"} 3
+`), "gosmig_last_run_duration_seconds", "gosmig_migration_failures_total",
+		"gosmig_pending_migrations", "gosmig_runs_total", "gosmig_schema_version")
+	require.NoError(t, err)
+
+	require.Equal(t, 1, testutil.CollectAndCount(collector, "gosmig_lock_wait_seconds"))
+}
@@ -0,0 +1,230 @@
+package gosmig
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// repeatableFileSuffix is the suffix of the SQL files defining repeatable
+// migrations (e.g. "active_users_view.repeatable.sql").
+const repeatableFileSuffix = ".repeatable.sql"
+
+// nameRegexp is what the names of repeatable migrations and seed sets must match.
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type (
+	// Repeatable is a migration which is (re)applied, after the versioned
+	// migrations, whenever its checksum changes. It suits database objects
+	// which are redefined as a whole, like views, functions and stored
+	// procedures, so Up should be idempotent (e.g. CREATE OR REPLACE VIEW).
+	// Repeatable migrations are never rolled back.
+	Repeatable[TDBRow DBRow, TDBResult DBResult, TTX TX[TDBRow, TDBResult], TTXO TXOptions, TDB DB[TDBRow, TDBResult, TTX, TTXO]] struct {
+		// Name identifies the repeatable migration in the tracking table.
+		Name string
+		// Up (re)defines the database objects, in a transaction.
+		Up func(ctx context.Context, tx TTX) error
+		// Checksum of the repeatable migration content. Up is run again when
+		// it differs from the checksum recorded at the last run. It is set by
+		// LoadSQLRepeatables (hex SHA-256 of the SQL file).
+		Checksum string
+	}
+
+	RepeatableSQL = Repeatable[*sql.Row, sql.Result, *sql.Tx, *sql.TxOptions, *sql.DB]
+
+	// repeatableStatus is the state of a repeatable migration in the database.
+	repeatableStatus string
+)
+
+const (
+	repeatablePending repeatableStatus = "[ ] PENDING"
+	repeatableChanged repeatableStatus = "[~] CHANGED"
+	repeatableApplied repeatableStatus = "[x] APPLIED"
+)
+
+func (r Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB]) validate() error {
+	if !nameRegexp.MatchString(r.Name) {
+		return fmt.Errorf(
+			"repeatable migration name %q must only contain letters, digits, _ and -", r.Name)
+	}
+
+	if r.Up == nil {
+		return fmt.Errorf("repeatable migration %s must have an Up function defined", r.Name)
+	}
+
+	if r.Checksum == "" {
+		return fmt.Errorf("repeatable migration %s must have a Checksum", r.Name)
+	}
+
+	return nil
+}
+
+func validateRepeatables[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB],
+) error {
+
+	nameCounters := make(map[string]int)
+
+	var validationErrs []string
+	for _, repeatable := range repeatables {
+		nameCounters[repeatable.Name]++
+		if err := repeatable.validate(); err != nil {
+			validationErrs = append(validationErrs, err.Error())
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(nameCounters)) {
+		if count := nameCounters[name]; count > 1 {
+			validationErrs = append(validationErrs,
+				fmt.Sprintf("repeatable migration %s is defined %d times", name, count))
+		}
+	}
+
+	if len(validationErrs) > 0 {
+		return fmt.Errorf(
+			"%w: %s", ErrInvalidMigrations, strings.Join(validationErrs, "; "))
+	}
+
+	return nil
+}
+
+func sortRepeatables[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+	repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB],
+) {
+	slices.SortFunc(repeatables, func(a, b Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB]) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// LoadSQLRepeatables loads the repeatable migrations defined as SQL files
+// named <name>.repeatable.sql in the root directory of fsys (e.g. an
+// embed.FS or os.DirFS). Other files are ignored.
+func LoadSQLRepeatables[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	fsys fs.FS,
+) ([]Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB], error) {
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SQL migrations directory: %w", err)
+	}
+
+	var repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB]
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, repeatableFileSuffix) {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SQL repeatable migration file %s: %w", name, err)
+		}
+
+		repeatables = append(repeatables, Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB]{
+			Name:     strings.TrimSuffix(filepath.Base(name), repeatableFileSuffix),
+			Up:       execSQL[TDBRow, TDBResult, TTX](content),
+			Checksum: checksum(content),
+		})
+	}
+
+	return repeatables, nil
+}
+
+// LoadSQLRepeatablesSQL is LoadSQLRepeatables for database/sql.
+func LoadSQLRepeatablesSQL(fsys fs.FS) ([]RepeatableSQL, error) {
+	return LoadSQLRepeatables[*sql.Row, sql.Result, *sql.Tx, *sql.TxOptions, *sql.DB](fsys)
+}
+
+// runRepeatables applies the repeatable migrations which were never applied
+// or whose checksum changed since they were last applied, sorted by name. It
+// returns the number of repeatable migrations applied.
+func runRepeatables[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	repeatables []Repeatable[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+) (int, error) {
+
+	if len(repeatables) == 0 {
+		return 0, nil
+	}
+
+	sortRepeatables(repeatables)
+
+	var nbApplied int
+	for _, repeatable := range repeatables {
+		status, err := getRepeatableStatus(ctx, db, repeatable.Name, repeatable.Checksum, config)
+		if err != nil {
+			return 0, err
+		}
+		if status == repeatableApplied {
+			continue
+		}
+
+		err = executeInTx(ctx, db, func(ctx context.Context, tx TTX) error {
+			if err := setSearchPath(ctx, tx, config); err != nil {
+				return withPhase(PhaseBookkeeping, err)
+			}
+
+			upCtx, cancelUp := context.WithTimeout(ctx, config.Timeout)
+			defer cancelUp()
+			if err := repeatable.Up(upCtx, tx); err != nil {
+				return withPhase(PhaseFunc, fmt.Errorf(
+					"failed to apply repeatable migration %s: %w", repeatable.Name, err))
+			}
+
+			if err := upsertRepeatable(ctx, tx, repeatable.Name, repeatable.Checksum, config); err != nil {
+				return withPhase(PhaseBookkeeping, err)
+			}
+
+			return nil
+		}, config.Timeout)
+		if err != nil {
+			return 0, err
+		}
+
+		_, _ = fmt.Fprintf(output, "[x] Applied repeatable migration %s\n", repeatable.Name)
+		nbApplied++
+	}
+
+	if nbApplied == 0 {
+		_, _ = fmt.Fprintln(output, "No repeatable migrations to apply")
+		return 0, nil
+	}
+
+	_, _ = fmt.Fprintf(output, "%d repeatable migration(s) applied\n", nbApplied)
+
+	return nbApplied, nil
+}
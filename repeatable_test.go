@@ -0,0 +1,295 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestRepeatable(name, checksum string) repeatableMock {
+	return repeatableMock{
+		Name:     name,
+		Up:       func(ctx context.Context, tx *txMock) error { return nil },
+		Checksum: checksum,
+	}
+}
+
+// setupRepeatableChecksumMock mocks the query of the checksum recorded for a
+// repeatable migration ("" if it was never applied).
+func setupRepeatableChecksumMock(db *dbMock, name, dbChecksum string) {
+	row := new(dbRowMock)
+	db.On("QueryRowContext", mock.Anything,
+		selectRepeatableChecksumSQL(repeatableTableName(migrationsTableName)), name).
+		Return(row).
+		Once()
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*string)) = dbChecksum
+		}).
+		Return(nil).
+		Once()
+}
+
+func TestValidateRepeatables(t *testing.T) {
+	testCases := []struct {
+		name        string
+		repeatables []repeatableMock
+		wantErr     string
+	}{
+		{
+			name: "valid",
+			repeatables: []repeatableMock{
+				createTestRepeatable("views", "abc"),
+				createTestRepeatable("functions", "def"),
+			},
+		},
+		{
+			name:        "invalid name",
+			repeatables: []repeatableMock{createTestRepeatable("active users", "abc")},
+			wantErr: `invalid migration(s): repeatable migration name "active users" ` +
+				"must only contain letters, digits, _ and -",
+		},
+		{
+			name:        "missing Up",
+			repeatables: []repeatableMock{{Name: "views", Checksum: "abc"}},
+			wantErr:     "invalid migration(s): repeatable migration views must have an Up function defined",
+		},
+		{
+			name:        "missing checksum",
+			repeatables: []repeatableMock{createTestRepeatable("views", "")},
+			wantErr:     "invalid migration(s): repeatable migration views must have a Checksum",
+		},
+		{
+			name: "duplicate names",
+			repeatables: []repeatableMock{
+				createTestRepeatable("views", "abc"),
+				createTestRepeatable("views", "def"),
+			},
+			wantErr: "invalid migration(s): repeatable migration views is defined 2 times",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRepeatables(tc.repeatables)
+			if tc.wantErr != "" {
+				require.ErrorIs(t, err, ErrInvalidMigrations)
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadSQLRepeatables(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_create_t.up.sql":           {Data: []byte("CREATE TABLE t (c INT);")},
+		"001_create_t.down.sql":         {Data: []byte("DROP TABLE t;")},
+		"t_view.repeatable.sql":         {Data: []byte("CREATE OR REPLACE VIEW v AS SELECT c FROM t;")},
+		"count_t_func.repeatable.sql":   {Data: []byte("CREATE OR REPLACE FUNCTION count_t() ...;")},
+		"subdir/ignored.repeatable.sql": {Data: []byte("SELECT 1;")},
+		"gosmig.sum":                    {Data: []byte("")},
+	}
+
+	repeatables, err := LoadSQLRepeatables[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock](fsys)
+	require.NoError(t, err)
+	require.Len(t, repeatables, 2)
+	require.Equal(t, "count_t_func", repeatables[0].Name)
+	require.Equal(t, "t_view", repeatables[1].Name)
+	require.Equal(t,
+		checksum([]byte("CREATE OR REPLACE VIEW v AS SELECT c FROM t;")), repeatables[1].Checksum)
+	require.NoError(t, validateRepeatables(repeatables))
+
+	tx := new(txMock)
+	tx.On("ExecContext", mock.Anything, "CREATE OR REPLACE VIEW v AS SELECT c FROM t;").
+		Return(new(dbResultMock), nil).
+		Once()
+	require.NoError(t, repeatables[1].Up(context.Background(), tx))
+	tx.AssertExpectations(t)
+
+	// Repeatable migration files are not versioned migration files.
+	migrations, err := LoadSQLMigrations[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock](fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+}
+
+func TestRunRepeatables(t *testing.T) {
+	testCases := []struct {
+		name        string
+		repeatables []repeatableMock
+		setupMock   func(*dbMock, *txMock)
+		wantOut     string
+		wantErr     string
+	}{
+		{
+			name:    "no repeatable migrations",
+			wantOut: "",
+		},
+		{
+			name: "applies new and changed, skips unchanged",
+			repeatables: []repeatableMock{
+				createTestRepeatable("views", "v2"),
+				createTestRepeatable("functions", "f1"),
+				createTestRepeatable("procedures", "p1"),
+			},
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupRepeatableChecksumMock(db, "functions", "")
+				setupRepeatableChecksumMock(db, "procedures", "p1")
+				setupRepeatableChecksumMock(db, "views", "v1")
+
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Twice()
+				upsertSQL := upsertRepeatableSQL(repeatableTableName(migrationsTableName))
+				tx.On("ExecContext", mock.Anything, upsertSQL, "functions", "f1").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("ExecContext", mock.Anything, upsertSQL, "views", "v2").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Commit").Return(nil).Twice()
+			},
+			wantOut: "[x] Applied repeatable migration functions\n" +
+				"[x] Applied repeatable migration views\n" +
+				"2 repeatable migration(s) applied\n",
+		},
+		{
+			name:        "all unchanged",
+			repeatables: []repeatableMock{createTestRepeatable("views", "v1")},
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupRepeatableChecksumMock(db, "views", "v1")
+			},
+			wantOut: "No repeatable migrations to apply\n",
+		},
+		{
+			name: "Up error rolls back",
+			repeatables: []repeatableMock{{
+				Name:     "views",
+				Checksum: "v1",
+				Up: func(ctx context.Context, tx *txMock) error {
+					return errors.New("syntax error")
+				},
+			}},
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupRepeatableChecksumMock(db, "views", "")
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("Rollback").Return(nil).Once()
+			},
+			wantErr: "failed to apply repeatable migration views: syntax error",
+		},
+		{
+			name:        "checksum query error",
+			repeatables: []repeatableMock{createTestRepeatable("views", "v1")},
+			setupMock: func(db *dbMock, tx *txMock) {
+				row := new(dbRowMock)
+				db.On("QueryRowContext", mock.Anything, mock.Anything, "views").
+					Return(row).
+					Once()
+				row.On("Scan", mock.Anything).Return(errors.New("no such table")).Once()
+			},
+			wantErr: "failed to get checksum of repeatable migration views: no such table",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			if tc.setupMock != nil {
+				tc.setupMock(db, tx)
+			}
+
+			var out bytes.Buffer
+			_, err := runRepeatables(context.Background(), tc.repeatables, db, &out, DefaultConfig())
+
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, out.String())
+		})
+	}
+}
+
+func TestRunCmdStatusRepeatables(t *testing.T) {
+	db := new(dbMock)
+	row := new(dbRowMock)
+	expectMigrationsColumns(db, migrationsTableName)
+	db.On("QueryRowContext", mock.Anything, tableExistsSQL(), repeatableTableName(migrationsTableName)).
+		Return(boolRow(true)).
+		Once()
+	db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(migrationsTableName)).
+		Return(row).
+		Once()
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*string)) = "1"
+		}).
+		Return(nil).
+		Once()
+	setupRepeatableChecksumMock(db, "active_users_view", "a1")
+	setupRepeatableChecksumMock(db, "functions", "")
+	setupRepeatableChecksumMock(db, "views", "v1")
+
+	var out bytes.Buffer
+	nbPending, err := runCmdStatus(
+		context.Background(),
+		createTestMigrations(1),
+		[]repeatableMock{
+			createTestRepeatable("views", "v2"),
+			createTestRepeatable("functions", "f1"),
+			createTestRepeatable("active_users_view", "a1"),
+		},
+		db,
+		&out,
+		DefaultConfig(),
+	)
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+
+	require.Equal(t,
+		"VERSION    STATUS      \n"+
+			"1          [x] APPLIED \n"+
+			"\n"+
+			"REPEATABLE        STATUS      \n"+
+			"active_users_view [x] APPLIED \n"+
+			"functions         [ ] PENDING \n"+
+			"views             [~] CHANGED \n",
+		out.String())
+	require.Equal(t, 2, nbPending)
+}
+
+func TestRunCmdStatusRepeatablesWithoutTables(t *testing.T) {
+	// Neither the migrations table nor the one of the repeatable migrations
+	// exist yet: everything is pending.
+	db := new(dbMock)
+	db.On("QueryRowContext", mock.Anything, selectColumnsSQL(), "", migrationsTableName).
+		Return(appliedIDsRow("")).
+		Once()
+	db.On("QueryRowContext", mock.Anything, tableExistsSQL(), repeatableTableName(migrationsTableName)).
+		Return(boolRow(false)).
+		Once()
+
+	var out bytes.Buffer
+	nbPending, err := runCmdStatus(context.Background(), createTestMigrations(1),
+		[]repeatableMock{createTestRepeatable("views", "v1")}, db, &out, DefaultConfig())
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+
+	require.Equal(t,
+		"VERSION    STATUS      \n"+
+			"1          [ ] PENDING \n"+
+			"\n"+
+			"REPEATABLE STATUS      \n"+
+			"views      [ ] PENDING \n",
+		out.String())
+	require.Equal(t, 2, nbPending)
+}
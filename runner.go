@@ -0,0 +1,223 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Target is a database, or a schema of one, which RunTargets runs a
+	// command against (e.g. the database or the schema of a tenant).
+	Target struct {
+		// Name identifies the target in the results (e.g. the tenant name).
+		Name string
+		// URL of the database, given to connectToDB.
+		URL string
+		// TableName, if not empty, overrides Config.TableName for this target.
+		TableName string
+		// Schema, if not empty, overrides Config.Schema for this target, to run
+		// the migrations in the schema of a tenant (see Config.Schema).
+		Schema string
+	}
+
+	// RunTargetsConfig is how RunTargets runs a command against its targets.
+	RunTargetsConfig struct {
+		// Concurrency is the maximum number of targets run at once.
+		// Defaults to 1 (one target after the other).
+		Concurrency int
+		// TargetTimeout bounds the run against each target. Zero means no bound,
+		// besides Config.Timeout for each database operation.
+		TargetTimeout time.Duration
+		// Phase is the phase of the expand/contract migrations which the up
+		// command applies: "expand" (the default) or "contract".
+		Phase string
+		// FailFast stops starting new targets after the first failure. The
+		// targets which are running are not interrupted, and the ones which
+		// were not started fail with ErrTargetNotRun.
+		FailFast bool
+	}
+
+	// TargetResult is the result of running a command against a target.
+	TargetResult struct {
+		Target Target
+		// Output of the command.
+		Output string
+		// NbPending is the number of pending migrations, for the status command.
+		NbPending int
+		// Duration of the run against the target.
+		Duration time.Duration
+		// Err is the error the command failed with, if any.
+		Err error
+	}
+)
+
+// targetCommands are the commands which RunTargets can run.
+var targetCommands = []string{cmdUp, cmdStatus}
+
+// RunTargets runs the up or status command against each of the targets (e.g.
+// the databases or schemas of hundreds of tenants), at most
+// runConfig.Concurrency at once, and returns their results in the order of
+// the targets. A failing target doesn't abort the others, unless
+// runConfig.FailFast is set. An error is returned only if the arguments are
+// invalid.
+func RunTargets[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	connectToDB func(url string, timeout time.Duration) (TDB, error),
+	config *Config,
+	targets []Target,
+	command string,
+	runConfig RunTargetsConfig,
+	opts ...Option[TDBRow, TDBResult, TTX, TTXO, TDB],
+) ([]TargetResult, error) {
+
+	if len(migrations) == 0 {
+		return nil, ErrNoMigrations
+	}
+
+	if connectToDB == nil {
+		return nil, fmt.Errorf("connectToDB function is nil")
+	}
+
+	if !slices.Contains(targetCommands, command) {
+		return nil, fmt.Errorf("unsupported command for targets: %q", command)
+	}
+
+	if runConfig.Phase != "" && !slices.Contains(phases, runConfig.Phase) {
+		return nil, fmt.Errorf(
+			"invalid phase: %q (expected %s)", runConfig.Phase, strings.Join(phases, " or "))
+	}
+
+	if config == nil {
+		config = DefaultConfig()
+	} else {
+		config.ensureDefaults()
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := validateMigrations(migrations, config.Versioning); err != nil {
+		return nil, err
+	}
+
+	optional, err := newOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, target := range targets {
+		targetConfig := target.config(*config)
+		if err := targetConfig.validate(); err != nil {
+			return nil, fmt.Errorf("target %s: %w", target.Name, err)
+		}
+	}
+
+	concurrency := max(runConfig.Concurrency, 1)
+	semaphore := make(chan struct{}, concurrency)
+	results := make([]TargetResult, len(targets))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		semaphore <- struct{}{}
+		if runConfig.FailFast && failed.Load() {
+			<-semaphore
+			results[i] = TargetResult{Target: target, Err: ErrTargetNotRun}
+			continue
+		}
+
+		wg.Go(func() {
+			defer func() { <-semaphore }()
+
+			results[i] = runTarget(
+				ctx, migrations, optional, connectToDB, target.config(*config), target, command, runConfig)
+			if results[i].Err != nil {
+				failed.Store(true)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	return results, nil
+}
+
+// config returns the config with the overrides of the target.
+func (t Target) config(config Config) Config {
+	if t.TableName != "" {
+		config.TableName = t.TableName
+	}
+	if t.Schema != "" {
+		config.Schema = t.Schema
+	}
+	return config
+}
+
+// runTarget runs the command against the target, with its config, as the
+// command line does (see runDBCmd).
+func runTarget[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	optional options[TDBRow, TDBResult, TTX, TTXO, TDB],
+	connectToDB func(url string, timeout time.Duration) (TDB, error),
+	config Config,
+	target Target,
+	command string,
+	runConfig RunTargetsConfig,
+) (result TargetResult) {
+
+	result.Target = target
+	start := time.Now()
+	var out bytes.Buffer
+	defer func() {
+		result.Output = out.String()
+		result.Duration = time.Since(start)
+	}()
+
+	if runConfig.TargetTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runConfig.TargetTimeout)
+		defer cancel()
+	}
+
+	// The commands sort the migrations in place, and the targets run concurrently.
+	migrations = slices.Clone(migrations)
+	optional.repeatables = slices.Clone(optional.repeatables)
+
+	db, err := connectToDB(target.URL, config.Timeout)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer func() {
+		if err := db.Close(); err != nil && result.Err == nil {
+			result.Err = err
+		}
+	}()
+
+	args := cliArgs{url: target.URL, command: command, phase: runConfig.Phase}
+	result.NbPending, _, result.Err = runDBCmd(
+		ctx, migrations, optional, db, target.URL, args, &out, &config, attrTarget.String(target.Name))
+
+	return result
+}
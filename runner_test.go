@@ -0,0 +1,301 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTargetDBMock mocks a target database at the given version, with the
+// migrations table of the given name, for the given command.
+func setupTargetDBMock(table, command string, version int) *dbMock {
+	db := new(dbMock)
+	if command == cmdStatus {
+		applied := make([]int, version)
+		for i := range applied {
+			applied[i] = i + 1
+		}
+		expectMigrationsColumns(db, table)
+		db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(table)).
+			Return(appliedVersionsRow(applied...)).
+			Once()
+	} else {
+		db.On("ExecContext", mock.Anything, createMigsTblSQL(table)).
+			Return(new(dbResultMock), nil).
+			Once()
+		db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(table)).
+			Return(dbVersionRow(version)).
+			Once()
+	}
+	db.On("Close").Return(nil).Once()
+	return db
+}
+
+func TestRunTargets(t *testing.T) {
+	connectErr := errors.New("connection refused")
+
+	testCases := []struct {
+		name        string
+		targets     []Target
+		command     string
+		runConfig   RunTargetsConfig
+		setupMocks  func() map[string]*dbMock
+		wantResults []TargetResult
+	}{
+		{
+			name: "status of all targets",
+			targets: []Target{
+				{Name: "tenant_a", URL: "postgres://a"},
+				{Name: "tenant_b", URL: "postgres://b"},
+			},
+			command:   cmdStatus,
+			runConfig: RunTargetsConfig{Concurrency: 2},
+			setupMocks: func() map[string]*dbMock {
+				return map[string]*dbMock{
+					"postgres://a": setupTargetDBMock(migrationsTableName, cmdStatus, 3),
+					"postgres://b": setupTargetDBMock(migrationsTableName, cmdStatus, 1),
+				}
+			},
+			wantResults: []TargetResult{
+				{
+					Target: Target{Name: "tenant_a", URL: "postgres://a"},
+					Output: "VERSION    STATUS      \n" +
+						"3          [x] APPLIED \n" +
+						"2          [x] APPLIED \n" +
+						"1          [x] APPLIED \n",
+				},
+				{
+					Target: Target{Name: "tenant_b", URL: "postgres://b"},
+					Output: "VERSION    STATUS      \n" +
+						"3          [ ] PENDING \n" +
+						"2          [ ] PENDING \n" +
+						"1          [x] APPLIED \n",
+					NbPending: 2,
+				},
+			},
+		},
+		{
+			name: "a failing target doesn't abort the others",
+			targets: []Target{
+				{Name: "tenant_a", URL: "postgres://a"},
+				{Name: "tenant_b", URL: "postgres://b", TableName: "tenant_b.gosmig"},
+			},
+			command: cmdUp,
+			setupMocks: func() map[string]*dbMock {
+				db := setupTargetDBMock("tenant_b.gosmig", cmdUp, 2)
+				tx := new(txMock)
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL("tenant_b.gosmig")).
+					Return(dbVersionRow(2)).
+					Once()
+				tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("ExecContext", mock.Anything, insertMigVersionSQL("tenant_b.gosmig"), 3).
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Commit").Return(nil).Once()
+				return map[string]*dbMock{"postgres://b": db}
+			},
+			wantResults: []TargetResult{
+				{
+					Target: Target{Name: "tenant_a", URL: "postgres://a"},
+					Err:    connectErr,
+				},
+				{
+					Target: Target{Name: "tenant_b", URL: "postgres://b", TableName: "tenant_b.gosmig"},
+					Output: "[x] Applied migration version 3\n1 migration(s) applied\n",
+				},
+			},
+		},
+		{
+			name: "fail fast",
+			targets: []Target{
+				{Name: "tenant_a", URL: "postgres://a"},
+				{Name: "tenant_b", URL: "postgres://b"},
+			},
+			command:   cmdUp,
+			runConfig: RunTargetsConfig{FailFast: true},
+			setupMocks: func() map[string]*dbMock {
+				return map[string]*dbMock{}
+			},
+			wantResults: []TargetResult{
+				{
+					Target: Target{Name: "tenant_a", URL: "postgres://a"},
+					Err:    connectErr,
+				},
+				{
+					Target: Target{Name: "tenant_b", URL: "postgres://b"},
+					Err:    ErrTargetNotRun,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbs := tc.setupMocks()
+			var connectedMu sync.Mutex
+			var connected []string
+			connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+				connectedMu.Lock()
+				connected = append(connected, url)
+				connectedMu.Unlock()
+				db, ok := dbs[url]
+				if !ok {
+					return nil, connectErr
+				}
+				return db, nil
+			}
+
+			results, err := RunTargets(
+				context.Background(), createTestMigrations(1, 2, 3), connectToDB, nil,
+				tc.targets, tc.command, tc.runConfig)
+			require.NoError(t, err)
+
+			for _, db := range dbs {
+				db.AssertExpectations(t)
+			}
+
+			require.Len(t, results, len(tc.wantResults))
+			for i, want := range tc.wantResults {
+				require.Equal(t, want.Target, results[i].Target)
+				require.Equal(t, want.Output, results[i].Output)
+				require.Equal(t, want.NbPending, results[i].NbPending)
+				require.ErrorIs(t, results[i].Err, want.Err)
+			}
+			if tc.runConfig.FailFast {
+				require.Equal(t, []string{"postgres://a"}, connected)
+			}
+		})
+	}
+}
+
+func TestRunTargetsPhase(t *testing.T) {
+	migrations := []migrationMock{createTestContractMigration(1, nil), createTestMigrations(2)[0]}
+
+	testCases := []struct {
+		name      string
+		phase     string
+		setupMock func(*dbMock, *txMock)
+		wantOut   string
+	}{
+		{
+			name: "expand phase by default",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupMigrationUpMocks(db, tx, new(dbRowMock), new(dbResultMock), 1, 2)
+			},
+			wantOut: "[x] Applied migration version 2\n1 migration(s) applied\n",
+		},
+		{
+			name:  "contract phase",
+			phase: phaseContract,
+			setupMock: func(db *dbMock, tx *txMock) {
+				db.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+					Return(contractedRow(false)).
+					Once()
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(1)).
+					Once()
+				tx.On("QueryRowContext", mock.Anything, selectMigContractedSQL(migrationsTableName), 1).
+					Return(contractedRow(false)).
+					Once()
+				tx.On("ExecContext", mock.Anything, "ALTER TABLE test DROP COLUMN old_id").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("ExecContext", mock.Anything, updateMigContractedSQL(migrationsTableName), 1).
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Commit").Return(nil).Once()
+			},
+			wantOut: "[x] Applied contract phase of migration version 1\n" +
+				"1 contract phase(s) applied\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := setupTargetDBMock(migrationsTableName, cmdUp, 1)
+			db.On("ExecContext", mock.Anything, addContractedAtColumnSQL(migrationsTableName)).
+				Return(new(dbResultMock), nil).
+				Once()
+			tx := new(txMock)
+			tc.setupMock(db, tx)
+			connectToDB := func(url string, timeout time.Duration) (*dbMock, error) { return db, nil }
+
+			results, err := RunTargets(
+				context.Background(), migrations, connectToDB, nil,
+				[]Target{{Name: "tenant_a", URL: "postgres://a"}}, cmdUp, RunTargetsConfig{Phase: tc.phase})
+			require.NoError(t, err)
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+
+			require.Len(t, results, 1)
+			require.NoError(t, results[0].Err)
+			require.Equal(t, tc.wantOut, results[0].Output)
+		})
+	}
+}
+
+func TestRunTargetsInvalidArgs(t *testing.T) {
+	connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+		return new(dbMock), nil
+	}
+
+	testCases := []struct {
+		name       string
+		migrations []migrationMock
+		targets    []Target
+		command    string
+		runConfig  RunTargetsConfig
+		wantErr    string
+	}{
+		{
+			name:    "no migrations",
+			command: cmdUp,
+			wantErr: ErrNoMigrations.Error(),
+		},
+		{
+			name:       "unsupported command",
+			migrations: createTestMigrations(1),
+			command:    cmdDown,
+			wantErr:    `unsupported command for targets: "down"`,
+		},
+		{
+			name:       "invalid phase",
+			migrations: createTestMigrations(1),
+			command:    cmdUp,
+			runConfig:  RunTargetsConfig{Phase: "migrate"},
+			wantErr:    `invalid phase: "migrate" (expected expand or contract)`,
+		},
+		{
+			name:       "invalid target table name",
+			migrations: createTestMigrations(1),
+			targets:    []Target{{Name: "tenant_a", URL: "postgres://a", TableName: "tenant a.gosmig"}},
+			command:    cmdUp,
+			wantErr:    `target tenant_a: invalid config: invalid migrations table name: "tenant a.gosmig"`,
+		},
+		{
+			name:       "invalid target schema",
+			migrations: createTestMigrations(1),
+			targets:    []Target{{Name: "tenant_a", URL: "postgres://a", Schema: "tenant a"}},
+			command:    cmdStatus,
+			wantErr:    `target tenant_a: invalid config: invalid schema: "tenant a"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := RunTargets(
+				context.Background(), tc.migrations, connectToDB, nil,
+				tc.targets, tc.command, tc.runConfig)
+			require.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package gosmig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// SchemaDumper dumps the schema of a database as SQL statements which
+// recreate it. It is used by the squash command to generate the baseline
+// migration. The tables to exclude are gosmig's own tables.
+type SchemaDumper interface {
+	DumpSchema(ctx context.Context, dbURL string, excludeTables []string) (string, error)
+}
+
+// PGDumpSchemaDumper dumps the schema of a PostgreSQL database with pg_dump.
+// The password of the database URL, if any, is passed to pg_dump in the
+// PGPASSWORD environment variable, so that it doesn't show in the process list.
+type PGDumpSchemaDumper struct {
+	// Command is the pg_dump command. Defaults to "pg_dump".
+	Command string
+	// Args are extra arguments for pg_dump (e.g. "--schema=myschema").
+	Args []string
+}
+
+// SQLiteSchemaDumper dumps the schema of a SQLite database with the .schema
+// command of the sqlite3 shell. Note that the SQL of gosmig's own tables is
+// written for PostgreSQL: the dumper is only of use with a DB whose driver
+// translates it (or to squash the migrations of a SQLite database which is
+// migrated by other means).
+type SQLiteSchemaDumper struct {
+	// Command is the sqlite3 command. Defaults to "sqlite3".
+	Command string
+}
+
+var (
+	// pg_dump sets an empty search_path for the rest of the session, which
+	// would affect the migrations run after the baseline on the same connection.
+	pgDumpSearchPathRegexp = regexp.MustCompile(
+		`(?m)^SELECT pg_catalog\.set_config\('search_path', '', false\);\n`)
+	// psql meta-commands (e.g. \restrict) can't be run by database drivers.
+	pgDumpMetaCommandRegexp = regexp.MustCompile(`(?m)^\\.*\n`)
+
+	sqliteCreateTableRegexp = regexp.MustCompile(
+		"(?i)^CREATE TABLE (?:IF NOT EXISTS )?[\"`\\[]?([A-Za-z0-9_]+)")
+)
+
+func (d PGDumpSchemaDumper) DumpSchema(
+	ctx context.Context, dbURL string, excludeTables []string,
+) (string, error) {
+
+	args := []string{"--schema-only", "--no-owner", "--no-privileges"}
+	for _, table := range excludeTables {
+		args = append(args, "--exclude-table="+table)
+	}
+	args = append(args, d.Args...)
+	dbURL, password := pgURLWithoutPassword(dbURL)
+	args = append(args, "--dbname="+dbURL)
+
+	var env []string
+	if password != "" {
+		env = []string{"PGPASSWORD=" + password}
+	}
+
+	schema, err := runDumpCommand(ctx, env, orDefault(d.Command, "pg_dump"), args...)
+	if err != nil {
+		return "", err
+	}
+
+	schema = pgDumpSearchPathRegexp.ReplaceAllString(schema, "")
+	schema = pgDumpMetaCommandRegexp.ReplaceAllString(schema, "")
+
+	return schema, nil
+}
+
+// pgURLWithoutPassword returns the PostgreSQL URL without its password, from
+// its user info or its password parameter, and the password. Other connection
+// strings (e.g. "host=localhost dbname=db") are returned as is.
+func pgURLWithoutPassword(dbURL string) (string, string) {
+	u, err := url.Parse(dbURL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return dbURL, ""
+	}
+
+	var password string
+	if u.User != nil {
+		password, _ = u.User.Password()
+		if username := u.User.Username(); username != "" {
+			u.User = url.User(username)
+		} else {
+			u.User = nil
+		}
+	}
+	if query := u.Query(); query.Has("password") {
+		password = query.Get("password")
+		query.Del("password")
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String(), password
+}
+
+func (d SQLiteSchemaDumper) DumpSchema(
+	ctx context.Context, dbURL string, excludeTables []string,
+) (string, error) {
+
+	schema, err := runDumpCommand(ctx, nil, orDefault(d.Command, "sqlite3"), sqlitePath(dbURL), ".schema")
+	if err != nil {
+		return "", err
+	}
+
+	// SQLite has no schemas, so schema-qualified names are matched on the table name.
+	excluded := make([]string, len(excludeTables))
+	for i, table := range excludeTables {
+		excluded[i] = table[strings.LastIndex(table, ".")+1:]
+	}
+
+	var statements []string
+	for statement := range strings.SplitSeq(schema, ";\n") {
+		statement = strings.TrimSpace(statement)
+		if statement == "" {
+			continue
+		}
+		if matches := sqliteCreateTableRegexp.FindStringSubmatch(statement); matches != nil {
+			table := matches[1]
+			if strings.HasPrefix(strings.ToLower(table), "sqlite_") ||
+				containsFold(excluded, table) {
+				continue
+			}
+		}
+		statements = append(statements, strings.TrimSuffix(statement, ";")+";\n")
+	}
+
+	return strings.Join(statements, ""), nil
+}
+
+// sqlitePath returns the path of the database file from a SQLite URL
+// (e.g. "sqlite://app.db", "file:app.db?mode=ro" or just "app.db").
+func sqlitePath(dbURL string) string {
+	for _, prefix := range []string{"sqlite3://", "sqlite://", "file:"} {
+		dbURL = strings.TrimPrefix(dbURL, prefix)
+	}
+	path, _, _ := strings.Cut(dbURL, "?")
+	return path
+}
+
+// runDumpCommand runs the command with the given environment variables on top
+// of the ones of the process, and returns its output.
+func runDumpCommand(ctx context.Context, env []string, command string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("failed to dump the database schema with %s: %w: %s",
+				command, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to dump the database schema with %s: %w", command, err)
+	}
+	return string(out), nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
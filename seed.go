@@ -0,0 +1,266 @@
+package gosmig
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type (
+	// SeedSet is a named set of seed data (e.g. development fixtures or
+	// reference lookup tables), applied on demand with the seed command, e.g.
+	// only in the environments which need it. Seed sets are tracked apart from
+	// the migrations, so they don't count toward the database version, and each
+	// is applied at most once.
+	SeedSet[TDBRow DBRow, TDBResult DBResult, TTX TX[TDBRow, TDBResult], TTXO TXOptions, TDB DB[TDBRow, TDBResult, TTX, TTXO]] struct {
+		// Name identifies the seed set on the command line and in the tracking table.
+		Name string
+		// MinVersion is the minimum database version the seed set requires
+		// (e.g. the version creating the tables it fills). 0 means any version.
+		MinVersion int
+		// Seed inserts the seed data, in a transaction.
+		Seed func(ctx context.Context, tx TTX) error
+	}
+
+	SeedSetSQL = SeedSet[*sql.Row, sql.Result, *sql.Tx, *sql.TxOptions, *sql.DB]
+)
+
+func (s SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]) validate() error {
+	if !nameRegexp.MatchString(s.Name) {
+		return fmt.Errorf(
+			"seed set name %q must only contain letters, digits, _ and -", s.Name)
+	}
+
+	if s.MinVersion < 0 {
+		return fmt.Errorf("seed set %s MinVersion must be >= 0", s.Name)
+	}
+
+	if s.Seed == nil {
+		return fmt.Errorf("seed set %s must have a Seed function defined", s.Name)
+	}
+
+	return nil
+}
+
+func validateSeedSets[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	seedSets []SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB],
+) error {
+
+	nameCounters := make(map[string]int)
+
+	var validationErrs []string
+	for _, seedSet := range seedSets {
+		nameCounters[seedSet.Name]++
+		if err := seedSet.validate(); err != nil {
+			validationErrs = append(validationErrs, err.Error())
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(nameCounters)) {
+		if count := nameCounters[name]; count > 1 {
+			validationErrs = append(validationErrs,
+				fmt.Sprintf("seed set %s is defined %d times", name, count))
+		}
+	}
+
+	if len(validationErrs) > 0 {
+		return fmt.Errorf(
+			"%w: %s", ErrInvalidMigrations, strings.Join(validationErrs, "; "))
+	}
+
+	return nil
+}
+
+// LoadSQLSeedSet loads a seed set from the .sql files in the root directory
+// of fsys (e.g. a sub-directory of an embed.FS, see fs.Sub). The files are
+// executed in the order of their names, in a single transaction.
+func LoadSQLSeedSet[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	fsys fs.FS,
+	name string,
+	minVersion int,
+) (SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB], error) {
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]{},
+			fmt.Errorf("failed to read SQL seed set %s directory: %w", name, err)
+	}
+
+	var seeds []func(context.Context, TTX) error
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]{},
+				fmt.Errorf("failed to read SQL seed file %s: %w", entry.Name(), err)
+		}
+		seeds = append(seeds, execSQL[TDBRow, TDBResult, TTX](content))
+	}
+
+	if len(seeds) == 0 {
+		return SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]{},
+			fmt.Errorf("no SQL files for seed set %s", name)
+	}
+
+	return SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]{
+		Name:       name,
+		MinVersion: minVersion,
+		Seed: func(ctx context.Context, tx TTX) error {
+			for _, seed := range seeds {
+				if err := seed(ctx, tx); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}, nil
+}
+
+// LoadSQLSeedSetSQL is LoadSQLSeedSet for database/sql.
+func LoadSQLSeedSetSQL(fsys fs.FS, name string, minVersion int) (SeedSetSQL, error) {
+	return LoadSQLSeedSet[*sql.Row, sql.Result, *sql.Tx, *sql.TxOptions, *sql.DB](
+		fsys, name, minVersion)
+}
+
+// runCmdSeed applies the seed set with the given name, unless it was
+// already applied or the database version is lower than its MinVersion.
+func runCmdSeed[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	seedSets []SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+	name string,
+) error {
+
+	i := slices.IndexFunc(seedSets, func(s SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return s.Name == name
+	})
+	if i < 0 {
+		return fmt.Errorf("unknown seed set: %q", name)
+	}
+	seedSet := seedSets[i]
+
+	applied, err := isSeedSetApplied(ctx, db, name, config)
+	if err != nil {
+		return err
+	}
+	if applied {
+		_, _ = fmt.Fprintf(output, "Seed set %s is already applied\n", name)
+		return nil
+	}
+
+	dbVersion, err := getDBVersion(ctx, db, config)
+	if err != nil {
+		return err
+	}
+	if dbVersion < seedSet.MinVersion {
+		return fmt.Errorf(
+			"seed set %s requires database version >= %d, but it is at version %d",
+			name, seedSet.MinVersion, dbVersion)
+	}
+
+	err = executeInTx(ctx, db, func(ctx context.Context, tx TTX) error {
+		if err := setSearchPath(ctx, tx, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		seedCtx, cancelSeed := context.WithTimeout(ctx, config.Timeout)
+		defer cancelSeed()
+		if err := seedSet.Seed(seedCtx, tx); err != nil {
+			return withPhase(PhaseFunc, fmt.Errorf("failed to apply seed set %s: %w", name, err))
+		}
+
+		if err := insertSeedSet(ctx, tx, name, config); err != nil {
+			return withPhase(PhaseBookkeeping, err)
+		}
+
+		return nil
+	}, config.Timeout)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(output, "[x] Applied seed set %s\n", name)
+
+	return nil
+}
+
+// runCmdSeedStatus lists the seed sets, sorted by name, and returns the
+// number of the ones not applied yet.
+func runCmdSeedStatus[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	seedSets []SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB],
+	db TDB,
+	output io.Writer,
+	config *Config,
+) (int, error) {
+
+	slices.SortFunc(seedSets, func(a, b SeedSet[TDBRow, TDBResult, TTX, TTXO, TDB]) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	nameWidth := len("SEED SET")
+	for _, seedSet := range seedSets {
+		nameWidth = max(nameWidth, len(seedSet.Name))
+	}
+
+	var nbPending int
+	_, _ = fmt.Fprintf(output, "%-*s %-12s %s\n", nameWidth, "SEED SET", "STATUS", "MIN VERSION")
+	for _, seedSet := range seedSets {
+		applied, err := isSeedSetApplied(ctx, db, seedSet.Name, config)
+		if err != nil {
+			return 0, err
+		}
+
+		status := "[x] APPLIED"
+		if !applied {
+			status = "[ ] PENDING"
+			nbPending++
+		}
+
+		minVersion := "-"
+		if seedSet.MinVersion > 0 {
+			minVersion = strconv.Itoa(seedSet.MinVersion)
+		}
+
+		_, _ = fmt.Fprintf(output, "%-*s %-12s %s\n", nameWidth, seedSet.Name, status, minVersion)
+	}
+
+	return nbPending, nil
+}
@@ -0,0 +1,269 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type seedSetMock = SeedSet[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock]
+
+func createTestSeedSet(name string, minVersion int) seedSetMock {
+	return seedSetMock{
+		Name:       name,
+		MinVersion: minVersion,
+		Seed:       func(ctx context.Context, tx *txMock) error { return nil },
+	}
+}
+
+// setupSeedSetAppliedMock mocks the query telling whether a seed set was applied.
+func setupSeedSetAppliedMock(db *dbMock, name string, applied bool) {
+	row := new(dbRowMock)
+	db.On("QueryRowContext", mock.Anything,
+		selectSeedSetCountSQL(seedTableName(migrationsTableName)), name).
+		Return(row).
+		Once()
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			if applied {
+				*(args.Get(0).([]any)[0].(*int)) = 1
+			}
+		}).
+		Return(nil).
+		Once()
+}
+
+func TestValidateSeedSets(t *testing.T) {
+	testCases := []struct {
+		name     string
+		seedSets []seedSetMock
+		wantErr  string
+	}{
+		{
+			name:     "valid",
+			seedSets: []seedSetMock{createTestSeedSet("dev", 0), createTestSeedSet("lookups", 3)},
+		},
+		{
+			name:     "invalid name",
+			seedSets: []seedSetMock{createTestSeedSet("dev fixtures", 0)},
+			wantErr: `invalid migration(s): seed set name "dev fixtures" ` +
+				"must only contain letters, digits, _ and -",
+		},
+		{
+			name:     "negative MinVersion",
+			seedSets: []seedSetMock{createTestSeedSet("dev", -1)},
+			wantErr:  "invalid migration(s): seed set dev MinVersion must be >= 0",
+		},
+		{
+			name:     "missing Seed",
+			seedSets: []seedSetMock{{Name: "dev"}},
+			wantErr:  "invalid migration(s): seed set dev must have a Seed function defined",
+		},
+		{
+			name:     "duplicate names",
+			seedSets: []seedSetMock{createTestSeedSet("dev", 0), createTestSeedSet("dev", 1)},
+			wantErr:  "invalid migration(s): seed set dev is defined 2 times",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateSeedSets(tc.seedSets)
+			if tc.wantErr != "" {
+				require.ErrorIs(t, err, ErrInvalidMigrations)
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadSQLSeedSet(t *testing.T) {
+	t.Run("executes the files in order", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"02_users.sql":     {Data: []byte("INSERT INTO users VALUES (1);")},
+			"01_countries.sql": {Data: []byte("INSERT INTO countries VALUES ('RO');")},
+			"README.md":        {Data: []byte("Development fixtures")},
+		}
+
+		seedSet, err := LoadSQLSeedSet[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock](
+			fsys, "dev", 2)
+		require.NoError(t, err)
+		require.Equal(t, "dev", seedSet.Name)
+		require.Equal(t, 2, seedSet.MinVersion)
+
+		var executed []string
+		tx := new(txMock)
+		tx.On("ExecContext", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { executed = append(executed, args.String(1)) }).
+			Return(new(dbResultMock), nil).
+			Twice()
+		require.NoError(t, seedSet.Seed(context.Background(), tx))
+		require.Equal(t,
+			[]string{"INSERT INTO countries VALUES ('RO');", "INSERT INTO users VALUES (1);"},
+			executed)
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"01.sql": {Data: []byte("bad")},
+			"02.sql": {Data: []byte("INSERT INTO users VALUES (1);")},
+		}
+
+		seedSet, err := LoadSQLSeedSet[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock](
+			fsys, "dev", 0)
+		require.NoError(t, err)
+
+		tx := new(txMock)
+		tx.On("ExecContext", mock.Anything, "bad").
+			Return(new(dbResultMock), errors.New("syntax error")).
+			Once()
+		require.EqualError(t, seedSet.Seed(context.Background(), tx), "syntax error")
+		tx.AssertExpectations(t)
+	})
+
+	t.Run("no SQL files", func(t *testing.T) {
+		_, err := LoadSQLSeedSet[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock](
+			fstest.MapFS{}, "dev", 0)
+		require.EqualError(t, err, "no SQL files for seed set dev")
+	})
+}
+
+func TestRunCmdSeed(t *testing.T) {
+	seedErr := errors.New("duplicate key")
+
+	testCases := []struct {
+		name      string
+		seedSets  []seedSetMock
+		seedName  string
+		setupMock func(*dbMock, *txMock)
+		wantOut   string
+		wantErr   string
+	}{
+		{
+			name:     "applies the seed set",
+			seedSets: []seedSetMock{createTestSeedSet("dev", 2), createTestSeedSet("lookups", 0)},
+			seedName: "dev",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupSeedSetAppliedMock(db, "dev", false)
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("ExecContext", mock.Anything,
+					insertSeedSetSQL(seedTableName(migrationsTableName)), "dev").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Commit").Return(nil).Once()
+			},
+			wantOut: "[x] Applied seed set dev\n",
+		},
+		{
+			name:     "already applied",
+			seedSets: []seedSetMock{createTestSeedSet("dev", 0)},
+			seedName: "dev",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupSeedSetAppliedMock(db, "dev", true)
+			},
+			wantOut: "Seed set dev is already applied\n",
+		},
+		{
+			name:      "unknown seed set",
+			seedSets:  []seedSetMock{createTestSeedSet("dev", 0)},
+			seedName:  "prod",
+			setupMock: func(db *dbMock, tx *txMock) {},
+			wantErr:   `unknown seed set: "prod"`,
+		},
+		{
+			name:     "database version too low",
+			seedSets: []seedSetMock{createTestSeedSet("dev", 3)},
+			seedName: "dev",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupSeedSetAppliedMock(db, "dev", false)
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(2)).
+					Once()
+			},
+			wantErr: "seed set dev requires database version >= 3, but it is at version 2",
+		},
+		{
+			name: "seed error rolls back",
+			seedSets: []seedSetMock{{
+				Name: "dev",
+				Seed: func(ctx context.Context, tx *txMock) error { return seedErr },
+			}},
+			seedName: "dev",
+			setupMock: func(db *dbMock, tx *txMock) {
+				setupSeedSetAppliedMock(db, "dev", false)
+				db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+					Return(dbVersionRow(0)).
+					Once()
+				db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+				tx.On("Rollback").Return(nil).Once()
+			},
+			wantErr: "failed to apply seed set dev: duplicate key",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			tx := new(txMock)
+			tc.setupMock(db, tx)
+
+			var out bytes.Buffer
+			err := runCmdSeed(context.Background(), tc.seedSets, db, &out, DefaultConfig(), tc.seedName)
+
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOut, out.String())
+		})
+	}
+}
+
+func TestRunCmdSeedStatus(t *testing.T) {
+	db := new(dbMock)
+	setupSeedSetAppliedMock(db, "dev", false)
+	setupSeedSetAppliedMock(db, "reference_data", true)
+
+	var out bytes.Buffer
+	nbPending, err := runCmdSeedStatus(
+		context.Background(),
+		[]seedSetMock{createTestSeedSet("reference_data", 0), createTestSeedSet("dev", 12)},
+		db,
+		&out,
+		DefaultConfig(),
+	)
+	require.NoError(t, err)
+	db.AssertExpectations(t)
+
+	require.Equal(t,
+		"SEED SET       STATUS       MIN VERSION\n"+
+			"dev            [ ] PENDING  12\n"+
+			"reference_data [x] APPLIED  -\n",
+		out.String())
+	require.Equal(t, 1, nbPending)
+}
+
+func dbVersionRow(version int) *dbRowMock {
+	row := new(dbRowMock)
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args.Get(0).([]any)[0].(*int)) = version
+		}).
+		Return(nil).
+		Once()
+	return row
+}
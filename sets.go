@@ -0,0 +1,361 @@
+package gosmig
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	// MigrationSet is a named set of migrations with its own version sequence
+	// (e.g. the migrations of a library embedded in a service), run along with
+	// other sets by NewSets. Its migrations, repeatable migrations and seed sets
+	// are tracked in their own tables, named after Config.TableName and the
+	// set name (e.g. gosmig_auth for the auth set).
+	MigrationSet[TDBRow DBRow, TDBResult DBResult, TTX TX[TDBRow, TDBResult], TTXO TXOptions, TDB DB[TDBRow, TDBResult, TTX, TTXO]] struct {
+		// Name identifies the set on the command line (--set <name>), and in
+		// its tracking tables. It must only contain letters, digits and _, and
+		// must not end with the suffix of a tracking table (e.g. lock). Names
+		// differing only in case name the same tables, so they're duplicates.
+		Name string
+		// Migrations of the set.
+		Migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB]
+		// MigrationsDir, if not empty, overrides Config.MigrationsDir for the set.
+		MigrationsDir string
+		// Options of the set (e.g. WithRepeatables).
+		Options []Option[TDBRow, TDBResult, TTX, TTXO, TDB]
+	}
+
+	MigrationSetSQL = MigrationSet[*sql.Row, sql.Result, *sql.Tx, *sql.TxOptions, *sql.DB]
+)
+
+// setNameRegexp is what the names of migration sets must match, since they
+// are part of the names of their tracking tables.
+var setNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// reservedSetNameSuffix returns the suffix of the tracking tables (e.g. lock
+// for gosmig_lock) which the set name ends with, if any: the migrations table
+// of the set would then be a tracking table of the migrations table of New,
+// or of another set.
+func reservedSetNameSuffix(name string) (string, bool) {
+	name = strings.ToLower(name) // PostgreSQL folds unquoted names to lower case
+	for _, table := range trackingTables(DefaultConfig())[1:] {
+		suffix := strings.TrimPrefix(table, migrationsTableName+"_")
+		if name == suffix || strings.HasSuffix(name, "_"+suffix) {
+			return suffix, true
+		}
+	}
+	return "", false
+}
+
+// singleSetCommands are the commands which need a set to be selected with
+// --set when there are several sets. The other ones run on all the sets, in
+// the order they were given to NewSets.
+var singleSetCommands = []string{
+	cmdUpOne,
+	cmdDown,
+	cmdGoto,
+	cmdResetBatch,
+	cmdCreate,
+	cmdSquash,
+	cmdSeed,
+}
+
+// NewSets is like New, but for several named migration sets (e.g. the ones of
+// the libraries embedded in a service), each with its own version sequence
+// and tracking tables. The commands run on the set selected with --set
+// <name>, or else on all the sets, in the given order (up-one, down, goto,
+// reset-batch, create, squash and seed need --set when there are several
+// sets). It refuses to run while the migrations table of New has migrations
+// applied (see ErrMigrationsOutsideSets).
+func NewSets[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	sets []MigrationSet[TDBRow, TDBResult, TTX, TTXO, TDB],
+	connectToDB func(url string, timeout time.Duration) (TDB, error),
+	config *Config,
+) (func(), error) { // coverage-ignore
+
+	getArgs := func() []string {
+		return os.Args[1:]
+	}
+
+	return newGosmigSets(sets, connectToDB, config, getArgs, os.Exit, os.Stdout, os.Stderr)
+}
+
+// preparedSet is a migration set with its options applied.
+type preparedSet[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]] struct {
+	MigrationSet[TDBRow, TDBResult, TTX, TTXO, TDB]
+	optional options[TDBRow, TDBResult, TTX, TTXO, TDB]
+}
+
+// config returns the config of the set: its tracking table is named after
+// the set, and it may have its own migrations directory.
+func (s MigrationSet[TDBRow, TDBResult, TTX, TTXO, TDB]) config(config Config) Config {
+	config.TableName += "_" + s.Name
+	if s.MigrationsDir != "" {
+		config.MigrationsDir = s.MigrationsDir
+	}
+	return config
+}
+
+func newGosmigSets[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	sets []MigrationSet[TDBRow, TDBResult, TTX, TTXO, TDB],
+	connectToDB func(url string, timeout time.Duration) (TDB, error),
+	config *Config,
+	getArgs func() []string,
+	osExit func(int),
+	out, errOut io.Writer,
+) (func(), error) {
+
+	if len(sets) == 0 {
+		return nil, errors.New("no migration sets provided")
+	}
+
+	if connectToDB == nil {
+		return nil, fmt.Errorf("connectToDB function is nil")
+	}
+
+	if config == nil {
+		config = DefaultConfig()
+	} else {
+		config.ensureDefaults()
+	}
+
+	if getArgs == nil {
+		return nil, fmt.Errorf("getArgs function is nil")
+	}
+
+	if osExit == nil {
+		return nil, fmt.Errorf("osExit function is nil")
+	}
+
+	if out == nil {
+		return nil, fmt.Errorf("out writer is nil")
+	}
+
+	if errOut == nil {
+		return nil, fmt.Errorf("errOut writer is nil")
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	prepared := make([]preparedSet[TDBRow, TDBResult, TTX, TTXO, TDB], len(sets))
+	for i, set := range sets {
+		if !setNameRegexp.MatchString(set.Name) {
+			return nil, fmt.Errorf(
+				"migration set name %q must only contain letters, digits and _", set.Name)
+		}
+
+		if suffix, ok := reservedSetNameSuffix(set.Name); ok {
+			return nil, fmt.Errorf(
+				"migration set name %q must not end with %s, which names tracking tables", set.Name, suffix)
+		}
+
+		if slices.ContainsFunc(sets[:i], func(s MigrationSet[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+			return strings.EqualFold(s.Name, set.Name) // their tracking tables would be the same
+		}) {
+			return nil, fmt.Errorf("migration set %s is defined more than once", set.Name)
+		}
+
+		if len(set.Migrations) == 0 {
+			return nil, fmt.Errorf("set %s: %w", set.Name, ErrNoMigrations)
+		}
+
+		setConfig := set.config(*config)
+		if err := setConfig.validate(); err != nil {
+			return nil, fmt.Errorf("set %s: %w", set.Name, err)
+		}
+
+		if err := validateMigrations(set.Migrations, config.Versioning); err != nil && !validateRun(getArgs) {
+			return nil, fmt.Errorf("set %s: %w", set.Name, err)
+		}
+
+		optional, err := newOptions(set.Options...)
+		if err != nil {
+			return nil, fmt.Errorf("set %s: %w", set.Name, err)
+		}
+
+		prepared[i] = preparedSet[TDBRow, TDBResult, TTX, TTXO, TDB]{MigrationSet: set, optional: optional}
+	}
+
+	return func() {
+		args, err := parseArgs(getArgs())
+		if err != nil {
+			errExit(ExitUsageError, err, errOut, osExit)
+			return
+		}
+
+		selected, err := selectSets(prepared, args)
+		if err != nil {
+			errExit(ExitUsageError, err, errOut, osExit)
+			return
+		}
+
+		if args.command == cmdCompletion || args.command == cmdComplete {
+			var migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB]
+			for _, set := range prepared {
+				migrations = append(migrations, set.Migrations...)
+			}
+			if err := runOfflineCmd(migrations, args, out, config); err != nil {
+				errExit(ExitUsageError, err, errOut, osExit)
+			}
+			return
+		}
+
+		if slices.Contains(offlineCommands, args.command) {
+			for _, set := range selected {
+				writeSetHeader(out, set.Name, len(selected))
+				setConfig := set.config(*config)
+				if err := runOfflineCmd(set.Migrations, args, out, &setConfig); err != nil {
+					exitCode := ExitUsageError
+					if errors.Is(err, ErrInvalidMigrations) {
+						exitCode = ExitValidationFailure
+					}
+					errExit(exitCode, fmt.Errorf("set %s: %w", set.Name, err), errOut, osExit)
+					return
+				}
+			}
+			return
+		}
+
+		config, url, err := resolveConfig(*config, args)
+		if err != nil {
+			errExit(ExitUsageError, err, errOut, osExit)
+			return
+		}
+
+		ctx := context.Background()
+
+		db, err := connectToDB(url, config.Timeout)
+		if err != nil {
+			errExit(ExitConnectionError, err, errOut, osExit)
+			return
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				errExit(ExitConnectionError, err, errOut, osExit)
+				return
+			}
+		}()
+
+		if err := checkMigrationsOutsideSets(ctx, db, config); err != nil {
+			errExit(ExitFailure, err, errOut, osExit)
+			return
+		}
+
+		var nbPending int
+		for _, set := range selected {
+			writeSetHeader(out, set.Name, len(selected))
+			setConfig := set.config(*config)
+			nbSetPending, exitCode, err := runDBCmd(
+				ctx, set.Migrations, set.optional, db, url, args, out, &setConfig)
+			if err != nil {
+				errExit(exitCode, fmt.Errorf("set %s: %w", set.Name, err), errOut, osExit)
+				return
+			}
+			nbPending += nbSetPending
+		}
+		if args.exitCode && nbPending > 0 {
+			errExit(ExitPendingMigrations, pendingErr(args.command, nbPending), errOut, osExit)
+			return
+		}
+	}, nil
+}
+
+// checkMigrationsOutsideSets checks that the migrations table of New, named
+// after Config.TableName alone, has no migrations applied: adopting sets in a
+// database migrated without them would otherwise apply the migrations again
+// in the tables of the sets (see ErrMigrationsOutsideSets).
+func checkMigrationsOutsideSets[TDBRow DBRow, TDBResult DBResult](
+	ctx context.Context,
+	dbOrTX DBOrTX[TDBRow, TDBResult],
+	config *Config,
+) error {
+
+	table := config.migrationsTable()
+	exists, err := tableExists(ctx, dbOrTX, table, config)
+	if err != nil || !exists {
+		return err
+	}
+
+	dbVersion, err := getDBVersion(ctx, dbOrTX, config)
+	if err != nil {
+		return err
+	}
+	if dbVersion > 0 {
+		return fmt.Errorf(
+			"%w: table %s is at version %d (rename it, and its tracking tables, after the set "+
+				"of these migrations, e.g. %s_<set>)",
+			ErrMigrationsOutsideSets, table, dbVersion, table)
+	}
+
+	return nil
+}
+
+// selectSets returns the set selected with --set, or else all the sets, for
+// the commands which can run on all of them.
+func selectSets[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	sets []preparedSet[TDBRow, TDBResult, TTX, TTXO, TDB],
+	args cliArgs,
+) ([]preparedSet[TDBRow, TDBResult, TTX, TTXO, TDB], error) {
+
+	names := make([]string, len(sets))
+	for i, set := range sets {
+		names[i] = set.Name
+	}
+
+	if args.set != "" {
+		i := slices.Index(names, args.set)
+		if i < 0 {
+			return nil, fmt.Errorf(
+				"unknown migration set: %q (sets: %s)", args.set, strings.Join(names, ", "))
+		}
+		return sets[i : i+1], nil
+	}
+
+	if len(sets) > 1 && slices.Contains(singleSetCommands, args.command) {
+		return nil, fmt.Errorf(
+			"%s needs --set <name> (sets: %s)", args.command, strings.Join(names, ", "))
+	}
+
+	return sets, nil
+}
+
+// writeSetHeader writes the name of the set before its output, when the
+// command runs on several sets.
+func writeSetHeader(output io.Writer, name string, nbSets int) {
+	if nbSets > 1 {
+		_, _ = fmt.Fprintf(output, "==> %s\n", name)
+	}
+}
@@ -0,0 +1,222 @@
+package gosmig
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type migrationSetMock = MigrationSet[*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock]
+
+func TestNewGosmigSetsErrors(t *testing.T) {
+	connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+		return new(dbMock), nil
+	}
+
+	testCases := []struct {
+		name    string
+		sets    []migrationSetMock
+		wantErr string
+	}{
+		{
+			name:    "no sets",
+			wantErr: "no migration sets provided",
+		},
+		{
+			name:    "invalid set name",
+			sets:    []migrationSetMock{{Name: "auth-lib", Migrations: createTestMigrations(1)}},
+			wantErr: `migration set name "auth-lib" must only contain letters, digits and _`,
+		},
+		{
+			name:    "reserved set name",
+			sets:    []migrationSetMock{{Name: "lock", Migrations: createTestMigrations(1)}},
+			wantErr: `migration set name "lock" must not end with lock, which names tracking tables`,
+		},
+		{
+			name:    "set name ending with a reserved suffix",
+			sets:    []migrationSetMock{{Name: "auth_History", Migrations: createTestMigrations(1)}},
+			wantErr: `migration set name "auth_History" must not end with history, which names tracking tables`,
+		},
+		{
+			name: "duplicate set",
+			sets: []migrationSetMock{
+				{Name: "auth", Migrations: createTestMigrations(1)},
+				{Name: "auth", Migrations: createTestMigrations(1)},
+			},
+			wantErr: "migration set auth is defined more than once",
+		},
+		{
+			name: "duplicate set differing in case",
+			sets: []migrationSetMock{
+				{Name: "Auth", Migrations: createTestMigrations(1)},
+				{Name: "auth", Migrations: createTestMigrations(1)},
+			},
+			wantErr: "migration set auth is defined more than once",
+		},
+		{
+			name:    "set without migrations",
+			sets:    []migrationSetMock{{Name: "auth"}},
+			wantErr: "set auth: no migrations provided",
+		},
+		{
+			name:    "set with invalid migrations",
+			sets:    []migrationSetMock{{Name: "auth", Migrations: createTestMigrations(1, 1)}},
+			wantErr: "set auth: invalid migration(s): migration version 1 is defined 2 times",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newGosmigSets(tc.sets, connectToDB, nil,
+				func() []string { return nil }, func(int) {}, io.Discard, io.Discard)
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestNewGosmigSets(t *testing.T) {
+	sets := []migrationSetMock{
+		{Name: "auth", Migrations: createTestMigrations(1, 2)},
+		{Name: "billing", Migrations: createTestMigrations(1)},
+	}
+	authTable := migrationsTableName + "_auth"
+	billingTable := migrationsTableName + "_billing"
+
+	testCases := []struct {
+		name         string
+		args         []string
+		setupMock    func(*dbMock)
+		baseVersion  int
+		wantOut      string
+		wantErrOut   string
+		wantExitCode int
+	}{
+		{
+			name: "status of all the sets, in order",
+			args: []string{"postgres://localhost/db", "status"},
+			setupMock: func(db *dbMock) {
+				for _, table := range []string{authTable, billingTable} {
+					expectMigrationsColumns(db, table)
+					db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(table)).
+						Return(appliedVersionsRow(1)).
+						Once()
+				}
+			},
+			wantOut: "==> auth\n" +
+				"VERSION    STATUS      \n" +
+				"2          [ ] PENDING \n" +
+				"1          [x] APPLIED \n" +
+				"==> billing\n" +
+				"VERSION    STATUS      \n" +
+				"1          [x] APPLIED \n",
+			wantExitCode: -1,
+		},
+		{
+			name: "pending migrations of all the sets",
+			args: []string{"postgres://localhost/db", "status", "--exit-code"},
+			setupMock: func(db *dbMock) {
+				for _, table := range []string{authTable, billingTable} {
+					expectMigrationsColumns(db, table)
+					db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(table)).
+						Return(appliedVersionsRow()).
+						Once()
+				}
+			},
+			wantOut: "==> auth\n" +
+				"VERSION    STATUS      \n" +
+				"2          [ ] PENDING \n" +
+				"1          [ ] PENDING \n" +
+				"==> billing\n" +
+				"VERSION    STATUS      \n" +
+				"1          [ ] PENDING \n",
+			wantErrOut:   "3 pending migration(s)\n",
+			wantExitCode: ExitPendingMigrations,
+		},
+		{
+			name: "status of the selected set",
+			args: []string{"--set", "billing", "postgres://localhost/db", "status"},
+			setupMock: func(db *dbMock) {
+				expectMigrationsColumns(db, billingTable)
+				db.On("QueryRowContext", mock.Anything, selectAppliedVersionsSQL(billingTable)).
+					Return(appliedVersionsRow()).
+					Once()
+			},
+			wantOut:      "VERSION    STATUS      \n1          [ ] PENDING \n",
+			wantExitCode: -1,
+		},
+		{
+			name:         "migrations applied outside of the sets",
+			args:         []string{"postgres://localhost/db", "status"},
+			setupMock:    func(db *dbMock) {},
+			baseVersion:  3,
+			wantErrOut:   "migrations applied outside of the migration sets: table gosmig is at version 3 (rename it, and its tracking tables, after the set of these migrations, e.g. gosmig_<set>)\n",
+			wantExitCode: ExitFailure,
+		},
+		{
+			name:         "unknown set",
+			args:         []string{"--set", "orders", "postgres://localhost/db", "status"},
+			wantErrOut:   usage() + "\nunknown migration set: \"orders\" (sets: auth, billing)\n",
+			wantExitCode: ExitUsageError,
+		},
+		{
+			name:         "down without a set",
+			args:         []string{"postgres://localhost/db", "down"},
+			wantErrOut:   usage() + "\ndown needs --set <name> (sets: auth, billing)\n",
+			wantExitCode: ExitUsageError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := new(dbMock)
+			if tc.setupMock != nil {
+				db.On("QueryRowContext", mock.Anything, tableExistsSQL(), migrationsTableName).
+					Return(dbVersionRow(min(tc.baseVersion, 1))).
+					Once()
+				if tc.baseVersion > 0 {
+					db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+						Return(dbVersionRow(tc.baseVersion)).
+						Once()
+				}
+				tc.setupMock(db)
+				db.On("Close").Return(nil).Once()
+			}
+			connectToDB := func(url string, timeout time.Duration) (*dbMock, error) {
+				return db, nil
+			}
+
+			var out, errOut bytes.Buffer
+			exitCode := -1
+			goSMig, err := newGosmigSets(sets, connectToDB, nil,
+				func() []string { return tc.args }, func(code int) { exitCode = code }, &out, &errOut)
+			require.NoError(t, err)
+
+			goSMig()
+
+			db.AssertExpectations(t)
+			require.Equal(t, tc.wantOut, out.String())
+			require.Equal(t, tc.wantErrOut, errOut.String())
+			require.Equal(t, tc.wantExitCode, exitCode)
+		})
+	}
+}
+
+func TestNewGosmigRefusesSet(t *testing.T) {
+	var errOut bytes.Buffer
+	exitCode := -1
+	goSMig, err := newGosmig(createTestMigrations(1),
+		func(url string, timeout time.Duration) (*dbMock, error) { return new(dbMock), nil },
+		nil,
+		func() []string { return []string{"--set", "auth", "postgres://localhost/db", "status"} },
+		func(code int) { exitCode = code }, io.Discard, &errOut)
+	require.NoError(t, err)
+
+	goSMig()
+
+	require.Equal(t, ExitUsageError, exitCode)
+	require.Equal(t, usage()+"\n--set needs migration sets (see NewSets)\n", errOut.String())
+}
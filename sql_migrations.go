@@ -0,0 +1,335 @@
+package gosmig
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// sumFileName is the name of the file holding the checksums of the SQL
+	// migration files. Its format is the one of sha256sum, so it can also be
+	// checked with `sha256sum -c gosmig.sum`.
+	sumFileName = "gosmig.sum"
+
+	// noTXDirective, when found among the leading comments of an up SQL file,
+	// makes the migration run without a transaction (i.e. as UpDownNoTX).
+	noTXDirective = "-- gosmig:no-tx"
+
+	// baselineDirective, when found among the leading comments of an up SQL
+	// file, marks the migration as a baseline (see Migration.Baseline).
+	baselineDirective = "-- gosmig:baseline"
+
+	// irreversibleDirective, when found among the leading comments of an up
+	// SQL file, marks the migration as irreversible (see
+	// Migration.Irreversible). Such a migration has no down file.
+	irreversibleDirective = "-- gosmig:irreversible"
+)
+
+var sqlFileNameRegexp = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_-]+)\.(up|down)\.sql$`)
+
+type (
+	sqlMigrationFile struct {
+		name      string
+		version   int
+		direction Direction
+		content   []byte
+	}
+
+	sqlMigrationFilePair struct {
+		version int
+		up      *sqlMigrationFile
+		down    *sqlMigrationFile
+	}
+)
+
+// LoadSQLMigrations loads the migrations defined as SQL files in the root
+// directory of fsys (e.g. an embed.FS or os.DirFS). The files are named
+// <version>_<description>.up.sql and <version>_<description>.down.sql.
+// Migrations whose up file starts with a "-- gosmig:no-tx" comment run
+// without a transaction, and those whose up file starts with a
+// "-- gosmig:baseline" comment are baselines (see Migration.Baseline). Those
+// whose up file starts with a "-- gosmig:irreversible" comment are
+// irreversible (see Migration.Irreversible) and have no down file.
+// Other files (e.g. gosmig.sum or repeatable migrations) are ignored. It fails
+// if a version has several up (or down) files.
+//
+// The loaded migrations can be combined with migrations written in Go.
+func LoadSQLMigrations[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	fsys fs.FS,
+) ([]Migration[TDBRow, TDBResult, TTX, TTXO, TDB], error) {
+
+	files, invalidNames, err := readSQLMigrationFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if len(invalidNames) > 0 {
+		return nil, fmt.Errorf(
+			"invalid SQL migration file name(s): %s", strings.Join(invalidNames, ", "))
+	}
+
+	if errs := duplicateSQLFilesErrs(files); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	var migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB]
+	for _, pair := range pairSQLMigrationFiles(files) {
+		if pair.up == nil {
+			return nil, fmt.Errorf("missing up SQL file for migration %d", pair.version)
+		}
+		irreversible := pair.up.hasDirective(irreversibleDirective)
+		if err := pair.downFileErr(irreversible); err != nil {
+			return nil, err
+		}
+
+		migration := Migration[TDBRow, TDBResult, TTX, TTXO, TDB]{
+			Version:      pair.version,
+			Checksum:     pair.checksum(),
+			Baseline:     pair.up.hasDirective(baselineDirective),
+			Irreversible: irreversible,
+		}
+		if pair.up.hasDirective(noTXDirective) {
+			migration.UpDownNoTX = &UpDown[TDBRow, TDBResult, TDB]{
+				Up: execSQL[TDBRow, TDBResult, TDB](pair.up.content),
+			}
+			if !irreversible {
+				migration.UpDownNoTX.Down = execSQL[TDBRow, TDBResult, TDB](pair.down.content)
+			}
+		} else {
+			migration.UpDown = &UpDown[TDBRow, TDBResult, TTX]{
+				Up: execSQL[TDBRow, TDBResult, TTX](pair.up.content),
+			}
+			if !irreversible {
+				migration.UpDown.Down = execSQL[TDBRow, TDBResult, TTX](pair.down.content)
+			}
+		}
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, nil
+}
+
+// LoadSQLMigrationsSQL is LoadSQLMigrations for database/sql.
+func LoadSQLMigrationsSQL(fsys fs.FS) ([]MigrationSQL, error) {
+	return LoadSQLMigrations[*sql.Row, sql.Result, *sql.Tx, *sql.TxOptions, *sql.DB](fsys)
+}
+
+// WriteSumFile (re)writes the gosmig.sum file holding the checksums of the
+// SQL migration files from dir.
+func WriteSumFile(dir string) error {
+	files, _, err := readSQLMigrationFiles(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+
+	var sum bytes.Buffer
+	for _, file := range files {
+		_, _ = fmt.Fprintf(&sum, "%s  %s\n", checksum(file.content), file.name)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, sumFileName), sum.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", sumFileName, err)
+	}
+
+	return nil
+}
+
+func execSQL[TDBRow DBRow, TDBResult DBResult, TDBOrTX DBOrTX[TDBRow, TDBResult]](
+	content []byte,
+) func(context.Context, TDBOrTX) error {
+
+	query := string(content)
+	return func(ctx context.Context, dbOrTX TDBOrTX) error {
+		_, err := dbOrTX.ExecContext(ctx, query)
+		return err
+	}
+}
+
+// readSQLMigrationFiles reads the SQL migration files from the root
+// directory of fsys, sorted by name. It also returns the names of the .sql
+// files which don't follow the naming convention.
+func readSQLMigrationFiles(fsys fs.FS) ([]sqlMigrationFile, []string, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SQL migrations directory: %w", err)
+	}
+
+	var files []sqlMigrationFile
+	var invalidNames []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".sql" ||
+			strings.HasSuffix(name, repeatableFileSuffix) {
+			continue
+		}
+
+		matches := sqlFileNameRegexp.FindStringSubmatch(name)
+		if matches == nil {
+			invalidNames = append(invalidNames, name)
+			continue
+		}
+		version, err := strconv.Atoi(matches[1])
+		if err != nil || version <= 0 {
+			invalidNames = append(invalidNames, name)
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read SQL migration file %s: %w", name, err)
+		}
+
+		files = append(files, sqlMigrationFile{
+			name:      name,
+			version:   version,
+			direction: Direction(matches[3]),
+			content:   content,
+		})
+	}
+
+	return files, invalidNames, nil
+}
+
+// duplicateSQLFilesErrs reports the versions with several up (or down)
+// files, e.g. 002_add_a.up.sql and 002_add_b.up.sql.
+func duplicateSQLFilesErrs(files []sqlMigrationFile) []string {
+	filesByVersionAndDirection := make(map[string][]string)
+	for _, file := range files {
+		key := fmt.Sprintf("%d.%s", file.version, file.direction)
+		filesByVersionAndDirection[key] = append(filesByVersionAndDirection[key], file.name)
+	}
+
+	var errs []string
+	for _, key := range slices.Sorted(maps.Keys(filesByVersionAndDirection)) {
+		if names := filesByVersionAndDirection[key]; len(names) > 1 {
+			errs = append(errs, "duplicate SQL migration files: "+strings.Join(names, ", "))
+		}
+	}
+
+	return errs
+}
+
+// pairSQLMigrationFiles groups the up and down files by version, sorted by
+// version. The files must not have duplicates (see duplicateSQLFilesErrs):
+// the last up (or down) file of a version would win.
+func pairSQLMigrationFiles(files []sqlMigrationFile) []sqlMigrationFilePair {
+	pairsByVersion := make(map[int]*sqlMigrationFilePair)
+	for i := range files {
+		file := &files[i]
+		pair, ok := pairsByVersion[file.version]
+		if !ok {
+			pair = &sqlMigrationFilePair{version: file.version}
+			pairsByVersion[file.version] = pair
+		}
+		if file.direction == DirectionUp {
+			pair.up = file
+		} else {
+			pair.down = file
+		}
+	}
+
+	pairs := make([]sqlMigrationFilePair, 0, len(pairsByVersion))
+	for _, pair := range pairsByVersion {
+		pairs = append(pairs, *pair)
+	}
+	slices.SortFunc(pairs, func(a, b sqlMigrationFilePair) int {
+		return cmp.Compare(a.version, b.version)
+	})
+
+	return pairs
+}
+
+func (p sqlMigrationFilePair) checksum() string {
+	var content []byte
+	if p.up != nil {
+		content = append(content, p.up.content...)
+	}
+	if p.down != nil {
+		content = append(content, p.down.content...)
+	}
+	return checksum(content)
+}
+
+// downFileErr reports a missing down file, or an unexpected one for an
+// irreversible migration.
+func (p sqlMigrationFilePair) downFileErr(irreversible bool) error {
+	switch {
+	case p.down == nil && !irreversible:
+		return fmt.Errorf("missing down SQL file for migration %d", p.version)
+	case p.down != nil && irreversible:
+		return fmt.Errorf(
+			"irreversible migration %d must not have a down SQL file", p.version)
+	}
+	return nil
+}
+
+// hasDirective tells whether the directive is among the leading comment
+// lines of the file.
+func (f *sqlMigrationFile) hasDirective(directive string) bool {
+	for line := range strings.Lines(string(f.content)) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+		if line == directive {
+			return true
+		}
+	}
+	return false
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// readSumFile reads the checksums of the SQL migration files from the
+// gosmig.sum file, keyed by file name. It returns nil if there is no such file.
+func readSumFile(fsys fs.FS) (map[string]string, error) {
+	content, err := fs.ReadFile(fsys, sumFileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", sumFileName, err)
+	}
+
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNb := 1; scanner.Scan(); lineNb++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %d in %s: %q", lineNb, sumFileName, line)
+		}
+		sums[name] = sum
+	}
+
+	return sums, nil
+}
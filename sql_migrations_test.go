@@ -0,0 +1,167 @@
+package gosmig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSQLMigrations(t *testing.T) {
+	testCases := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantVersions []int
+		wantNoTX     []bool
+		wantBaseline []bool
+		wantIrrev    []bool
+		wantErr      string
+	}{
+		{
+			name: "valid files",
+			fsys: fstest.MapFS{
+				"002_add_index.up.sql": {
+					Data: []byte("-- gosmig:no-tx\nCREATE INDEX CONCURRENTLY i ON t (c);")},
+				"002_add_index.down.sql": {Data: []byte("DROP INDEX i;")},
+				"001_create_t.up.sql":    {Data: []byte("CREATE TABLE t (c INT);")},
+				"001_create_t.down.sql":  {Data: []byte("DROP TABLE t;")},
+				"003_baseline.up.sql": {
+					Data: []byte("\n-- gosmig:baseline\n-- squashed\nCREATE TABLE u (c INT);")},
+				"003_baseline.down.sql": {Data: []byte("-- can't be rolled back")},
+				"004_add_e.up.sql": {
+					Data: []byte("ALTER TABLE t ADD COLUMN e INT;\n-- gosmig:no-tx")},
+				"004_add_e.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN e;")},
+				"005_drop_d.up.sql": {
+					Data: []byte("-- gosmig:irreversible\nALTER TABLE t DROP COLUMN d;")},
+				"gosmig.sum": {Data: []byte("ignored")},
+				"README.md":  {Data: []byte("ignored")},
+			},
+			wantVersions: []int{1, 2, 3, 4, 5},
+			wantNoTX:     []bool{false, true, false, false, false},
+			wantBaseline: []bool{false, false, true, false, false},
+			wantIrrev:    []bool{false, false, false, false, true},
+		},
+		{
+			name: "down file of an irreversible migration",
+			fsys: fstest.MapFS{
+				"001_drop_t.up.sql":   {Data: []byte("-- gosmig:irreversible\nDROP TABLE t;")},
+				"001_drop_t.down.sql": {Data: []byte("-- nothing")},
+			},
+			wantErr: "irreversible migration 1 must not have a down SQL file",
+		},
+		{
+			name: "invalid file name",
+			fsys: fstest.MapFS{
+				"create_t.up.sql": {Data: []byte("CREATE TABLE t (c INT);")},
+			},
+			wantErr: "invalid SQL migration file name(s): create_t.up.sql",
+		},
+		{
+			name: "missing down file",
+			fsys: fstest.MapFS{
+				"001_create_t.up.sql": {Data: []byte("CREATE TABLE t (c INT);")},
+			},
+			wantErr: "missing down SQL file for migration 1",
+		},
+		{
+			name: "missing up file",
+			fsys: fstest.MapFS{
+				"001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+			wantErr: "missing up SQL file for migration 1",
+		},
+		{
+			name: "duplicate files",
+			fsys: fstest.MapFS{
+				"001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (c INT);")},
+				"001_create_u.up.sql":   {Data: []byte("CREATE TABLE u (c INT);")},
+				"001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+			wantErr: "duplicate SQL migration files: 001_create_t.up.sql, 001_create_u.up.sql",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := LoadSQLMigrations[
+				*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock](tc.fsys)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, migrations, len(tc.wantVersions))
+			for i, migration := range migrations {
+				require.Equal(t, tc.wantVersions[i], migration.Version)
+				require.NotEmpty(t, migration.Checksum)
+				require.Equal(t, tc.wantNoTX[i], migration.UpDownNoTX != nil)
+				require.Equal(t, !tc.wantNoTX[i], migration.UpDown != nil)
+				require.Equal(t, tc.wantBaseline[i], migration.Baseline)
+				require.Equal(t, tc.wantIrrev[i], migration.Irreversible)
+				require.NoError(t, migration.validate())
+			}
+		})
+	}
+}
+
+func TestLoadSQLMigrationsExecutesSQL(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_create_t.up.sql":   {Data: []byte("CREATE TABLE t (c INT);")},
+		"1_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+	migrations, err := LoadSQLMigrations[
+		*dbRowMock, *dbResultMock, *txMock, txOptionsMock, *dbMock](fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+
+	tx := new(txMock)
+	tx.On("ExecContext", mock.Anything, "CREATE TABLE t (c INT);").
+		Return(new(dbResultMock), nil).
+		Once()
+	tx.On("ExecContext", mock.Anything, "DROP TABLE t;").
+		Return(new(dbResultMock), nil).
+		Once()
+
+	require.NoError(t, migrations[0].UpDown.Up(context.Background(), tx))
+	require.NoError(t, migrations[0].UpDown.Down(context.Background(), tx))
+	tx.AssertExpectations(t)
+}
+
+func TestWriteSumFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"001_create_t.up.sql":   "CREATE TABLE t (c INT);",
+		"001_create_t.down.sql": "DROP TABLE t;",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	require.NoError(t, WriteSumFile(dir))
+
+	sums, err := readSumFile(os.DirFS(dir))
+	require.NoError(t, err)
+	require.Len(t, sums, len(files))
+	for name, content := range files {
+		require.Equal(t, checksum([]byte(content)), sums[name])
+	}
+}
+
+func TestReadSumFile(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		sums, err := readSumFile(fstest.MapFS{})
+		require.NoError(t, err)
+		require.Nil(t, sums)
+	})
+
+	t.Run("invalid line", func(t *testing.T) {
+		_, err := readSumFile(fstest.MapFS{
+			"gosmig.sum": {Data: []byte("abc 001_create_t.up.sql\n")},
+		})
+		require.EqualError(t, err, `invalid line 1 in gosmig.sum: "abc 001_create_t.up.sql"`)
+	})
+}
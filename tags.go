@@ -0,0 +1,64 @@
+package gosmig
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// selectedByTags tells whether a migration with the given tags runs with the
+// given tag selector: untagged migrations always run, and so do all of them
+// when the selector is empty. Otherwise the migration needs one of its tags
+// to be in the selector.
+func selectedByTags(tags, selector []string) bool {
+	if len(tags) == 0 || len(selector) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(tags, func(tag string) bool {
+		return slices.Contains(selector, tag)
+	})
+}
+
+// parseTags parses a comma-separated list of tags, as given to --tags or
+// recorded in the migrations table.
+func parseTags(s string) []string {
+	var tags []string
+	for tag := range strings.SplitSeq(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func formatTags(tags []string) string {
+	return strings.Join(tags, ",")
+}
+
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if !nameRegexp.MatchString(tag) {
+			return fmt.Errorf("tag %q must only contain letters, digits, _ and -", tag)
+		}
+	}
+	return nil
+}
+
+// usesTags tells whether tags are in use, in which case the migrations table
+// records the tag selector each migration was applied (or skipped) with.
+func usesTags[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	config *Config,
+) bool {
+
+	return len(config.Tags) > 0 ||
+		slices.ContainsFunc(migrations, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+			return len(m.Tags) > 0
+		})
+}
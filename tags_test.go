@@ -0,0 +1,52 @@
+package gosmig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectedByTags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tags     []string
+		selector []string
+		want     bool
+	}{
+		{name: "untagged, no selector", want: true},
+		{name: "untagged, selector", selector: []string{"prod"}, want: true},
+		{name: "tagged, no selector", tags: []string{"test"}, want: true},
+		{name: "tagged, selected", tags: []string{"test", "dev"}, selector: []string{"dev"}, want: true},
+		{name: "tagged, not selected", tags: []string{"test"}, selector: []string{"prod", "eu"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, selectedByTags(tc.tags, tc.selector))
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	require.Nil(t, parseTags(""))
+	require.Equal(t, []string{"prod", "eu"}, parseTags(" prod, eu,"))
+	require.Equal(t, "prod,eu", formatTags(parseTags("prod,eu")))
+}
+
+func TestValidateTags(t *testing.T) {
+	require.NoError(t, validateTags([]string{"prod", "eu-west_1"}))
+	require.EqualError(t, validateTags([]string{"prod", ""}),
+		`tag "" must only contain letters, digits, _ and -`)
+}
+
+func TestUsesTags(t *testing.T) {
+	migrations := createTestMigrations(1, 2)
+	require.False(t, usesTags(migrations, DefaultConfig()))
+
+	config := DefaultConfig()
+	config.Tags = []string{"prod"}
+	require.True(t, usesTags(migrations, config))
+
+	migrations[1].Tags = []string{"test"}
+	require.True(t, usesTags(migrations, DefaultConfig()))
+}
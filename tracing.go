@@ -0,0 +1,117 @@
+package gosmig
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	nooptrace "go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the name of the OpenTelemetry tracer (the instrumentation scope).
+const tracerName = "github.com/padurean/gosmig"
+
+// The attributes of the spans.
+const (
+	attrCommand            = attribute.Key("gosmig.command")
+	attrTable              = attribute.Key("gosmig.table")
+	attrTarget             = attribute.Key("gosmig.target")
+	attrMigrationVersion   = attribute.Key("gosmig.migration.version")
+	attrMigrationModule    = attribute.Key("gosmig.migration.module")
+	attrMigrationDirection = attribute.Key("gosmig.migration.direction")
+	attrMigrationTxMode    = attribute.Key("gosmig.migration.tx_mode")
+	attrMigrationPhase     = attribute.Key("gosmig.migration.phase")
+	attrMigrationSkipped   = attribute.Key("gosmig.migration.skipped")
+	attrMigrationResult    = attribute.Key("gosmig.migration.result")
+)
+
+// The results of the migration spans.
+const (
+	spanResultSuccess = "success"
+	spanResultFailure = "failure"
+)
+
+// tracer returns the tracer of Config.TracerProvider, or a no-op one if it's
+// not set.
+func (c *Config) tracer() trace.Tracer {
+	if c.TracerProvider == nil {
+		return nooptrace.NewTracerProvider().Tracer(tracerName)
+	}
+	return c.TracerProvider.Tracer(tracerName)
+}
+
+// startCmdSpan starts the span of a command, which the spans of its
+// migrations are children of.
+func startCmdSpan(
+	ctx context.Context, command string, config *Config, attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+
+	attrs = append([]attribute.KeyValue{
+		attrCommand.String(command),
+		attrTable.String(config.migrationsTable()),
+	}, attrs...)
+
+	return config.tracer().Start(ctx, "gosmig "+command, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span, recording the error, if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceMigration runs a step of the migration (e.g. applying or rolling it
+// back, or only its given phase, if any) in a span, child of the command
+// span, telling whether the migration was skipped. The context passed to run, and
+// so to the migration functions, carries the span, so that the spans of the
+// queries they run (if instrumented) are its children.
+func traceMigration[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	ctx context.Context,
+	migration Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	direction Direction,
+	txMode TxMode,
+	phase string,
+	skipped bool,
+	config *Config,
+	run func(ctx context.Context) error,
+) error {
+
+	attrs := []attribute.KeyValue{
+		attrMigrationVersion.Int(migration.Version),
+		attrMigrationDirection.String(string(direction)),
+		attrMigrationTxMode.String(string(txMode)),
+	}
+	if phase != "" {
+		attrs = append(attrs, attrMigrationPhase.String(phase))
+	}
+	if skipped {
+		attrs = append(attrs, attrMigrationSkipped.Bool(true))
+	}
+	if migration.Module != "" {
+		attrs = append(attrs, attrMigrationModule.String(migration.Module))
+	}
+
+	ctx, span := config.tracer().Start(ctx,
+		fmt.Sprintf("migration %s %s", migration.ID(), direction), trace.WithAttributes(attrs...))
+
+	err := run(ctx)
+	if err != nil {
+		span.SetAttributes(attrMigrationResult.String(spanResultFailure))
+	} else {
+		span.SetAttributes(attrMigrationResult.String(spanResultSuccess))
+	}
+	endSpan(span, err)
+
+	return err
+}
@@ -0,0 +1,191 @@
+package gosmig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	nooptrace "go.opentelemetry.io/otel/trace/noop"
+)
+
+type (
+	// testTracerProvider provides a tracer recording the spans, once ended,
+	// so that the tests don't need the OpenTelemetry SDK.
+	testTracerProvider struct {
+		nooptrace.TracerProvider
+		tracer *testTracer
+	}
+
+	testTracer struct {
+		nooptrace.Tracer
+		ended []*testSpan
+		nbIDs byte
+	}
+
+	testSpan struct {
+		nooptrace.Span
+		tracer      *testTracer
+		name        string
+		spanContext trace.SpanContext
+		parent      trace.SpanContext
+		attrs       map[attribute.Key]attribute.Value
+		status      codes.Code
+	}
+)
+
+// newTestTracerProvider returns a tracer provider recording the spans, once
+// ended, in the returned tracer.
+func newTestTracerProvider() (testTracerProvider, *testTracer) {
+	tracer := new(testTracer)
+	return testTracerProvider{tracer: tracer}, tracer
+}
+
+func (p testTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return p.tracer
+}
+
+func (t *testTracer) Start(
+	ctx context.Context, name string, opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+
+	t.nbIDs++
+	span := &testSpan{
+		tracer: t,
+		name:   name,
+		spanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{1},
+			SpanID:  trace.SpanID{t.nbIDs},
+		}),
+		parent: trace.SpanContextFromContext(ctx),
+		attrs:  make(map[attribute.Key]attribute.Value),
+	}
+	spanConfig := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(spanConfig.Attributes()...)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (s *testSpan) SpanContext() trace.SpanContext { return s.spanContext }
+
+func (s *testSpan) IsRecording() bool { return true }
+
+func (s *testSpan) SetAttributes(attrs ...attribute.KeyValue) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *testSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+
+func (s *testSpan) End(...trace.SpanEndOption) { s.tracer.ended = append(s.tracer.ended, s) }
+
+func TestTracing(t *testing.T) {
+	upErr := errors.New("relation already exists")
+
+	testCases := []struct {
+		name       string
+		upErr      error
+		wantResult string
+		wantStatus codes.Code
+	}{
+		{
+			name:       "applied migration",
+			wantResult: spanResultSuccess,
+			wantStatus: codes.Unset,
+		},
+		{
+			name:       "failing migration",
+			upErr:      upErr,
+			wantResult: spanResultFailure,
+			wantStatus: codes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracerProvider, tracer := newTestTracerProvider()
+			config := DefaultConfig()
+			config.TracerProvider = tracerProvider
+
+			var upSpanContext trace.SpanContext
+			migration := createTestMigrations(1)[0]
+			migration.UpDown.Up = func(ctx context.Context, tx *txMock) error {
+				upSpanContext = trace.SpanContextFromContext(ctx)
+				if tc.upErr != nil {
+					return tc.upErr
+				}
+				_, err := tx.ExecContext(ctx, "CREATE TABLE test (id INT)")
+				return err
+			}
+
+			db := new(dbMock)
+			tx := new(txMock)
+			db.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(0)).
+				Once()
+			db.On("BeginTx", mock.Anything, mock.Anything).Return(tx, nil).Once()
+			tx.On("QueryRowContext", mock.Anything, selectDBVersionSQL(migrationsTableName)).
+				Return(dbVersionRow(0)).
+				Once()
+			if tc.upErr != nil {
+				tx.On("Rollback").Return(nil).Once()
+			} else {
+				tx.On("ExecContext", mock.Anything, "CREATE TABLE test (id INT)").
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("ExecContext", mock.Anything, insertMigVersionSQL(migrationsTableName), 1).
+					Return(new(dbResultMock), nil).
+					Once()
+				tx.On("Commit").Return(nil).Once()
+			}
+
+			ctx, cmdSpan := startCmdSpan(context.Background(), cmdUp, config)
+			var out bytes.Buffer
+			_, err := runCmdUp(ctx, []migrationMock{migration}, db, &out, 0, config)
+			endSpan(cmdSpan, err)
+			db.AssertExpectations(t)
+			tx.AssertExpectations(t)
+			if tc.upErr != nil {
+				require.ErrorIs(t, err, tc.upErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Len(t, tracer.ended, 2)
+			migSpan, cmdSpanEnded := tracer.ended[0], tracer.ended[1]
+
+			require.Equal(t, "gosmig up", cmdSpanEnded.name)
+			require.Equal(t, map[attribute.Key]attribute.Value{
+				attrCommand: attribute.StringValue(cmdUp),
+				attrTable:   attribute.StringValue(migrationsTableName),
+			}, cmdSpanEnded.attrs)
+			require.Equal(t, tc.wantStatus, cmdSpanEnded.status)
+
+			require.Equal(t, "migration 1 up", migSpan.name)
+			require.Equal(t, cmdSpanEnded.spanContext.SpanID(), migSpan.parent.SpanID())
+			require.Equal(t, map[attribute.Key]attribute.Value{
+				attrMigrationVersion:   attribute.IntValue(1),
+				attrMigrationDirection: attribute.StringValue(string(DirectionUp)),
+				attrMigrationTxMode:    attribute.StringValue(string(TxModeTX)),
+				attrMigrationResult:    attribute.StringValue(tc.wantResult),
+			}, migSpan.attrs)
+			require.Equal(t, tc.wantStatus, migSpan.status)
+
+			// The queries of the migration functions nest under the migration span.
+			require.Equal(t, migSpan.spanContext.SpanID(), upSpanContext.SpanID())
+		})
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	ctx, span := startCmdSpan(context.Background(), cmdUp, DefaultConfig())
+	defer endSpan(span, nil)
+
+	require.False(t, span.IsRecording())
+	require.False(t, trace.SpanContextFromContext(ctx).IsValid())
+}
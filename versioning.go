@@ -0,0 +1,88 @@
+package gosmig
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// timestampVersionLayout is the time layout of VersioningTimestamp versions.
+const timestampVersionLayout = "20060102150405"
+
+// parseTimestampVersion returns the UTC time of a VersioningTimestamp version.
+func parseTimestampVersion(version int) (time.Time, error) {
+	versionStr := strconv.Itoa(version)
+	if len(versionStr) != len(timestampVersionLayout) {
+		return time.Time{}, fmt.Errorf("%d is not a YYYYMMDDhhmmss timestamp", version)
+	}
+	t, err := time.Parse(timestampVersionLayout, versionStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%d is not a YYYYMMDDhhmmss timestamp", version)
+	}
+	return t, nil
+}
+
+// formatVersionTime returns the time of a version in a human-readable form
+// for VersioningTimestamp, and an empty string otherwise (or for version 0,
+// i.e. no migration applied).
+func formatVersionTime(version int, versioning VersioningScheme) string {
+	if versioning != VersioningTimestamp || version == 0 {
+		return ""
+	}
+	t, err := parseTimestampVersion(version)
+	if err != nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05 UTC")
+}
+
+// versionsValidationErrs checks that the versions of the migrations follow
+// the versioning scheme.
+func versionsValidationErrs[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+	versioning VersioningScheme,
+) []string {
+
+	if versioning != VersioningTimestamp {
+		return nil
+	}
+
+	var errs []string
+	for _, migration := range migrations {
+		if migration.Version <= 0 {
+			continue // already reported by Migration.validate
+		}
+		if _, err := parseTimestampVersion(migration.Version); err != nil {
+			errs = append(errs, fmt.Sprintf("migration version %v", err))
+		}
+	}
+
+	return errs
+}
+
+// hasBigintVersions tells whether any of the migrations has a version which
+// doesn't fit in an INTEGER (e.g. a VersioningTimestamp one), in which case
+// the version column of migrations tables created before it was a BIGINT is
+// widened.
+func hasBigintVersions[
+	TDBRow DBRow,
+	TDBResult DBResult,
+	TTX TX[TDBRow, TDBResult],
+	TTXO TXOptions,
+	TDB DB[TDBRow, TDBResult, TTX, TTXO]](
+
+	migrations []Migration[TDBRow, TDBResult, TTX, TTXO, TDB],
+) bool {
+
+	return slices.ContainsFunc(migrations, func(m Migration[TDBRow, TDBResult, TTX, TTXO, TDB]) bool {
+		return m.Version > math.MaxInt32
+	})
+}
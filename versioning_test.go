@@ -0,0 +1,88 @@
+package gosmig
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimestampVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version int
+		want    time.Time
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			version: 20261016093005,
+			want:    time.Date(2026, 10, 16, 9, 30, 5, 0, time.UTC),
+		},
+		{
+			name:    "too short",
+			version: 202610160930,
+			wantErr: "202610160930 is not a YYYYMMDDhhmmss timestamp",
+		},
+		{
+			name:    "invalid month",
+			version: 20261316093005,
+			wantErr: "20261316093005 is not a YYYYMMDDhhmmss timestamp",
+		},
+		{
+			name:    "sequential version",
+			version: 3,
+			wantErr: "3 is not a YYYYMMDDhhmmss timestamp",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseTimestampVersion(tc.version)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFormatVersionTime(t *testing.T) {
+	require.Equal(t, "2026-10-16 09:30:05 UTC",
+		formatVersionTime(20261016093005, VersioningTimestamp))
+	require.Empty(t, formatVersionTime(0, VersioningTimestamp))
+	require.Empty(t, formatVersionTime(3, VersioningTimestamp))
+	require.Empty(t, formatVersionTime(20261016093005, VersioningSequential))
+}
+
+func TestVersionsValidationErrs(t *testing.T) {
+	migrations := createTestMigrations(20261016093005, 0, 42)
+
+	require.Empty(t, versionsValidationErrs(migrations, VersioningSequential))
+	require.Equal(t,
+		[]string{"migration version 42 is not a YYYYMMDDhhmmss timestamp"},
+		versionsValidationErrs(migrations, VersioningTimestamp))
+}
+
+func TestSortMigrationsLargeVersions(t *testing.T) {
+	// Subtraction-based comparators would overflow with these versions.
+	migrations := createTestMigrations(math.MaxInt, 20261016093005, math.MinInt+1, 1)
+
+	sortMigrationsAsc(migrations)
+	require.Equal(t,
+		[]int{math.MinInt + 1, 1, 20261016093005, math.MaxInt},
+		[]int{migrations[0].Version, migrations[1].Version, migrations[2].Version, migrations[3].Version})
+
+	sortMigrationsDesc(migrations)
+	require.Equal(t,
+		[]int{math.MaxInt, 20261016093005, 1, math.MinInt + 1},
+		[]int{migrations[0].Version, migrations[1].Version, migrations[2].Version, migrations[3].Version})
+}
+
+func TestHasBigintVersions(t *testing.T) {
+	require.False(t, hasBigintVersions(createTestMigrations(1, 2, math.MaxInt32)))
+	require.True(t, hasBigintVersions(createTestMigrations(1, 20261016093005)))
+}